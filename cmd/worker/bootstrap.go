@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	"go.artefactual.dev/tools/log"
+
+	"github.com/artefactual-sdps/preprocessing-moma/cmd/worker/bootstrapcmd"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+)
+
+// bootstrapCmd registers the workflow search attributes in the Temporal
+// namespace and schedules the housekeeping workflow, e.g.:
+//
+//	preprocessing-moma-worker bootstrap [--config <file>]
+func bootstrapCmd(ctx context.Context, args []string) error {
+	p := pflag.NewFlagSet(bootstrapcmd.Name, pflag.ExitOnError)
+	p.String("config", "", "Configuration file")
+	_ = p.Parse(args)
+
+	var cfg config.Configuration
+	configFile, _ := p.GetString("config")
+	if _, _, err := config.Read(&cfg, configFile); err != nil {
+		return fmt.Errorf("Failed to read configuration: %v", err)
+	}
+
+	logger := log.New(os.Stderr,
+		log.WithName(bootstrapcmd.Name),
+		log.WithDebug(cfg.Debug),
+		log.WithLevel(cfg.Verbosity),
+	)
+	defer log.Sync(logger)
+
+	m := bootstrapcmd.NewMain(logger, cfg, os.Stdout)
+	defer m.Close()
+
+	return m.Run(ctx)
+}
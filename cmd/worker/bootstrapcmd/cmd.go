@@ -0,0 +1,193 @@
+// Package bootstrapcmd prepares a Temporal namespace for the preprocessing
+// workflow.
+package bootstrapcmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-logr/logr"
+	"go.artefactual.dev/tools/temporal"
+	temporalapi_enums "go.temporal.io/api/enums/v1"
+	temporalapi_operatorservice "go.temporal.io/api/operatorservice/v1"
+	temporalapi_serviceerror "go.temporal.io/api/serviceerror"
+	temporalsdk_client "go.temporal.io/sdk/client"
+	temporalsdk_temporal "go.temporal.io/sdk/temporal"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/codec"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/workflow"
+)
+
+const Name = "bootstrap"
+
+type Main struct {
+	logger         logr.Logger
+	cfg            config.Configuration
+	stdout         io.Writer
+	temporalClient temporalsdk_client.Client
+}
+
+func NewMain(logger logr.Logger, cfg config.Configuration, stdout io.Writer) *Main {
+	return &Main{
+		logger: logger,
+		cfg:    cfg,
+		stdout: stdout,
+	}
+}
+
+func (m *Main) dial() (temporalsdk_client.Client, error) {
+	if m.temporalClient != nil {
+		return m.temporalClient, nil
+	}
+
+	// The arguments of the scheduled workflows are encrypted with the worker
+	// keys.
+	pc, err := codec.New(m.cfg.Codec)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := temporalsdk_client.Dial(codec.ClientOptions(temporalsdk_client.Options{
+		HostPort:  m.cfg.Temporal.Address,
+		Namespace: m.cfg.Temporal.Namespace,
+		Logger:    temporal.Logger(m.logger.WithName("temporal")),
+	}, pc))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create Temporal client: %v", err)
+	}
+	m.temporalClient = c
+
+	return c, nil
+}
+
+// Run registers the workflow search attributes in the configured namespace,
+// and schedules the housekeeping workflow.
+func (m *Main) Run(ctx context.Context) error {
+	c, err := m.dial()
+	if err != nil {
+		return err
+	}
+
+	added, err := RegisterSearchAttributes(ctx, c, m.cfg.Temporal.Namespace)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range added {
+		fmt.Fprintf(m.stdout, "Search attribute registered: %s\n", name)
+	}
+	if len(added) == 0 {
+		fmt.Fprintln(m.stdout, "Search attributes already registered.")
+	}
+
+	scheduled, err := ScheduleHousekeeping(ctx, c, m.cfg)
+	if err != nil {
+		return err
+	}
+	if scheduled {
+		fmt.Fprintf(m.stdout, "Housekeeping scheduled: %s\n", workflow.HousekeepingScheduleID(m.cfg.Temporal.TaskQueue))
+	} else {
+		fmt.Fprintln(m.stdout, "Housekeeping not scheduled, the trash retention is zero.")
+	}
+
+	return nil
+}
+
+func (m *Main) Close() error {
+	if m.temporalClient != nil {
+		m.temporalClient.Close()
+	}
+
+	return nil
+}
+
+// RegisterSearchAttributes registers the workflow.SearchAttributes missing in
+// namespace, and returns their names. It returns an error if a search
+// attribute is registered with a different type.
+func RegisterSearchAttributes(ctx context.Context, c temporalsdk_client.Client, namespace string) ([]string, error) {
+	resp, err := c.OperatorService().ListSearchAttributes(ctx, &temporalapi_operatorservice.ListSearchAttributesRequest{
+		Namespace: namespace,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list search attributes: %v", err)
+	}
+
+	missing := map[string]temporalapi_enums.IndexedValueType{}
+	var names []string
+	for _, key := range workflow.SearchAttributes {
+		name := key.GetName()
+		t, ok := resp.GetCustomAttributes()[name]
+		if !ok {
+			missing[name] = key.GetValueType()
+			names = append(names, name)
+			continue
+		}
+		if t != key.GetValueType() {
+			return nil, fmt.Errorf(
+				"search attribute %s is registered with type %s, expected %s",
+				name, t, key.GetValueType(),
+			)
+		}
+	}
+	if len(missing) == 0 {
+		return nil, nil
+	}
+
+	if _, err := c.OperatorService().AddSearchAttributes(ctx, &temporalapi_operatorservice.AddSearchAttributesRequest{
+		Namespace:        namespace,
+		SearchAttributes: missing,
+	}); err != nil {
+		return nil, fmt.Errorf("unable to register search attributes: %v", err)
+	}
+
+	return names, nil
+}
+
+// ScheduleHousekeeping creates or updates the schedule of the
+// HousekeepingWorkflow purging the trash, and reports whether it's scheduled.
+// The schedule is deleted if the cfg trash retention is zero.
+func ScheduleHousekeeping(ctx context.Context, c temporalsdk_client.Client, cfg config.Configuration) (bool, error) {
+	id := workflow.HousekeepingScheduleID(cfg.Temporal.TaskQueue)
+	sc := c.ScheduleClient()
+
+	if cfg.Trash.Retention == 0 {
+		err := sc.GetHandle(ctx, id).Delete(ctx)
+		var notFound *temporalapi_serviceerror.NotFound
+		if err != nil && !errors.As(err, &notFound) {
+			return false, fmt.Errorf("unable to delete schedule: %v", err)
+		}
+		return false, nil
+	}
+
+	spec := temporalsdk_client.ScheduleSpec{CronExpressions: []string{cfg.Trash.Schedule}}
+	action := &temporalsdk_client.ScheduleWorkflowAction{
+		ID:        id,
+		Workflow:  workflow.HousekeepingWorkflowName,
+		Args:      []interface{}{&workflow.HousekeepingWorkflowParams{Retention: cfg.Trash.Retention}},
+		TaskQueue: cfg.Temporal.TaskQueue,
+	}
+	_, err := sc.Create(ctx, temporalsdk_client.ScheduleOptions{
+		ID:      id,
+		Spec:    spec,
+		Action:  action,
+		Overlap: temporalapi_enums.SCHEDULE_OVERLAP_POLICY_SKIP,
+	})
+	if errors.Is(err, temporalsdk_temporal.ErrScheduleAlreadyRunning) {
+		err = sc.GetHandle(ctx, id).Update(ctx, temporalsdk_client.ScheduleUpdateOptions{
+			DoUpdate: func(in temporalsdk_client.ScheduleUpdateInput) (*temporalsdk_client.ScheduleUpdate, error) {
+				s := in.Description.Schedule
+				s.Spec = &spec
+				s.Action = action
+				return &temporalsdk_client.ScheduleUpdate{Schedule: &s}, nil
+			},
+		})
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to create or update schedule: %v", err)
+	}
+
+	return true, nil
+}
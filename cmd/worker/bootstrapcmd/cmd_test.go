@@ -0,0 +1,248 @@
+package bootstrapcmd_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/mock"
+	temporalapi_enums "go.temporal.io/api/enums/v1"
+	temporalapi_operatorservice "go.temporal.io/api/operatorservice/v1"
+	temporalapi_serviceerror "go.temporal.io/api/serviceerror"
+	temporalsdk_client "go.temporal.io/sdk/client"
+	temporalsdk_mocks "go.temporal.io/sdk/mocks"
+	temporalsdk_temporal "go.temporal.io/sdk/temporal"
+	"google.golang.org/grpc"
+	"gotest.tools/v3/assert"
+
+	"github.com/artefactual-sdps/preprocessing-moma/cmd/worker/bootstrapcmd"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/workflow"
+)
+
+const (
+	namespace  = "default"
+	scheduleID = "preprocessing-housekeeping:preprocessing"
+)
+
+// fakeOperatorService is an operator service with the custom search
+// attributes of the namespace in memory.
+type fakeOperatorService struct {
+	temporalapi_operatorservice.OperatorServiceClient
+
+	attrs   map[string]temporalapi_enums.IndexedValueType
+	listErr error
+	added   *temporalapi_operatorservice.AddSearchAttributesRequest
+}
+
+func (s *fakeOperatorService) ListSearchAttributes(
+	ctx context.Context,
+	req *temporalapi_operatorservice.ListSearchAttributesRequest,
+	opts ...grpc.CallOption,
+) (*temporalapi_operatorservice.ListSearchAttributesResponse, error) {
+	if s.listErr != nil {
+		return nil, s.listErr
+	}
+	if req.GetNamespace() != namespace {
+		return nil, errors.New("unexpected namespace")
+	}
+
+	return &temporalapi_operatorservice.ListSearchAttributesResponse{
+		CustomAttributes: s.attrs,
+	}, nil
+}
+
+func (s *fakeOperatorService) AddSearchAttributes(
+	ctx context.Context,
+	req *temporalapi_operatorservice.AddSearchAttributesRequest,
+	opts ...grpc.CallOption,
+) (*temporalapi_operatorservice.AddSearchAttributesResponse, error) {
+	s.added = req
+	for name, t := range req.GetSearchAttributes() {
+		s.attrs[name] = t
+	}
+
+	return &temporalapi_operatorservice.AddSearchAttributesResponse{}, nil
+}
+
+// registered returns the workflow search attributes, except the skipped ones.
+func registered(skip ...string) map[string]temporalapi_enums.IndexedValueType {
+	attrs := map[string]temporalapi_enums.IndexedValueType{}
+	for _, key := range workflow.SearchAttributes {
+		attrs[key.GetName()] = key.GetValueType()
+	}
+	for _, name := range skip {
+		delete(attrs, name)
+	}
+
+	return attrs
+}
+
+// newClient returns a client using svc and, if it's not nil, sc.
+func newClient(t *testing.T, svc *fakeOperatorService, sc *temporalsdk_mocks.ScheduleClient) *temporalsdk_mocks.Client {
+	c := temporalsdk_mocks.NewClient(t)
+	c.On("OperatorService").Return(svc)
+	if sc != nil {
+		c.On("ScheduleClient").Return(sc)
+	}
+
+	return c
+}
+
+// unscheduled returns a schedule client without the housekeeping schedule.
+func unscheduled(t *testing.T) *temporalsdk_mocks.ScheduleClient {
+	h := temporalsdk_mocks.NewScheduleHandle(t)
+	h.On("Delete", mock.Anything).Return(temporalapi_serviceerror.NewNotFound("schedule not found"))
+	sc := temporalsdk_mocks.NewScheduleClient(t)
+	sc.On("GetHandle", mock.Anything, scheduleID).Return(h)
+
+	return sc
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Configuration{
+		Temporal: config.Temporal{Namespace: namespace, TaskQueue: "preprocessing"},
+	}
+
+	t.Run("Registers the missing search attributes", func(t *testing.T) {
+		t.Parallel()
+
+		svc := &fakeOperatorService{
+			attrs: registered(workflow.OutcomeAttr.GetName(), workflow.FileCountAttr.GetName()),
+		}
+
+		var stdout bytes.Buffer
+		m := bootstrapcmd.NewMain(logr.Discard(), cfg, &stdout)
+		m.SetClient(newClient(t, svc, unscheduled(t)))
+
+		err := m.Run(context.Background())
+		assert.NilError(t, err)
+		assert.Equal(t, stdout.String(), `Search attribute registered: PreprocessingFileCount
+Search attribute registered: PreprocessingOutcome
+Housekeeping not scheduled, the trash retention is zero.
+`)
+		assert.Equal(t, svc.added.GetNamespace(), namespace)
+		assert.DeepEqual(t, svc.added.GetSearchAttributes(), map[string]temporalapi_enums.IndexedValueType{
+			"PreprocessingFileCount": temporalapi_enums.INDEXED_VALUE_TYPE_INT,
+			"PreprocessingOutcome":   temporalapi_enums.INDEXED_VALUE_TYPE_KEYWORD,
+		})
+		assert.DeepEqual(t, svc.attrs, registered())
+	})
+
+	t.Run("Does nothing if the search attributes are registered", func(t *testing.T) {
+		t.Parallel()
+
+		svc := &fakeOperatorService{attrs: registered()}
+
+		var stdout bytes.Buffer
+		m := bootstrapcmd.NewMain(logr.Discard(), cfg, &stdout)
+		m.SetClient(newClient(t, svc, unscheduled(t)))
+
+		err := m.Run(context.Background())
+		assert.NilError(t, err)
+		assert.Equal(t, stdout.String(), `Search attributes already registered.
+Housekeeping not scheduled, the trash retention is zero.
+`)
+		assert.Assert(t, svc.added == nil)
+	})
+
+	t.Run("Schedules the housekeeping workflow", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := cfg
+		cfg.Trash = config.TrashConfig{Retention: 24 * time.Hour, Schedule: "0 3 * * *"}
+
+		sc := temporalsdk_mocks.NewScheduleClient(t)
+		sc.On("Create", mock.Anything, mock.MatchedBy(func(opts temporalsdk_client.ScheduleOptions) bool {
+			action := opts.Action.(*temporalsdk_client.ScheduleWorkflowAction)
+			return opts.ID == scheduleID &&
+				slices.Equal(opts.Spec.CronExpressions, []string{"0 3 * * *"}) &&
+				action.Workflow == workflow.HousekeepingWorkflowName &&
+				action.TaskQueue == "preprocessing"
+		})).Return(nil, nil)
+
+		var stdout bytes.Buffer
+		m := bootstrapcmd.NewMain(logr.Discard(), cfg, &stdout)
+		m.SetClient(newClient(t, &fakeOperatorService{attrs: registered()}, sc))
+
+		err := m.Run(context.Background())
+		assert.NilError(t, err)
+		assert.Equal(t, stdout.String(), `Search attributes already registered.
+Housekeeping scheduled: preprocessing-housekeeping:preprocessing
+`)
+	})
+
+	t.Run("Updates the housekeeping schedule", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := cfg
+		cfg.Trash = config.TrashConfig{Retention: 24 * time.Hour, Schedule: "0 3 * * *"}
+
+		h := temporalsdk_mocks.NewScheduleHandle(t)
+		h.On("Update", mock.Anything, mock.Anything).Return(nil)
+		sc := temporalsdk_mocks.NewScheduleClient(t)
+		sc.On("Create", mock.Anything, mock.Anything).Return(nil, temporalsdk_temporal.ErrScheduleAlreadyRunning)
+		sc.On("GetHandle", mock.Anything, scheduleID).Return(h)
+
+		var stdout bytes.Buffer
+		m := bootstrapcmd.NewMain(logr.Discard(), cfg, &stdout)
+		m.SetClient(newClient(t, &fakeOperatorService{attrs: registered()}, sc))
+
+		err := m.Run(context.Background())
+		assert.NilError(t, err)
+	})
+
+	t.Run("Fails if the housekeeping can't be scheduled", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := cfg
+		cfg.Trash = config.TrashConfig{Retention: 24 * time.Hour, Schedule: "0 3 * * *"}
+
+		sc := temporalsdk_mocks.NewScheduleClient(t)
+		sc.On("Create", mock.Anything, mock.Anything).Return(nil, errors.New("server unavailable"))
+
+		var stdout bytes.Buffer
+		m := bootstrapcmd.NewMain(logr.Discard(), cfg, &stdout)
+		m.SetClient(newClient(t, &fakeOperatorService{attrs: registered()}, sc))
+
+		err := m.Run(context.Background())
+		assert.Error(t, err, "unable to create or update schedule: server unavailable")
+	})
+
+	t.Run("Fails if a search attribute has a different type", func(t *testing.T) {
+		t.Parallel()
+
+		attrs := registered()
+		attrs["PreprocessingFileCount"] = temporalapi_enums.INDEXED_VALUE_TYPE_KEYWORD
+		svc := &fakeOperatorService{attrs: attrs}
+
+		var stdout bytes.Buffer
+		m := bootstrapcmd.NewMain(logr.Discard(), cfg, &stdout)
+		m.SetClient(newClient(t, svc, nil))
+
+		err := m.Run(context.Background())
+		assert.Error(t, err, "search attribute PreprocessingFileCount is registered with type Keyword, expected Int")
+		assert.Equal(t, stdout.Len(), 0)
+		assert.Assert(t, svc.added == nil)
+	})
+
+	t.Run("Fails if the search attributes can't be listed", func(t *testing.T) {
+		t.Parallel()
+
+		svc := &fakeOperatorService{listErr: errors.New("namespace not found")}
+
+		var stdout bytes.Buffer
+		m := bootstrapcmd.NewMain(logr.Discard(), cfg, &stdout)
+		m.SetClient(newClient(t, svc, nil))
+
+		err := m.Run(context.Background())
+		assert.Error(t, err, "unable to list search attributes: namespace not found")
+		assert.Equal(t, stdout.Len(), 0)
+	})
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/spf13/pflag"
+
+	"github.com/artefactual-sdps/preprocessing-moma/cmd/worker/configcmd"
+)
+
+// validateConfigCmd validates the configuration and prints the effective
+// values, e.g.:
+//
+//	preprocessing-moma-worker validate-config [--config <file>] [--json]
+func validateConfigCmd(_ context.Context, args []string) error {
+	p := pflag.NewFlagSet(configcmd.ValidateName, pflag.ExitOnError)
+	p.String("config", "", "Configuration file")
+	p.Bool("json", false, "Print the output in JSON format")
+	_ = p.Parse(args)
+
+	configFile, _ := p.GetString("config")
+	asJSON, _ := p.GetBool("json")
+
+	return configcmd.NewMain(os.Stdout, asJSON).Validate(configFile)
+}
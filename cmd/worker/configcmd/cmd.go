@@ -0,0 +1,127 @@
+// Package configcmd validates the worker configuration and prints the
+// effective values.
+package configcmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+)
+
+const ValidateName = "validate-config"
+
+// Report is the JSON output of the validate-config command.
+type Report struct {
+	ConfigFile string
+	Valid      bool
+	Errors     []string `json:",omitempty"`
+	Settings   []config.Setting
+}
+
+type Main struct {
+	stdout io.Writer
+	json   bool
+}
+
+// NewMain returns a Main printing to stdout, in JSON format if asJSON is true or
+// in a human readable format otherwise.
+func NewMain(stdout io.Writer, asJSON bool) *Main {
+	return &Main{
+		stdout: stdout,
+		json:   asJSON,
+	}
+}
+
+// Validate loads the configuration from configFile (or the default paths),
+// validates it and prints the effective configuration with the source of each
+// value. It returns an error if the configuration can't be loaded or it's not
+// valid.
+func (m *Main) Validate(configFile string) error {
+	r, err := config.Resolve(configFile)
+	if err != nil {
+		return fmt.Errorf("Failed to read configuration: %v", err)
+	}
+
+	report := Report{
+		ConfigFile: r.ConfigFileUsed,
+		Valid:      true,
+		Settings:   r.Settings,
+	}
+	verr := r.Config.Validate()
+	if len(r.UnknownKeys) > 0 {
+		verr = errors.Join(
+			fmt.Errorf("unknown configuration keys: %s", strings.Join(r.UnknownKeys, ", ")),
+			verr,
+		)
+	}
+	if verr != nil {
+		report.Valid = false
+		report.Errors = unjoin(verr)
+	}
+
+	if m.json {
+		enc := json.NewEncoder(m.stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else if err := m.print(report); err != nil {
+		return err
+	}
+
+	if verr != nil {
+		return errors.New("invalid configuration")
+	}
+
+	return nil
+}
+
+func (m *Main) print(r Report) error {
+	file := r.ConfigFile
+	if file == "" {
+		file = "not found"
+	}
+	fmt.Fprintf(m.stdout, "Configuration file: %s\n\n", file)
+
+	w := tabwriter.NewWriter(m.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, s := range r.Settings {
+		source := string(s.Source)
+		if s.Source == config.SourceEnv {
+			source = fmt.Sprintf("%s (%s)", s.Source, s.Env)
+		}
+		fmt.Fprintf(w, "%s\t%v\t%s\n", s.Key, s.Value, source)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if r.Valid {
+		fmt.Fprintln(m.stdout, "\nConfiguration is valid.")
+	} else {
+		fmt.Fprintln(m.stdout, "\nInvalid configuration:")
+		for _, e := range r.Errors {
+			fmt.Fprintf(m.stdout, "  %s\n", e)
+		}
+	}
+
+	return nil
+}
+
+// unjoin returns the messages of the errors joined in err.
+func unjoin(err error) []string {
+	if u, ok := err.(interface{ Unwrap() []error }); ok {
+		var msgs []string
+		for _, e := range u.Unwrap() {
+			msgs = append(msgs, unjoin(e)...)
+		}
+		return msgs
+	}
+
+	return []string{err.Error()}
+}
@@ -0,0 +1,123 @@
+package configcmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+
+	"github.com/artefactual-sdps/preprocessing-moma/cmd/worker/configcmd"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+)
+
+const validConfig = `# Config
+sharedPath = "/home/preprocessing/shared"
+[temporal]
+taskQueue = "preprocessing"
+workflowName = "preprocessing"
+`
+
+const invalidConfig = `# Config
+sharedPath = "/home/preprocessing/shared"
+unknownKey = "value"
+[temporal]
+taskQueue = "preprocessing"
+workflowName = "preprocessing"
+[worker]
+maxConcurrentSessions = -1
+`
+
+// settings returns the report settings indexed by key.
+func settings(r configcmd.Report) map[string]config.Setting {
+	m := map[string]config.Setting{}
+	for _, s := range r.Settings {
+		m[s.Key] = s
+	}
+
+	return m
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Reports a valid configuration", func(t *testing.T) {
+		t.Parallel()
+
+		dir := fs.NewDir(t, "", fs.WithFile("preprocessing.toml", validConfig))
+
+		var stdout bytes.Buffer
+		err := configcmd.NewMain(&stdout, true).Validate(dir.Join("preprocessing.toml"))
+		assert.NilError(t, err)
+
+		var r configcmd.Report
+		assert.NilError(t, json.Unmarshal(stdout.Bytes(), &r))
+		assert.Equal(t, r.ConfigFile, dir.Join("preprocessing.toml"))
+		assert.Equal(t, r.Valid, true)
+		assert.Equal(t, len(r.Errors), 0)
+
+		s := settings(r)
+		assert.DeepEqual(t, s["SharedPath"], config.Setting{
+			Key:    "SharedPath",
+			Value:  "/home/preprocessing/shared",
+			Source: config.SourceFile,
+			Env:    "ENDURO_PREPROCESSING_SHAREDPATH",
+		})
+		assert.DeepEqual(t, s["Report.Format"], config.Setting{
+			Key:    "Report.Format",
+			Value:  "html",
+			Source: config.SourceDefault,
+			Env:    "ENDURO_PREPROCESSING_REPORT_FORMAT",
+		})
+	})
+
+	t.Run("Reports the unknown keys and the validation errors", func(t *testing.T) {
+		t.Parallel()
+
+		dir := fs.NewDir(t, "", fs.WithFile("preprocessing.toml", invalidConfig))
+
+		var stdout bytes.Buffer
+		err := configcmd.NewMain(&stdout, true).Validate(dir.Join("preprocessing.toml"))
+		assert.Error(t, err, "invalid configuration")
+
+		var r configcmd.Report
+		assert.NilError(t, json.Unmarshal(stdout.Bytes(), &r))
+		assert.Equal(t, r.Valid, false)
+		assert.DeepEqual(t, r.Errors, []string{
+			"unknown configuration keys: unknownkey",
+			"Worker.MaxConcurrentSessions: -1 is less than the minimum value (1)",
+		})
+	})
+
+	t.Run("Prints a human readable report", func(t *testing.T) {
+		t.Parallel()
+
+		dir := fs.NewDir(t, "", fs.WithFile("preprocessing.toml", invalidConfig))
+
+		var stdout bytes.Buffer
+		err := configcmd.NewMain(&stdout, false).Validate(dir.Join("preprocessing.toml"))
+		assert.Error(t, err, "invalid configuration")
+
+		out := stdout.String()
+		assert.Assert(t, strings.HasPrefix(out, "Configuration file: "+dir.Join("preprocessing.toml")+"\n"), out)
+		assert.Assert(t, strings.Contains(out, "\nSharedPath "), out)
+		assert.Assert(t, strings.HasSuffix(out, `
+Invalid configuration:
+  unknown configuration keys: unknownkey
+  Worker.MaxConcurrentSessions: -1 is less than the minimum value (1)
+`), out)
+	})
+
+	t.Run("Fails if the configuration file is missing", func(t *testing.T) {
+		t.Parallel()
+
+		dir := fs.NewDir(t, "")
+
+		var stdout bytes.Buffer
+		err := configcmd.NewMain(&stdout, true).Validate(dir.Join("missing.toml"))
+		assert.Error(t, err, "Failed to read configuration: configuration file not found: "+dir.Join("missing.toml"))
+		assert.Equal(t, stdout.Len(), 0)
+	})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"sync/atomic"
+
+	"github.com/go-logr/logr"
+)
+
+// maxVerbosity is the verbosity of the base logger, the messages are filtered
+// by levelSink using the configured verbosity.
+const maxVerbosity = 127
+
+// levelSink is a logr.LogSink discarding the messages above a verbosity level
+// that can be changed while the worker is running.
+type levelSink struct {
+	logr.LogSink
+	level *atomic.Int32
+}
+
+var _ logr.CallDepthLogSink = levelSink{}
+
+// withLevel returns a logger logging the messages of logger up to the level
+// verbosity.
+func withLevel(logger logr.Logger, level *atomic.Int32) logr.Logger {
+	return logr.New(levelSink{LogSink: logger.GetSink(), level: level})
+}
+
+func (s levelSink) Enabled(level int) bool {
+	return level <= int(s.level.Load()) && s.LogSink.Enabled(level)
+}
+
+func (s levelSink) WithValues(keysAndValues ...any) logr.LogSink {
+	return levelSink{LogSink: s.LogSink.WithValues(keysAndValues...), level: s.level}
+}
+
+func (s levelSink) WithName(name string) logr.LogSink {
+	return levelSink{LogSink: s.LogSink.WithName(name), level: s.level}
+}
+
+func (s levelSink) WithCallDepth(depth int) logr.LogSink {
+	if cd, ok := s.LogSink.(logr.CallDepthLogSink); ok {
+		return levelSink{LogSink: cd.WithCallDepth(depth), level: s.level}
+	}
+
+	return s
+}
@@ -7,21 +7,67 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
 
 	"github.com/spf13/pflag"
 	"go.artefactual.dev/tools/log"
 
+	"github.com/artefactual-sdps/preprocessing-moma/cmd/worker/bootstrapcmd"
+	"github.com/artefactual-sdps/preprocessing-moma/cmd/worker/configcmd"
+	"github.com/artefactual-sdps/preprocessing-moma/cmd/worker/runcmd"
+	"github.com/artefactual-sdps/preprocessing-moma/cmd/worker/trashcmd"
 	"github.com/artefactual-sdps/preprocessing-moma/cmd/worker/workercmd"
+	"github.com/artefactual-sdps/preprocessing-moma/cmd/worker/workflowcmd"
 	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
 	"github.com/artefactual-sdps/preprocessing-moma/internal/version"
 )
 
 const appName = "preprocessing-moma-worker"
 
+// subcommands maps the subcommand names to their entry points, the worker is
+// started when no subcommand is given.
+var subcommands = map[string]func(ctx context.Context, args []string) error{
+	bootstrapcmd.Name:      bootstrapCmd,
+	configcmd.ValidateName: validateConfigCmd,
+	runcmd.Name:            runCmd,
+	trashcmd.TrashName:     trashCmd,
+	trashcmd.RestoreName:   restoreCmd,
+	trashcmd.PurgeName:     purgeCmd,
+	workflowcmd.SubmitName: submitCmd,
+	workflowcmd.StatusName: statusCmd,
+	workflowcmd.ReviewName: reviewCmd,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			err := cmd(ctx, os.Args[2:])
+			stop()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+	}
+
 	p := pflag.NewFlagSet(workercmd.Name, pflag.ExitOnError)
 	p.String("config", "", "Configuration file")
 	p.Bool("version", false, "Show version information")
+	p.Bool("warn-unknown-keys", false, "Log unknown configuration keys instead of failing")
+	p.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags]\n       %s <command> [flags]\n\n", appName, appName)
+		names := make([]string, 0, len(subcommands))
+		for name := range subcommands {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		fmt.Fprintf(os.Stderr, "Commands:\n  %s\n\nFlags:\n", strings.Join(names, "\n  "))
+		p.PrintDefaults()
+	}
 	if err := p.Parse(os.Args[1:]); err == flag.ErrHelp {
 		os.Exit(1)
 	} else if err != nil {
@@ -36,18 +82,28 @@ func main() {
 
 	var cfg config.Configuration
 	configFile, _ := p.GetString("config")
-	configFileFound, configFileUsed, err := config.Read(&cfg, configFile)
+	var opts []config.ReadOption
+	var unknownKeys []string
+	warnUnknownKeys, _ := p.GetBool("warn-unknown-keys")
+	if warnUnknownKeys {
+		opts = append(opts, config.WarnUnknownKeys(&unknownKeys))
+	}
+	configFileFound, configFileUsed, err := config.Read(&cfg, configFile, opts...)
 	if err != nil {
 		fmt.Printf("Failed to read configuration: %v\n", err)
 		os.Exit(1)
 	}
 
-	logger := log.New(os.Stderr,
+	// The verbosity can be changed by reloading the configuration.
+	level := &atomic.Int32{}
+	level.Store(int32(cfg.Verbosity))
+	baseLogger := log.New(os.Stderr,
 		log.WithName(workercmd.Name),
 		log.WithDebug(cfg.Debug),
-		log.WithLevel(cfg.Verbosity),
+		log.WithLevel(maxVerbosity),
 	)
-	defer log.Sync(logger)
+	defer log.Sync(baseLogger)
+	logger := withLevel(baseLogger, level)
 
 	keys := []interface{}{
 		"version", version.Long,
@@ -59,18 +115,31 @@ func main() {
 	}
 	logger.Info("Starting...", keys...)
 
+	watcher := config.NewWatcher(logger.WithName("config"), cfg, configFileUsed, warnUnknownKeys)
 	if configFileFound {
-		logger.Info("Configuration file loaded.", "path", configFileUsed)
+		logger.Info("Configuration file loaded.", "path", configFileUsed, "version", watcher.Version())
 	} else {
 		logger.Info("Configuration file not found.")
 	}
+	if len(unknownKeys) > 0 {
+		logger.Info("Unknown configuration keys ignored.", "keys", unknownKeys)
+	}
+
+	if configFileFound {
+		watcher.OnReload(func(cfg config.Configuration) {
+			level.Store(int32(cfg.Verbosity))
+		})
+		if err := watcher.Watch(); err != nil {
+			logger.Error(err, "Unable to watch the configuration file.")
+		}
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
 	go func() { <-c; cancel() }()
 
-	m := workercmd.NewMain(logger, cfg)
+	m := workercmd.NewMain(logger, watcher)
 
 	if err := m.Run(ctx); err != nil {
 		_ = m.Close()
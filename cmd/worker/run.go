@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"go.artefactual.dev/tools/log"
+
+	"github.com/artefactual-sdps/preprocessing-moma/cmd/worker/runcmd"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+)
+
+// runCmd preprocesses a local directory without a Temporal server, e.g.:
+//
+//	preprocessing-moma-worker run --path <dir>
+func runCmd(ctx context.Context, args []string) error {
+	p := pflag.NewFlagSet(runcmd.Name, pflag.ExitOnError)
+	p.String("config", "", "Configuration file (optional)")
+	p.String("path", "", "Directory to preprocess (required)")
+	p.String("workspace", "", "Directory of the results, staging and journal (default: a temporary directory removed on exit)")
+	_ = p.Parse(args)
+
+	// The configuration file is optional, the defaults and environment
+	// variables are used when no file is found in the default paths. The
+	// Temporal configuration isn't required to run the workflow in-process, so
+	// the configuration isn't validated.
+	configFile, _ := p.GetString("config")
+	r, err := config.Resolve(configFile)
+	if err != nil {
+		return fmt.Errorf("Failed to read configuration: %v", err)
+	}
+	if len(r.UnknownKeys) > 0 {
+		return fmt.Errorf("Failed to read configuration: unknown configuration keys: %s",
+			strings.Join(r.UnknownKeys, ", "))
+	}
+	cfg := r.Config
+
+	logger := log.New(os.Stderr,
+		log.WithName(runcmd.Name),
+		log.WithDebug(cfg.Debug),
+		log.WithLevel(cfg.Verbosity),
+	)
+	defer log.Sync(logger)
+
+	path, _ := p.GetString("path")
+	workspace, _ := p.GetString("workspace")
+
+	return runcmd.NewMain(logger, cfg, os.Stdout, workspace).Run(ctx, path)
+}
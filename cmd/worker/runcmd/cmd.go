@@ -0,0 +1,174 @@
+package runcmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"go.artefactual.dev/tools/temporal"
+	temporalsdk_activity "go.temporal.io/sdk/activity"
+	temporalsdk_converter "go.temporal.io/sdk/converter"
+	temporalsdk_testsuite "go.temporal.io/sdk/testsuite"
+	temporalsdk_worker "go.temporal.io/sdk/worker"
+	temporalsdk_workflow "go.temporal.io/sdk/workflow"
+
+	"github.com/artefactual-sdps/preprocessing-moma/cmd/worker/workercmd"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/journal"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/results"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/workflow"
+)
+
+const Name = "run"
+
+// idleTimeout is the maximum time the in-process workflow can be blocked
+// waiting for an activity to complete.
+const idleTimeout = 7 * 24 * time.Hour
+
+// Main runs the preprocessing workflow in-process against a local directory,
+// using the Temporal test environment instead of a Temporal server.
+type Main struct {
+	logger logr.Logger
+	cfg    config.Configuration
+	stdout io.Writer
+
+	// workspace is the directory of the results, staging and journal
+	// directories not set in cfg. A temporary workspace is used, and removed
+	// on exit, when it's empty.
+	workspace string
+}
+
+func NewMain(logger logr.Logger, cfg config.Configuration, stdout io.Writer, workspace string) *Main {
+	return &Main{
+		logger:    logger,
+		cfg:       cfg,
+		stdout:    stdout,
+		workspace: workspace,
+	}
+}
+
+// Run preprocesses the directory at path and prints the workflow result as
+// JSON. It returns an error if the workflow fails or rejects the SIP, the
+// result printed then holds the findings of the completed checks.
+func (m *Main) Run(ctx context.Context, path string) error {
+	if path == "" {
+		return fmt.Errorf("missing required path")
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("invalid path: %v", err)
+	}
+
+	ws := m.workspace
+	if ws == "" {
+		// The temporary workspace is created next to path, so the staged copy
+		// and the trash are on the same filesystem as the SIP.
+		ws, err = os.MkdirTemp(filepath.Dir(path), ".preprocessing-run-")
+		if err != nil {
+			return fmt.Errorf("create workspace: %v", err)
+		}
+		defer func() {
+			if err := os.RemoveAll(ws); err != nil {
+				m.logger.Error(err, "Unable to remove workspace.", "path", ws)
+			}
+		}()
+	}
+
+	// Run the workflow with the parent directory of path as the shared path,
+	// and the other directories in the workspace.
+	cfg := m.cfg
+	cfg.SharedPath = filepath.Dir(path)
+	cfg.Worker.ActivityTaskQueue = ""
+	if cfg.ResultsPath == "" {
+		cfg.ResultsPath = filepath.Join(ws, "results")
+	}
+	if cfg.StagingPath == "" {
+		cfg.StagingPath = filepath.Join(ws, "staging")
+	}
+	if cfg.JournalPath == "" {
+		cfg.JournalPath = filepath.Join(ws, "journal")
+	}
+
+	var ts temporalsdk_testsuite.WorkflowTestSuite
+	ts.SetLogger(temporal.Logger(m.logger.WithName("temporal")))
+
+	env := ts.NewTestWorkflowEnvironment()
+	env.SetTestTimeout(idleTimeout)
+	env.SetWorkerOptions(temporalsdk_worker.Options{EnableSessionWorker: true})
+	env.RegisterWorkflowWithOptions(
+		workflow.LockWorkflow,
+		temporalsdk_workflow.RegisterOptions{Name: workflow.LockWorkflowName},
+	)
+	workercmd.RegisterActivities(
+		env,
+		results.NewStore(cfg.ResultsDir()),
+		cfg.StagingDir(),
+		journal.NewStore(cfg.JournalDir()),
+		nil,
+	)
+
+	// The test environment can't cancel the workflow before it's started, so
+	// ctx is only watched once the first activity is started.
+	var watch sync.Once
+	done := make(chan struct{})
+	defer close(done)
+	env.SetOnActivityStartedListener(func(*temporalsdk_activity.Info, context.Context, temporalsdk_converter.EncodedValues) {
+		watch.Do(func() {
+			go func() {
+				select {
+				case <-ctx.Done():
+					env.CancelWorkflow()
+				case <-done:
+				}
+			}()
+		})
+	})
+
+	m.logger.V(1).Info("Running preprocessing workflow.", "path", path)
+	env.ExecuteWorkflow(
+		workflow.NewPreprocessingWorkflow(cfg).Execute,
+		&workflow.PreprocessingWorkflowParams{RelativePath: filepath.Base(path)},
+	)
+
+	if wfErr := env.GetWorkflowError(); wfErr != nil {
+		// Print the findings of the checks completed before the failure.
+		result := workflow.PreprocessingWorkflowResult{RelativePath: filepath.Base(path)}
+		if v, err := env.QueryWorkflow(workflow.ProgressQuery); err == nil {
+			var progress workflow.Progress
+			if err := v.Get(&progress); err == nil {
+				result.ConfigVersion = progress.ConfigVersion
+				result.Findings = progress.Findings
+			}
+		}
+		if err := m.print(result); err != nil {
+			return err
+		}
+
+		return fmt.Errorf("preprocessing workflow failed: %v", wfErr)
+	}
+
+	var result workflow.PreprocessingWorkflowResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		return fmt.Errorf("invalid workflow result: %v", err)
+	}
+
+	return m.print(result)
+}
+
+// print writes result as indented JSON to stdout.
+func (m *Main) print(result workflow.PreprocessingWorkflowResult) error {
+	enc := json.NewEncoder(m.stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		return fmt.Errorf("print workflow result: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package runcmd_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+
+	"github.com/artefactual-sdps/preprocessing-moma/cmd/worker/runcmd"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/workflow"
+)
+
+func testConfig() config.Configuration {
+	return config.Configuration{
+		UnwantedFiles: config.UnwantedFilesConfig{
+			Names: []string{".DS_Store"},
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Preprocesses a local directory", func(t *testing.T) {
+		t.Parallel()
+
+		dir := fs.NewDir(t, "",
+			fs.WithDir("sip",
+				fs.WithFile(".DS_Store", ""),
+				fs.WithDir("objects",
+					fs.WithFile("a.txt", "a"),
+				),
+			),
+		)
+
+		var stdout bytes.Buffer
+		m := runcmd.NewMain(logr.Discard(), testConfig(), &stdout, "")
+		err := m.Run(context.Background(), dir.Join("sip"))
+		assert.NilError(t, err)
+
+		var result workflow.PreprocessingWorkflowResult
+		assert.NilError(t, json.Unmarshal(stdout.Bytes(), &result))
+		assert.Equal(t, result.RelativePath, "sip")
+		assert.Assert(t, result.Removed != nil)
+		assert.Assert(t, fs.Equal(dir.Join("sip"), fs.Expected(t,
+			fs.WithDir("objects",
+				fs.WithFile("a.txt", "a"),
+			),
+			fs.MatchAnyFileMode,
+			fs.MatchExtraFiles,
+		)))
+	})
+
+	t.Run("Removes the temporary workspace", func(t *testing.T) {
+		t.Parallel()
+
+		dir := fs.NewDir(t, "",
+			fs.WithDir("sip",
+				fs.WithFile(".DS_Store", ""),
+			),
+		)
+
+		var stdout bytes.Buffer
+		m := runcmd.NewMain(logr.Discard(), testConfig(), &stdout, "")
+		err := m.Run(context.Background(), dir.Join("sip"))
+		assert.NilError(t, err)
+
+		entries, err := os.ReadDir(dir.Path())
+		assert.NilError(t, err)
+		assert.Equal(t, len(entries), 1)
+		assert.Equal(t, entries[0].Name(), "sip")
+	})
+
+	t.Run("Keeps the results in the given workspace", func(t *testing.T) {
+		t.Parallel()
+
+		dir := fs.NewDir(t, "",
+			fs.WithDir("sip",
+				fs.WithFile(".DS_Store", ""),
+			),
+		)
+		workspace := t.TempDir()
+
+		var stdout bytes.Buffer
+		m := runcmd.NewMain(logr.Discard(), testConfig(), &stdout, workspace)
+		err := m.Run(context.Background(), dir.Join("sip"))
+		assert.NilError(t, err)
+
+		var result workflow.PreprocessingWorkflowResult
+		assert.NilError(t, json.Unmarshal(stdout.Bytes(), &result))
+		assert.Assert(t, result.Removed != nil)
+		_, err = os.Stat(filepath.Join(workspace, "results", result.Removed.Path))
+		assert.NilError(t, err)
+	})
+
+	t.Run("Prints the findings of a rejected SIP", func(t *testing.T) {
+		t.Parallel()
+
+		dir := fs.NewDir(t, "",
+			fs.WithDir("sip",
+				fs.WithFile(".DS_Store", ""),
+			),
+		)
+		cfg := testConfig()
+		cfg.Validation.BlockingSeverity = "info"
+
+		var stdout bytes.Buffer
+		m := runcmd.NewMain(logr.Discard(), cfg, &stdout, "")
+		err := m.Run(context.Background(), dir.Join("sip"))
+		assert.ErrorContains(t, err, "preprocessing workflow failed")
+		assert.ErrorContains(t, err, "validation failed")
+
+		var result workflow.PreprocessingWorkflowResult
+		assert.NilError(t, json.Unmarshal(stdout.Bytes(), &result))
+		assert.Equal(t, result.RelativePath, "sip")
+		assert.Equal(t, len(result.Findings), 1)
+		assert.Equal(t, result.Findings[0].Code, "unwanted-files-removed")
+	})
+
+	t.Run("Cancels the workflow", func(t *testing.T) {
+		t.Parallel()
+
+		dir := fs.NewDir(t, "",
+			fs.WithDir("sip",
+				fs.WithFile(".DS_Store", ""),
+			),
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var stdout bytes.Buffer
+		m := runcmd.NewMain(logr.Discard(), testConfig(), &stdout, "")
+		err := m.Run(ctx, dir.Join("sip"))
+		assert.ErrorContains(t, err, "preprocessing workflow failed")
+		assert.ErrorContains(t, err, "canceled")
+
+		var result workflow.PreprocessingWorkflowResult
+		assert.NilError(t, json.Unmarshal(stdout.Bytes(), &result))
+		assert.Equal(t, result.RelativePath, "sip")
+	})
+
+	t.Run("Fails without a path", func(t *testing.T) {
+		t.Parallel()
+
+		m := runcmd.NewMain(logr.Discard(), testConfig(), &bytes.Buffer{}, "")
+		err := m.Run(context.Background(), "")
+		assert.Error(t, err, "missing required path")
+	})
+}
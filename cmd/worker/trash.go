@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	"go.artefactual.dev/tools/log"
+
+	"github.com/artefactual-sdps/preprocessing-moma/cmd/worker/trashcmd"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+)
+
+// trashCmd lists the workflow runs with removed paths in their trash, or the
+// removed paths of a workflow run, e.g.:
+//
+//	preprocessing-moma-worker trash [--id <workflow-id> --run-id <run-id>]
+func trashCmd(_ context.Context, args []string) error {
+	p := pflag.NewFlagSet(trashcmd.TrashName, pflag.ExitOnError)
+	p.String("config", "", "Configuration file")
+	p.String("id", "", "Workflow ID, list the runs with a trash if empty")
+	p.String("run-id", "", "Run ID (required with --id)")
+	p.Bool("json", false, "Print the output in JSON format")
+	_ = p.Parse(args)
+
+	m, err := newTrashMain(trashcmd.TrashName, p)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	id, _ := p.GetString("id")
+	if id == "" {
+		return m.List()
+	}
+
+	runID, _ := p.GetString("run-id")
+	if runID == "" {
+		return errors.New("--run-id is required with --id")
+	}
+
+	return m.Show(id, runID)
+}
+
+// restoreCmd moves the removed paths of a workflow run back from its trash,
+// e.g.:
+//
+//	preprocessing-moma-worker restore --id <workflow-id> --run-id <run-id> [--path <path>]...
+func restoreCmd(_ context.Context, args []string) error {
+	p := pflag.NewFlagSet(trashcmd.RestoreName, pflag.ExitOnError)
+	p.String("config", "", "Configuration file")
+	p.String("id", "", "Workflow ID (required)")
+	p.String("run-id", "", "Run ID (required)")
+	p.StringArray("path", nil, "Absolute path to restore, with its content (optional, all the removed paths by default)")
+	p.Bool("json", false, "Print the output in JSON format")
+	_ = p.Parse(args)
+
+	m, err := newTrashMain(trashcmd.RestoreName, p)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	id, _ := p.GetString("id")
+	runID, _ := p.GetString("run-id")
+	paths, _ := p.GetStringArray("path")
+
+	return m.Restore(id, runID, paths)
+}
+
+// purgeCmd deletes the trash of the workflow runs older than the retention, or
+// the configured trash retention, except the runs still running, e.g.:
+//
+//	preprocessing-moma-worker purge [--retention <duration>]
+func purgeCmd(ctx context.Context, args []string) error {
+	p := pflag.NewFlagSet(trashcmd.PurgeName, pflag.ExitOnError)
+	p.String("config", "", "Configuration file")
+	p.Duration("retention", 0, "Minimum age of the trash purged (optional, the trash retention by default)")
+	p.Bool("json", false, "Print the output in JSON format")
+	_ = p.Parse(args)
+
+	m, err := newTrashMain(trashcmd.PurgeName, p)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	retention, _ := p.GetDuration("retention")
+
+	return m.Purge(ctx, retention)
+}
+
+func newTrashMain(name string, p *pflag.FlagSet) (*trashcmd.Main, error) {
+	var cfg config.Configuration
+	configFile, _ := p.GetString("config")
+	if _, _, err := config.Read(&cfg, configFile); err != nil {
+		return nil, fmt.Errorf("Failed to read configuration: %v", err)
+	}
+
+	logger := log.New(os.Stderr,
+		log.WithName(name),
+		log.WithDebug(cfg.Debug),
+		log.WithLevel(cfg.Verbosity),
+	)
+	asJSON, _ := p.GetBool("json")
+
+	return trashcmd.NewMain(logger, cfg, os.Stdout, asJSON), nil
+}
@@ -0,0 +1,252 @@
+// Package trashcmd lists, restores and purges the paths removed by the
+// preprocessing workflow runs, kept in the trash of their journal.
+package trashcmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-logr/logr"
+	"go.artefactual.dev/tools/temporal"
+	temporalsdk_client "go.temporal.io/sdk/client"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/activities"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/journal"
+)
+
+const (
+	TrashName   = "trash"
+	RestoreName = "restore"
+	PurgeName   = "purge"
+)
+
+// Run describes the trash of a workflow run.
+type Run struct {
+	WorkflowID string
+	RunID      string
+	ModTime    time.Time
+
+	// Items is the number of removed paths in the trash.
+	Items int
+}
+
+// Item is a removed path in the trash of a workflow run.
+type Item struct {
+	Path    string
+	Trash   string
+	Removed time.Time
+}
+
+// RestoreResult is the JSON output of the restore command.
+type RestoreResult struct {
+	WorkflowID string
+	RunID      string
+	Restored   int
+}
+
+// PurgeResult is the JSON output of the purge command.
+type PurgeResult struct {
+	// Purged are the workflow runs whose trash was purged.
+	Purged []journal.Run
+
+	// Running are the workflow runs older than the retention whose trash was
+	// kept, because they are still running.
+	Running []journal.Run
+}
+
+type Main struct {
+	logger         logr.Logger
+	cfg            config.Configuration
+	journals       *journal.Store
+	stdout         io.Writer
+	json           bool
+	temporalClient temporalsdk_client.Client
+}
+
+// NewMain returns a Main using the journals of the cfg configuration and
+// printing to stdout, in JSON format if asJSON is true or in a human readable
+// format otherwise.
+func NewMain(logger logr.Logger, cfg config.Configuration, stdout io.Writer, asJSON bool) *Main {
+	return &Main{
+		logger:   logger,
+		cfg:      cfg,
+		journals: journal.NewStore(cfg.JournalDir()),
+		stdout:   stdout,
+		json:     asJSON,
+	}
+}
+
+func (m *Main) dial() (temporalsdk_client.Client, error) {
+	if m.temporalClient != nil {
+		return m.temporalClient, nil
+	}
+
+	c, err := temporalsdk_client.Dial(temporalsdk_client.Options{
+		HostPort:  m.cfg.Temporal.Address,
+		Namespace: m.cfg.Temporal.Namespace,
+		Logger:    temporal.Logger(m.logger.WithName("temporal")),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create Temporal client: %v", err)
+	}
+	m.temporalClient = c
+
+	return c, nil
+}
+
+// List prints the workflow runs with a trash, oldest first.
+func (m *Main) List() error {
+	jruns, err := m.journals.Runs()
+	if err != nil {
+		return err
+	}
+
+	runs := make([]Run, 0, len(jruns))
+	for _, r := range jruns {
+		trashed, err := m.trashed(r.WorkflowID, r.RunID)
+		if err != nil {
+			return err
+		}
+		runs = append(runs, Run{
+			WorkflowID: r.WorkflowID,
+			RunID:      r.RunID,
+			ModTime:    r.ModTime,
+			Items:      len(trashed),
+		})
+	}
+
+	if m.json {
+		return m.encode(runs)
+	}
+
+	w := tabwriter.NewWriter(m.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WORKFLOW ID\tRUN ID\tMODIFIED\tITEMS")
+	for _, r := range runs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", r.WorkflowID, r.RunID, r.ModTime.Format(time.RFC3339), r.Items)
+	}
+
+	return w.Flush()
+}
+
+// Show prints the removed paths in the trash of the workflow run.
+func (m *Main) Show(workflowID, runID string) error {
+	trashed, err := m.trashed(workflowID, runID)
+	if err != nil {
+		return err
+	}
+
+	items := make([]Item, 0, len(trashed))
+	for _, e := range trashed {
+		items = append(items, Item{Path: e.Path, Trash: e.Target, Removed: e.Time})
+	}
+
+	if m.json {
+		return m.encode(items)
+	}
+
+	w := tabwriter.NewWriter(m.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tREMOVED\tTRASH")
+	for _, i := range items {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", i.Path, i.Removed.Format(time.RFC3339), i.Trash)
+	}
+
+	return w.Flush()
+}
+
+// Restore moves the removed paths of the workflow run back from its trash, or
+// only the removed paths equal to or in one of paths if it isn't empty.
+func (m *Main) Restore(workflowID, runID string, paths []string) error {
+	if workflowID == "" || runID == "" {
+		return errors.New("--id and --run-id are required")
+	}
+
+	j, err := m.journals.Open(workflowID, runID)
+	if err != nil {
+		return err
+	}
+	if len(j.Entries()) == 0 {
+		return fmt.Errorf("no trash found for workflow %q run %q", workflowID, runID)
+	}
+
+	n, err := j.Restore(paths...)
+	if err != nil {
+		return err
+	}
+
+	if m.json {
+		return m.encode(RestoreResult{WorkflowID: workflowID, RunID: runID, Restored: n})
+	}
+	fmt.Fprintf(m.stdout, "%d paths restored.\n", n)
+
+	return nil
+}
+
+// Purge deletes the trash of the workflow runs whose last path was removed
+// more than retention ago, or Trash.Retention if retention is zero, like the
+// HousekeepingWorkflow. The trash of the runs still running is kept.
+func (m *Main) Purge(ctx context.Context, retention time.Duration) error {
+	if retention == 0 {
+		retention = m.cfg.Trash.Retention
+	}
+	if retention <= 0 {
+		return fmt.Errorf("invalid retention %s", retention)
+	}
+
+	c, err := m.dial()
+	if err != nil {
+		return err
+	}
+
+	res := PurgeResult{Purged: []journal.Run{}, Running: []journal.Run{}}
+	purged, err := m.journals.Purge(time.Now().Add(-retention), func(r journal.Run) (bool, error) {
+		running, err := activities.IsRunning(ctx, c, r.WorkflowID, r.RunID)
+		if running {
+			res.Running = append(res.Running, r)
+		}
+		return running, err
+	})
+	res.Purged = append(res.Purged, purged...)
+	if err != nil {
+		return err
+	}
+
+	if m.json {
+		return m.encode(res)
+	}
+	for _, r := range res.Running {
+		fmt.Fprintf(m.stdout, "Trash kept, workflow %q run %q is running.\n", r.WorkflowID, r.RunID)
+	}
+	fmt.Fprintf(m.stdout, "%d runs purged.\n", len(res.Purged))
+
+	return nil
+}
+
+func (m *Main) Close() error {
+	if m.temporalClient != nil {
+		m.temporalClient.Close()
+	}
+
+	return nil
+}
+
+func (m *Main) trashed(workflowID, runID string) ([]journal.Entry, error) {
+	j, err := m.journals.Open(workflowID, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	return j.Trashed()
+}
+
+func (m *Main) encode(v any) error {
+	enc := json.NewEncoder(m.stdout)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
@@ -0,0 +1,273 @@
+package trashcmd_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/mock"
+	temporalapi_enums "go.temporal.io/api/enums/v1"
+	temporalapi_serviceerror "go.temporal.io/api/serviceerror"
+	temporalapi_workflow "go.temporal.io/api/workflow/v1"
+	temporalapi_workflowservice "go.temporal.io/api/workflowservice/v1"
+	temporalsdk_mocks "go.temporal.io/sdk/mocks"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+
+	"github.com/artefactual-sdps/preprocessing-moma/cmd/worker/trashcmd"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/journal"
+)
+
+// trashEnv is a shared path with a SIP and the journals of its workflow runs.
+type trashEnv struct {
+	cfg      config.Configuration
+	journals *journal.Store
+}
+
+func newTrashEnv(t *testing.T) *trashEnv {
+	t.Helper()
+
+	shared := fs.NewDir(t, "",
+		fs.WithDir("sip",
+			fs.WithFile(".DS_Store", ""),
+			fs.WithFile("a.txt", "a"),
+			fs.WithDir("z", fs.WithFile("Thumbs.db", "")),
+		),
+	)
+	cfg := config.Configuration{
+		SharedPath: shared.Path(),
+		Trash:      config.TrashConfig{Retention: 24 * time.Hour},
+	}
+
+	return &trashEnv{
+		cfg:      cfg,
+		journals: journal.NewStore(cfg.JournalDir()),
+	}
+}
+
+// remove removes the paths of the SIP in the journal of the workflow run, and
+// records them age ago.
+func (env *trashEnv) remove(t *testing.T, workflowID string, age time.Duration, paths ...string) {
+	t.Helper()
+
+	j, err := env.journals.Open(workflowID, "rid")
+	assert.NilError(t, err)
+	for _, p := range paths {
+		assert.NilError(t, j.Remove(filepath.Join(env.cfg.SharedPath, "sip", p)))
+	}
+
+	mtime := time.Now().Add(-age)
+	assert.NilError(t, os.Chtimes(filepath.Join(j.Dir(), journal.FileName), mtime, mtime))
+}
+
+func (env *trashEnv) runs(t *testing.T) []string {
+	t.Helper()
+
+	runs, err := env.journals.Runs()
+	assert.NilError(t, err)
+
+	ids := make([]string, 0, len(runs))
+	for _, r := range runs {
+		ids = append(ids, r.WorkflowID)
+	}
+
+	return ids
+}
+
+func describeResponse(s temporalapi_enums.WorkflowExecutionStatus) *temporalapi_workflowservice.DescribeWorkflowExecutionResponse {
+	return &temporalapi_workflowservice.DescribeWorkflowExecutionResponse{
+		WorkflowExecutionInfo: &temporalapi_workflow.WorkflowExecutionInfo{Status: s},
+	}
+}
+
+func TestRestore(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Restores the removed paths", func(t *testing.T) {
+		t.Parallel()
+
+		env := newTrashEnv(t)
+		env.remove(t, "wid", 0, ".DS_Store", "z/Thumbs.db")
+
+		var stdout bytes.Buffer
+		err := trashcmd.NewMain(logr.Discard(), env.cfg, &stdout, false).Restore("wid", "rid", nil)
+		assert.NilError(t, err)
+		assert.Equal(t, stdout.String(), "2 paths restored.\n")
+		assert.Assert(t, fs.Equal(filepath.Join(env.cfg.SharedPath, "sip"), fs.Expected(t,
+			fs.WithFile(".DS_Store", ""),
+			fs.WithFile("a.txt", "a"),
+			fs.WithDir("z", fs.WithFile("Thumbs.db", ""), fs.MatchAnyFileMode),
+			fs.MatchAnyFileMode,
+		)))
+	})
+
+	t.Run("Restores the given paths", func(t *testing.T) {
+		t.Parallel()
+
+		env := newTrashEnv(t)
+		env.remove(t, "wid", 0, ".DS_Store", "z")
+
+		var stdout bytes.Buffer
+		err := trashcmd.NewMain(logr.Discard(), env.cfg, &stdout, true).Restore("wid", "rid", []string{
+			filepath.Join(env.cfg.SharedPath, "sip", "z"),
+		})
+		assert.NilError(t, err)
+
+		var res trashcmd.RestoreResult
+		assert.NilError(t, json.Unmarshal(stdout.Bytes(), &res))
+		assert.DeepEqual(t, res, trashcmd.RestoreResult{WorkflowID: "wid", RunID: "rid", Restored: 1})
+		assert.Assert(t, fs.Equal(filepath.Join(env.cfg.SharedPath, "sip"), fs.Expected(t,
+			fs.WithFile("a.txt", "a"),
+			fs.WithDir("z", fs.WithFile("Thumbs.db", ""), fs.MatchAnyFileMode),
+			fs.MatchAnyFileMode,
+		)))
+	})
+
+	t.Run("Fails if the run has no trash", func(t *testing.T) {
+		t.Parallel()
+
+		env := newTrashEnv(t)
+
+		var stdout bytes.Buffer
+		err := trashcmd.NewMain(logr.Discard(), env.cfg, &stdout, false).Restore("wid", "rid", nil)
+		assert.Error(t, err, `no trash found for workflow "wid" run "rid"`)
+		assert.Equal(t, stdout.Len(), 0)
+	})
+
+	t.Run("Fails if a removed path exists again", func(t *testing.T) {
+		t.Parallel()
+
+		env := newTrashEnv(t)
+		env.remove(t, "wid", 0, "a.txt")
+		assert.NilError(t, os.WriteFile(filepath.Join(env.cfg.SharedPath, "sip", "a.txt"), []byte("new"), 0o600))
+
+		var stdout bytes.Buffer
+		err := trashcmd.NewMain(logr.Discard(), env.cfg, &stdout, false).Restore("wid", "rid", nil)
+		assert.ErrorContains(t, err, "file already exists")
+		assert.Equal(t, stdout.Len(), 0)
+	})
+}
+
+func TestPurge(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Purges the trash older than the retention", func(t *testing.T) {
+		t.Parallel()
+
+		env := newTrashEnv(t)
+		env.remove(t, "old", 48*time.Hour, ".DS_Store")
+		env.remove(t, "recent", 12*time.Hour, "z/Thumbs.db")
+
+		c := temporalsdk_mocks.NewClient(t)
+		c.On("DescribeWorkflowExecution", mock.Anything, "old", "rid").Return(
+			describeResponse(temporalapi_enums.WORKFLOW_EXECUTION_STATUS_COMPLETED), nil,
+		)
+
+		var stdout bytes.Buffer
+		m := trashcmd.NewMain(logr.Discard(), env.cfg, &stdout, false)
+		m.SetClient(c)
+
+		err := m.Purge(context.Background(), 0)
+		assert.NilError(t, err)
+		assert.Equal(t, stdout.String(), "1 runs purged.\n")
+		assert.DeepEqual(t, env.runs(t), []string{"recent"})
+	})
+
+	t.Run("Purges the trash older than the given retention", func(t *testing.T) {
+		t.Parallel()
+
+		env := newTrashEnv(t)
+		env.remove(t, "old", 48*time.Hour, ".DS_Store")
+		env.remove(t, "recent", 12*time.Hour, "z/Thumbs.db")
+
+		// The run was deleted by the retention of the namespace.
+		c := temporalsdk_mocks.NewClient(t)
+		c.On("DescribeWorkflowExecution", mock.Anything, mock.Anything, "rid").Return(
+			nil, temporalapi_serviceerror.NewNotFound("workflow not found"),
+		)
+
+		var stdout bytes.Buffer
+		m := trashcmd.NewMain(logr.Discard(), env.cfg, &stdout, true)
+		m.SetClient(c)
+
+		err := m.Purge(context.Background(), 6*time.Hour)
+		assert.NilError(t, err)
+
+		var res trashcmd.PurgeResult
+		assert.NilError(t, json.Unmarshal(stdout.Bytes(), &res))
+		assert.Equal(t, len(res.Purged), 2)
+		assert.Equal(t, len(res.Running), 0)
+		assert.Equal(t, len(env.runs(t)), 0)
+	})
+
+	t.Run("Keeps the trash of the running workflows", func(t *testing.T) {
+		t.Parallel()
+
+		env := newTrashEnv(t)
+		env.remove(t, "running", 72*time.Hour, "a.txt")
+		env.remove(t, "old", 48*time.Hour, ".DS_Store")
+
+		c := temporalsdk_mocks.NewClient(t)
+		c.On("DescribeWorkflowExecution", mock.Anything, "running", "rid").Return(
+			describeResponse(temporalapi_enums.WORKFLOW_EXECUTION_STATUS_RUNNING), nil,
+		)
+		c.On("DescribeWorkflowExecution", mock.Anything, "old", "rid").Return(
+			describeResponse(temporalapi_enums.WORKFLOW_EXECUTION_STATUS_FAILED), nil,
+		)
+
+		var stdout bytes.Buffer
+		m := trashcmd.NewMain(logr.Discard(), env.cfg, &stdout, false)
+		m.SetClient(c)
+
+		err := m.Purge(context.Background(), 0)
+		assert.NilError(t, err)
+		assert.Equal(t, stdout.String(), `Trash kept, workflow "running" run "rid" is running.
+1 runs purged.
+`)
+		assert.DeepEqual(t, env.runs(t), []string{"running"})
+
+		// The trash of the running workflow can still be restored.
+		stdout.Reset()
+		err = m.Restore("running", "rid", nil)
+		assert.NilError(t, err)
+		assert.Equal(t, stdout.String(), "1 paths restored.\n")
+	})
+
+	t.Run("Keeps the trash if the workflow status is unknown", func(t *testing.T) {
+		t.Parallel()
+
+		env := newTrashEnv(t)
+		env.remove(t, "old", 48*time.Hour, ".DS_Store")
+
+		c := temporalsdk_mocks.NewClient(t)
+		c.On("DescribeWorkflowExecution", mock.Anything, "old", "rid").Return(
+			nil, temporalapi_serviceerror.NewUnavailable("server unavailable"),
+		)
+
+		var stdout bytes.Buffer
+		m := trashcmd.NewMain(logr.Discard(), env.cfg, &stdout, false)
+		m.SetClient(c)
+
+		err := m.Purge(context.Background(), 0)
+		assert.Error(t, err, "journal: describe workflow old run rid: server unavailable")
+		assert.Equal(t, stdout.Len(), 0)
+		assert.DeepEqual(t, env.runs(t), []string{"old"})
+	})
+
+	t.Run("Fails without a retention", func(t *testing.T) {
+		t.Parallel()
+
+		env := newTrashEnv(t)
+		env.cfg.Trash.Retention = 0
+
+		var stdout bytes.Buffer
+		err := trashcmd.NewMain(logr.Discard(), env.cfg, &stdout, false).Purge(context.Background(), 0)
+		assert.Error(t, err, "invalid retention 0s")
+	})
+}
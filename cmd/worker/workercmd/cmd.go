@@ -2,8 +2,11 @@ package workercmd
 
 import (
 	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
 
-	"github.com/artefactual-sdps/temporal-activities/removefiles"
 	"github.com/go-logr/logr"
 	"go.artefactual.dev/tools/temporal"
 	temporalsdk_activity "go.temporal.io/sdk/activity"
@@ -12,7 +15,11 @@ import (
 	temporalsdk_worker "go.temporal.io/sdk/worker"
 	temporalsdk_workflow "go.temporal.io/sdk/workflow"
 
+	"github.com/artefactual-sdps/preprocessing-moma/internal/activities"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/codec"
 	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/journal"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/results"
 	"github.com/artefactual-sdps/preprocessing-moma/internal/workflow"
 )
 
@@ -21,45 +28,64 @@ const Name = "preprocessing-worker"
 type Main struct {
 	logger         logr.Logger
 	cfg            config.Configuration
+	provider       config.Provider
 	temporalWorker temporalsdk_worker.Worker
+	activityWorker temporalsdk_worker.Worker
 	temporalClient temporalsdk_client.Client
+	codecServer    *http.Server
 }
 
-func NewMain(logger logr.Logger, cfg config.Configuration) *Main {
+// NewMain returns a Main using the cfg configuration, a config.Configuration
+// or a config.Watcher applying the configuration changes to the new workflow
+// executions.
+func NewMain(logger logr.Logger, cfg config.Provider) *Main {
 	return &Main{
-		logger: logger,
-		cfg:    cfg,
+		logger:   logger,
+		cfg:      cfg.Config(),
+		provider: cfg,
 	}
 }
 
 func (m *Main) Run(ctx context.Context) error {
-	c, err := temporalsdk_client.Dial(temporalsdk_client.Options{
+	pc, err := codec.New(m.cfg.Codec)
+	if err != nil {
+		m.logger.Error(err, "Unable to create payload codec.")
+		return err
+	}
+
+	c, err := temporalsdk_client.Dial(codec.ClientOptions(temporalsdk_client.Options{
 		HostPort:  m.cfg.Temporal.Address,
 		Namespace: m.cfg.Temporal.Namespace,
 		Logger:    temporal.Logger(m.logger.WithName("temporal")),
-	})
+	}, pc))
 	if err != nil {
 		m.logger.Error(err, "Unable to create Temporal client.")
 		return err
 	}
 	m.temporalClient = c
 
-	w := temporalsdk_worker.New(m.temporalClient, m.cfg.Temporal.TaskQueue, temporalsdk_worker.Options{
-		EnableSessionWorker:               true,
-		MaxConcurrentSessionExecutionSize: m.cfg.Worker.MaxConcurrentSessions,
-		Interceptors: []temporalsdk_interceptor.WorkerInterceptor{
-			temporal.NewLoggerInterceptor(m.logger.WithName("worker")),
-		},
-	})
+	if m.cfg.Worker.StickyWorkflowCacheSize > 0 {
+		temporalsdk_worker.SetStickyWorkflowCacheSize(m.cfg.Worker.StickyWorkflowCacheSize)
+	}
+
+	wc, ac := m.workerConfigs()
+	w := temporalsdk_worker.New(m.temporalClient, wc.taskQueue, wc.options)
 	m.temporalWorker = w
 
-	w.RegisterWorkflowWithOptions(
-		workflow.NewPreprocessingWorkflow(m.cfg.SharedPath).Execute,
-		temporalsdk_workflow.RegisterOptions{Name: m.cfg.Temporal.WorkflowName},
-	)
-	w.RegisterActivityWithOptions(
-		removefiles.NewActivity().Execute,
-		temporalsdk_activity.RegisterOptions{Name: removefiles.ActivityName},
+	m.registerWorkflows(w)
+
+	aw := w
+	if ac != nil {
+		aw = temporalsdk_worker.New(m.temporalClient, ac.taskQueue, ac.options)
+		m.activityWorker = aw
+	}
+
+	RegisterActivities(
+		aw,
+		results.NewStore(m.cfg.ResultsDir()),
+		m.cfg.StagingDir(),
+		journal.NewStore(m.cfg.JournalDir()),
+		m.temporalClient,
 	)
 
 	if err := w.Start(); err != nil {
@@ -67,10 +93,181 @@ func (m *Main) Run(ctx context.Context) error {
 		return err
 	}
 
+	if m.activityWorker != nil {
+		if err := m.activityWorker.Start(); err != nil {
+			m.logger.Error(err, "Activity worker failed to start or fatal error during its execution.")
+			return err
+		}
+	}
+
+	if pc != nil && m.cfg.Codec.Server.Address != "" {
+		if err := m.startCodecServer(pc); err != nil {
+			m.logger.Error(err, "Codec server failed to start.")
+			return err
+		}
+	}
+
+	return nil
+}
+
+// startCodecServer starts the codec server used by the Temporal UI and CLI to
+// decode the payloads encrypted by pc.
+func (m *Main) startCodecServer(pc *codec.AESCodec) error {
+	ln, err := net.Listen("tcp", m.cfg.Codec.Server.Address)
+	if err != nil {
+		return err
+	}
+
+	m.codecServer = &http.Server{
+		Handler:           codec.Handler(pc, m.cfg.Codec.Server),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := m.codecServer.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			m.logger.Error(err, "Codec server failed.")
+		}
+	}()
+	m.logger.V(1).Info("Codec server listening.", "address", ln.Addr().String())
+
 	return nil
 }
 
+// registerWorkflows registers the preprocessing workflows in r.
+func (m *Main) registerWorkflows(r temporalsdk_worker.WorkflowRegistry) {
+	r.RegisterWorkflowWithOptions(
+		workflow.NewPreprocessingWorkflow(m.provider).Execute,
+		temporalsdk_workflow.RegisterOptions{Name: m.cfg.Temporal.WorkflowName},
+	)
+	r.RegisterWorkflowWithOptions(
+		workflow.LockWorkflow,
+		temporalsdk_workflow.RegisterOptions{Name: workflow.LockWorkflowName},
+	)
+	r.RegisterWorkflowWithOptions(
+		workflow.NewHousekeepingWorkflow(m.cfg).Execute,
+		temporalsdk_workflow.RegisterOptions{Name: workflow.HousekeepingWorkflowName},
+	)
+}
+
+// RegisterActivities registers the preprocessing activities in r, a Temporal
+// worker or test environment, writing their large results to store, staging
+// the SIPs in the stagingDir directory and journaling the SIP mutations in
+// journals. The trash of the workflow runs reported as running by c isn't
+// purged, c can be nil if the HousekeepingWorkflow isn't run.
+func RegisterActivities(
+	r temporalsdk_worker.ActivityRegistry,
+	store *results.Store,
+	stagingDir string,
+	journals *journal.Store,
+	c temporalsdk_client.Client,
+) {
+	r.RegisterActivityWithOptions(
+		activities.NewRemoveFiles(store, journals).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.RemoveFilesName},
+	)
+	r.RegisterActivityWithOptions(
+		activities.NewRemoveFiles(store, journals).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.RemoveFilesLegacyName},
+	)
+	r.RegisterActivityWithOptions(
+		activities.NewCheckTimestamps(store).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.CheckTimestampsName},
+	)
+	r.RegisterActivityWithOptions(
+		activities.NewCaptureTimestamps(journals).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.CaptureTimestampsName},
+	)
+	r.RegisterActivityWithOptions(
+		activities.NewRestoreTimestamps().Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.RestoreTimestampsName},
+	)
+	r.RegisterActivityWithOptions(
+		activities.NewPreserveMacMetadata(journals).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.PreserveMacMetadataName},
+	)
+	r.RegisterActivityWithOptions(
+		activities.NewWriteReport(store, journals).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.WriteReportName},
+	)
+	r.RegisterActivityWithOptions(
+		activities.NewStage(stagingDir).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.StageName},
+	)
+	r.RegisterActivityWithOptions(
+		activities.NewPublishStage(stagingDir).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.PublishStageName},
+	)
+	r.RegisterActivityWithOptions(
+		activities.NewDiscardStage(stagingDir).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.DiscardStageName},
+	)
+	r.RegisterActivityWithOptions(
+		activities.NewRollbackJournal(journals).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.RollbackJournalName},
+	)
+	r.RegisterActivityWithOptions(
+		activities.NewDeleteJournal(journals).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.DeleteJournalName},
+	)
+	r.RegisterActivityWithOptions(
+		activities.NewPurgeTrash(journals, c).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.PurgeTrashName},
+	)
+}
+
+// workerConfig is the task queue and the options of a Temporal worker.
+type workerConfig struct {
+	taskQueue string
+	options   temporalsdk_worker.Options
+}
+
+// workerConfigs returns the configuration of the worker polling the workflow
+// tasks and, when a dedicated activity task queue is configured, of the worker
+// polling the activity tasks. Without a dedicated activity worker, the
+// workflow worker polls both.
+func (m *Main) workerConfigs() (workflowWorker workerConfig, activityWorker *workerConfig) {
+	dedicated := m.cfg.Worker.ActivityTaskQueue != ""
+
+	opts := m.workerOptions()
+	opts.LocalActivityWorkerOnly = dedicated
+	workflowWorker = workerConfig{taskQueue: m.cfg.Temporal.TaskQueue, options: opts}
+
+	if dedicated {
+		opts := m.workerOptions()
+		opts.DisableWorkflowWorker = true
+		activityWorker = &workerConfig{taskQueue: m.cfg.Worker.ActivityTaskQueue, options: opts}
+	}
+
+	return workflowWorker, activityWorker
+}
+
+func (m *Main) workerOptions() temporalsdk_worker.Options {
+	return temporalsdk_worker.Options{
+		EnableSessionWorker:                    true,
+		MaxConcurrentSessionExecutionSize:      m.cfg.Worker.MaxConcurrentSessions,
+		MaxConcurrentActivityExecutionSize:     m.cfg.Worker.MaxConcurrentActivityExecutionSize,
+		MaxConcurrentWorkflowTaskExecutionSize: m.cfg.Worker.MaxConcurrentWorkflowTaskExecutionSize,
+		MaxConcurrentActivityTaskPollers:       m.cfg.Worker.MaxConcurrentActivityTaskPollers,
+		MaxConcurrentWorkflowTaskPollers:       m.cfg.Worker.MaxConcurrentWorkflowTaskPollers,
+		WorkerActivitiesPerSecond:              m.cfg.Worker.WorkerActivitiesPerSecond,
+		TaskQueueActivitiesPerSecond:           m.cfg.Worker.TaskQueueActivitiesPerSecond,
+		Interceptors: []temporalsdk_interceptor.WorkerInterceptor{
+			temporal.NewLoggerInterceptor(m.logger.WithName("worker")),
+		},
+	}
+}
+
 func (m *Main) Close() error {
+	var err error
+	if m.codecServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err = m.codecServer.Shutdown(ctx)
+		cancel()
+	}
+
+	if m.activityWorker != nil {
+		m.activityWorker.Stop()
+	}
+
 	if m.temporalWorker != nil {
 		m.temporalWorker.Stop()
 	}
@@ -79,5 +276,5 @@ func (m *Main) Close() error {
 		m.temporalClient.Close()
 	}
 
-	return nil
+	return err
 }
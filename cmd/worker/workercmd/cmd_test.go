@@ -0,0 +1,186 @@
+package workercmd_test
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	temporalsdk_activity "go.temporal.io/sdk/activity"
+	temporalsdk_worker "go.temporal.io/sdk/worker"
+	temporalsdk_workflow "go.temporal.io/sdk/workflow"
+	"gotest.tools/v3/assert"
+
+	"github.com/artefactual-sdps/preprocessing-moma/cmd/worker/workercmd"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/activities"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/journal"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/results"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/workflow"
+)
+
+// registry records the names of the workflows and activities registered.
+type registry struct {
+	workflows  []string
+	activities []string
+}
+
+func (r *registry) RegisterWorkflow(w interface{}) {
+	panic("workflows must be registered with a name")
+}
+
+func (r *registry) RegisterWorkflowWithOptions(w interface{}, opts temporalsdk_workflow.RegisterOptions) {
+	r.workflows = append(r.workflows, opts.Name)
+}
+
+func (r *registry) RegisterActivity(a interface{}) {
+	panic("activities must be registered with a name")
+}
+
+func (r *registry) RegisterActivityWithOptions(a interface{}, opts temporalsdk_activity.RegisterOptions) {
+	r.activities = append(r.activities, opts.Name)
+}
+
+// options returns opts without the interceptors, which can't be compared.
+func options(t *testing.T, opts temporalsdk_worker.Options) temporalsdk_worker.Options {
+	t.Helper()
+
+	assert.Equal(t, len(opts.Interceptors), 1)
+	opts.Interceptors = nil
+
+	return opts
+}
+
+func TestWorkerConfigs(t *testing.T) {
+	t.Parallel()
+
+	tuned := config.WorkerConfig{
+		MaxConcurrentSessions:                  2,
+		MaxConcurrentActivityExecutionSize:     10,
+		MaxConcurrentWorkflowTaskExecutionSize: 20,
+		MaxConcurrentActivityTaskPollers:       3,
+		MaxConcurrentWorkflowTaskPollers:       4,
+		WorkerActivitiesPerSecond:              0.5,
+		TaskQueueActivitiesPerSecond:           5,
+	}
+	tunedOptions := temporalsdk_worker.Options{
+		EnableSessionWorker:                    true,
+		MaxConcurrentSessionExecutionSize:      2,
+		MaxConcurrentActivityExecutionSize:     10,
+		MaxConcurrentWorkflowTaskExecutionSize: 20,
+		MaxConcurrentActivityTaskPollers:       3,
+		MaxConcurrentWorkflowTaskPollers:       4,
+		WorkerActivitiesPerSecond:              0.5,
+		TaskQueueActivitiesPerSecond:           5,
+	}
+
+	type test struct {
+		name           string
+		cfg            config.WorkerConfig
+		workflowWorker workercmd.WorkerConfig
+		activityWorker *workercmd.WorkerConfig
+	}
+	for _, tc := range []test{
+		{
+			name: "Polls the workflow and activity tasks with a single worker",
+			cfg:  config.WorkerConfig{MaxConcurrentSessions: 1},
+			workflowWorker: workercmd.WorkerConfig{
+				TaskQueue: "preprocessing",
+				Options: temporalsdk_worker.Options{
+					EnableSessionWorker:               true,
+					MaxConcurrentSessionExecutionSize: 1,
+				},
+			},
+		},
+		{
+			name: "Maps the worker tuning options",
+			cfg:  tuned,
+			workflowWorker: workercmd.WorkerConfig{
+				TaskQueue: "preprocessing",
+				Options:   tunedOptions,
+			},
+		},
+		{
+			name: "Polls the activity tasks with a dedicated worker",
+			cfg: func() config.WorkerConfig {
+				cfg := tuned
+				cfg.ActivityTaskQueue = "preprocessing-activities"
+				return cfg
+			}(),
+			workflowWorker: workercmd.WorkerConfig{
+				TaskQueue: "preprocessing",
+				Options: func() temporalsdk_worker.Options {
+					opts := tunedOptions
+					opts.LocalActivityWorkerOnly = true
+					return opts
+				}(),
+			},
+			activityWorker: &workercmd.WorkerConfig{
+				TaskQueue: "preprocessing-activities",
+				Options: func() temporalsdk_worker.Options {
+					opts := tunedOptions
+					opts.DisableWorkflowWorker = true
+					return opts
+				}(),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			m := workercmd.NewMain(logr.Discard(), config.Configuration{
+				Temporal: config.Temporal{TaskQueue: "preprocessing"},
+				Worker:   tc.cfg,
+			})
+			wc, ac := m.WorkerConfigs()
+
+			wc.Options = options(t, wc.Options)
+			assert.DeepEqual(t, wc, tc.workflowWorker)
+
+			if tc.activityWorker == nil {
+				assert.Assert(t, ac == nil)
+				return
+			}
+			assert.Assert(t, ac != nil)
+			ac.Options = options(t, ac.Options)
+			assert.DeepEqual(t, *ac, *tc.activityWorker)
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	m := workercmd.NewMain(logr.Discard(), config.Configuration{
+		Temporal: config.Temporal{WorkflowName: "preprocessing"},
+	})
+
+	var r registry
+	m.RegisterWorkflows(&r)
+	workercmd.RegisterActivities(
+		&r,
+		results.NewStore(t.TempDir()),
+		t.TempDir(),
+		journal.NewStore(t.TempDir()),
+		nil,
+	)
+
+	assert.DeepEqual(t, r.workflows, []string{
+		"preprocessing",
+		workflow.LockWorkflowName,
+		workflow.HousekeepingWorkflowName,
+	})
+	assert.DeepEqual(t, r.activities, []string{
+		activities.RemoveFilesName,
+		activities.RemoveFilesLegacyName,
+		activities.CheckTimestampsName,
+		activities.CaptureTimestampsName,
+		activities.RestoreTimestampsName,
+		activities.PreserveMacMetadataName,
+		activities.WriteReportName,
+		activities.StageName,
+		activities.PublishStageName,
+		activities.DiscardStageName,
+		activities.RollbackJournalName,
+		activities.DeleteJournalName,
+		activities.PurgeTrashName,
+	})
+}
@@ -0,0 +1,26 @@
+package workercmd
+
+import temporalsdk_worker "go.temporal.io/sdk/worker"
+
+// WorkerConfig is the task queue and the options of a Temporal worker.
+type WorkerConfig struct {
+	TaskQueue string
+	Options   temporalsdk_worker.Options
+}
+
+// WorkerConfigs returns the configuration of the workflow worker and of the
+// dedicated activity worker, nil if it isn't configured.
+func (m *Main) WorkerConfigs() (WorkerConfig, *WorkerConfig) {
+	wc, ac := m.workerConfigs()
+	workflowWorker := WorkerConfig{TaskQueue: wc.taskQueue, Options: wc.options}
+	if ac == nil {
+		return workflowWorker, nil
+	}
+
+	return workflowWorker, &WorkerConfig{TaskQueue: ac.taskQueue, Options: ac.options}
+}
+
+// RegisterWorkflows registers the preprocessing workflows in r.
+func (m *Main) RegisterWorkflows(r temporalsdk_worker.WorkflowRegistry) {
+	m.registerWorkflows(r)
+}
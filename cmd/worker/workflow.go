@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	"go.artefactual.dev/tools/log"
+
+	"github.com/artefactual-sdps/preprocessing-moma/cmd/worker/workflowcmd"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/workflow"
+)
+
+// submitCmd starts a preprocessing workflow, e.g.:
+//
+//	preprocessing-moma-worker submit --relative-path <path> [--review] [--wait]
+func submitCmd(ctx context.Context, args []string) error {
+	p := pflag.NewFlagSet(workflowcmd.SubmitName, pflag.ExitOnError)
+	p.String("config", "", "Configuration file")
+	p.String("relative-path", "", "Transfer path, relative to the shared path (required)")
+	p.String("id", "", "Workflow ID (optional, generated by default)")
+	p.Bool("review", false, "Wait for a review decision before completing the workflow")
+	p.String("transfer-type", "", "Transfer type, recorded in the search attributes")
+	p.String("depositor", "", "Depositor, recorded in the search attributes")
+	p.Bool("wait", false, "Wait for the workflow to complete")
+	p.Bool("json", false, "Print the output in JSON format")
+	_ = p.Parse(args)
+
+	m, err := newWorkflowMain(workflowcmd.SubmitName, p)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	relPath, _ := p.GetString("relative-path")
+	id, _ := p.GetString("id")
+	review, _ := p.GetBool("review")
+	transferType, _ := p.GetString("transfer-type")
+	depositor, _ := p.GetString("depositor")
+	wait, _ := p.GetBool("wait")
+
+	return m.Submit(ctx, &workflow.PreprocessingWorkflowParams{
+		RelativePath: relPath,
+		Review:       review,
+		TransferType: transferType,
+		Depositor:    depositor,
+	}, id, wait)
+}
+
+// statusCmd shows the status of a preprocessing workflow, or lists the recent
+// executions when no workflow ID is given, e.g.:
+//
+//	preprocessing-moma-worker status [--id <workflow-id>]
+func statusCmd(ctx context.Context, args []string) error {
+	p := pflag.NewFlagSet(workflowcmd.StatusName, pflag.ExitOnError)
+	p.String("config", "", "Configuration file")
+	p.String("id", "", "Workflow ID, list the recent executions if empty")
+	p.String("run-id", "", "Run ID (optional, latest run by default)")
+	p.Int("limit", 20, "Maximum number of executions listed")
+	p.Bool("json", false, "Print the output in JSON format")
+	_ = p.Parse(args)
+
+	m, err := newWorkflowMain(workflowcmd.StatusName, p)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	id, _ := p.GetString("id")
+	if id == "" {
+		limit, _ := p.GetInt("limit")
+		return m.List(ctx, limit)
+	}
+
+	runID, _ := p.GetString("run-id")
+
+	return m.Status(ctx, id, runID)
+}
+
+// reviewCmd approves or rejects a preprocessing workflow waiting for review,
+// e.g.:
+//
+//	preprocessing-moma-worker review --id <workflow-id> --approve --reviewer <name>
+func reviewCmd(ctx context.Context, args []string) error {
+	p := pflag.NewFlagSet(workflowcmd.ReviewName, pflag.ExitOnError)
+	p.String("config", "", "Configuration file")
+	p.String("id", "", "Workflow ID (required)")
+	p.String("run-id", "", "Run ID (optional, latest run by default)")
+	p.Bool("approve", false, "Approve the SIP")
+	p.Bool("reject", false, "Reject the SIP")
+	p.String("reviewer", "", "Reviewer name")
+	p.String("note", "", "Reviewer note")
+	p.Bool("json", false, "Print the output in JSON format")
+	_ = p.Parse(args)
+
+	approve, _ := p.GetBool("approve")
+	reject, _ := p.GetBool("reject")
+	if approve == reject {
+		return errors.New("one of --approve or --reject is required")
+	}
+
+	m, err := newWorkflowMain(workflowcmd.ReviewName, p)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	id, _ := p.GetString("id")
+	runID, _ := p.GetString("run-id")
+	reviewer, _ := p.GetString("reviewer")
+	note, _ := p.GetString("note")
+
+	return m.Review(ctx, id, runID, workflow.ReviewDecision{
+		Approved: approve,
+		Reviewer: reviewer,
+		Note:     note,
+	})
+}
+
+func newWorkflowMain(name string, p *pflag.FlagSet) (*workflowcmd.Main, error) {
+	var cfg config.Configuration
+	configFile, _ := p.GetString("config")
+	if _, _, err := config.Read(&cfg, configFile); err != nil {
+		return nil, fmt.Errorf("Failed to read configuration: %v", err)
+	}
+
+	logger := log.New(os.Stderr,
+		log.WithName(name),
+		log.WithDebug(cfg.Debug),
+		log.WithLevel(cfg.Verbosity),
+	)
+	asJSON, _ := p.GetBool("json")
+
+	return workflowcmd.NewMain(logger, cfg, os.Stdout, asJSON), nil
+}
@@ -0,0 +1,462 @@
+// Package workflowcmd starts and inspects preprocessing workflow executions
+// using the Temporal configuration of the worker.
+package workflowcmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-logr/logr"
+	"go.artefactual.dev/tools/temporal"
+	temporalapi_enums "go.temporal.io/api/enums/v1"
+	temporalapi_workflow "go.temporal.io/api/workflow/v1"
+	temporalapi_workflowservice "go.temporal.io/api/workflowservice/v1"
+	temporalsdk_client "go.temporal.io/sdk/client"
+	temporalsdk_converter "go.temporal.io/sdk/converter"
+	temporalsdk_temporal "go.temporal.io/sdk/temporal"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/activities"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/codec"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/validation"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/workflow"
+)
+
+const (
+	SubmitName = "submit"
+	StatusName = "status"
+	ReviewName = "review"
+)
+
+// Execution describes a preprocessing workflow execution.
+type Execution struct {
+	WorkflowID string
+	RunID      string
+	Status     string
+	StartTime  *time.Time                            `json:",omitempty"`
+	CloseTime  *time.Time                            `json:",omitempty"`
+	Result     *workflow.PreprocessingWorkflowResult `json:",omitempty"`
+	Error      string                                `json:",omitempty"`
+
+	// Progress is the progress reported by a running execution.
+	Progress *workflow.Progress `json:",omitempty"`
+
+	// StepProgress is the progress recorded in the heartbeats of the running
+	// step activity.
+	StepProgress *activities.Progress `json:",omitempty"`
+
+	// Findings lists the findings of the preprocessing checks completed.
+	Findings validation.Findings `json:",omitempty"`
+}
+
+type Main struct {
+	logger         logr.Logger
+	cfg            config.Configuration
+	stdout         io.Writer
+	json           bool
+	temporalClient temporalsdk_client.Client
+}
+
+// NewMain returns a Main printing to stdout, in JSON format if asJSON is true or
+// in a human readable format otherwise.
+func NewMain(logger logr.Logger, cfg config.Configuration, stdout io.Writer, asJSON bool) *Main {
+	return &Main{
+		logger: logger,
+		cfg:    cfg,
+		stdout: stdout,
+		json:   asJSON,
+	}
+}
+
+func (m *Main) dial() (temporalsdk_client.Client, error) {
+	if m.temporalClient != nil {
+		return m.temporalClient, nil
+	}
+
+	opts, err := m.clientOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := temporalsdk_client.Dial(opts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create Temporal client: %v", err)
+	}
+	m.temporalClient = c
+
+	return c, nil
+}
+
+// clientOptions returns the options of the Temporal client. The workflow
+// inputs and results are encrypted with the worker keys.
+func (m *Main) clientOptions() (temporalsdk_client.Options, error) {
+	pc, err := codec.New(m.cfg.Codec)
+	if err != nil {
+		return temporalsdk_client.Options{}, err
+	}
+
+	return codec.ClientOptions(temporalsdk_client.Options{
+		HostPort:  m.cfg.Temporal.Address,
+		Namespace: m.cfg.Temporal.Namespace,
+		Logger:    temporal.Logger(m.logger.WithName("temporal")),
+	}, pc), nil
+}
+
+// dataConverter returns the data converter of the Temporal client, which
+// decodes the payloads not decoded by the client, e.g. the heartbeat details.
+func (m *Main) dataConverter() (temporalsdk_converter.DataConverter, error) {
+	opts, err := m.clientOptions()
+	if err != nil {
+		return nil, err
+	}
+	if opts.DataConverter == nil {
+		return temporalsdk_converter.GetDefaultDataConverter(), nil
+	}
+
+	return opts.DataConverter, nil
+}
+
+// Submit starts a preprocessing workflow with params, using workflowID if it's
+// not empty. If wait is true, it waits for the workflow to complete and prints
+// its result.
+func (m *Main) Submit(ctx context.Context, params *workflow.PreprocessingWorkflowParams, workflowID string, wait bool) error {
+	if params.RelativePath == "" {
+		return errors.New("missing required relative path")
+	}
+
+	c, err := m.dial()
+	if err != nil {
+		return err
+	}
+
+	run, err := c.ExecuteWorkflow(
+		ctx,
+		temporalsdk_client.StartWorkflowOptions{
+			ID:        workflowID,
+			TaskQueue: m.cfg.Temporal.TaskQueue,
+		},
+		m.cfg.Temporal.WorkflowName,
+		params,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to start workflow: %v", err)
+	}
+
+	exec := Execution{
+		WorkflowID: run.GetID(),
+		RunID:      run.GetRunID(),
+		Status:     temporalapi_enums.WORKFLOW_EXECUTION_STATUS_RUNNING.String(),
+	}
+	if wait {
+		var result workflow.PreprocessingWorkflowResult
+		if err := run.Get(ctx, &result); err != nil {
+			exec.Status = closeStatus(err).String()
+			exec.Error = err.Error()
+			exec.Findings = errorFindings(err)
+		} else {
+			exec.Status = temporalapi_enums.WORKFLOW_EXECUTION_STATUS_COMPLETED.String()
+			exec.Result = &result
+			exec.Findings = result.Findings
+		}
+	}
+
+	if err := m.print(exec); err != nil {
+		return err
+	}
+	if exec.Error != "" {
+		return errors.New("preprocessing workflow failed")
+	}
+
+	return nil
+}
+
+// Status prints the status of the workflowID execution, and its result once
+// it's closed. An empty runID selects the latest run.
+func (m *Main) Status(ctx context.Context, workflowID, runID string) error {
+	if workflowID == "" {
+		return errors.New("missing required workflow ID")
+	}
+
+	c, err := m.dial()
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.DescribeWorkflowExecution(ctx, workflowID, runID)
+	if err != nil {
+		return fmt.Errorf("unable to describe workflow: %v", err)
+	}
+
+	info := resp.GetWorkflowExecutionInfo()
+	exec := newExecution(info)
+
+	switch info.GetStatus() {
+	case temporalapi_enums.WORKFLOW_EXECUTION_STATUS_RUNNING:
+		// The progress is only reported when a worker answers the query.
+		value, err := c.QueryWorkflow(ctx, exec.WorkflowID, exec.RunID, workflow.ProgressQuery)
+		if err != nil {
+			m.logger.V(1).Info("Unable to query workflow progress.", "err", err)
+		} else {
+			var progress workflow.Progress
+			if err := value.Get(&progress); err != nil {
+				return fmt.Errorf("unable to decode workflow progress: %v", err)
+			}
+			exec.Progress = &progress
+			exec.Findings = progress.Findings
+		}
+		dc, err := m.dataConverter()
+		if err != nil {
+			return err
+		}
+		exec.StepProgress = stepProgress(dc, resp.GetPendingActivities())
+	case temporalapi_enums.WORKFLOW_EXECUTION_STATUS_COMPLETED:
+		var result workflow.PreprocessingWorkflowResult
+		run := c.GetWorkflow(ctx, exec.WorkflowID, exec.RunID)
+		if err := run.Get(ctx, &result); err != nil {
+			return fmt.Errorf("unable to get workflow result: %v", err)
+		}
+		exec.Result = &result
+		exec.Findings = result.Findings
+	default:
+		run := c.GetWorkflow(ctx, exec.WorkflowID, exec.RunID)
+		if err := run.Get(ctx, nil); err != nil {
+			exec.Error = err.Error()
+			exec.Findings = errorFindings(err)
+		}
+	}
+
+	return m.print(exec)
+}
+
+// Review sends the review decision to the workflowID execution waiting for
+// review. An empty runID selects the latest run.
+func (m *Main) Review(ctx context.Context, workflowID, runID string, decision workflow.ReviewDecision) error {
+	if workflowID == "" {
+		return errors.New("missing required workflow ID")
+	}
+
+	c, err := m.dial()
+	if err != nil {
+		return err
+	}
+
+	if err := c.SignalWorkflow(ctx, workflowID, runID, workflow.ReviewSignal, decision); err != nil {
+		return fmt.Errorf("unable to send review decision: %v", err)
+	}
+
+	return m.Status(ctx, workflowID, runID)
+}
+
+// List prints the most recent preprocessing workflow executions, up to limit.
+func (m *Main) List(ctx context.Context, limit int) error {
+	c, err := m.dial()
+	if err != nil {
+		return err
+	}
+
+	var execs []Execution
+	var token []byte
+	for len(execs) < limit {
+		resp, err := c.ListWorkflow(ctx, &temporalapi_workflowservice.ListWorkflowExecutionsRequest{
+			Namespace:     m.cfg.Temporal.Namespace,
+			PageSize:      int32(min(limit, 1000)),
+			NextPageToken: token,
+			Query:         fmt.Sprintf("WorkflowType = '%s'", m.cfg.Temporal.WorkflowName),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to list workflows: %v", err)
+		}
+
+		for _, info := range resp.GetExecutions() {
+			if len(execs) == limit {
+				break
+			}
+			execs = append(execs, newExecution(info))
+		}
+
+		token = resp.GetNextPageToken()
+		if len(token) == 0 {
+			break
+		}
+	}
+
+	if m.json {
+		return m.encode(execs)
+	}
+
+	w := tabwriter.NewWriter(m.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WORKFLOW ID\tRUN ID\tSTATUS\tSTART TIME\tCLOSE TIME")
+	for _, e := range execs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			e.WorkflowID, e.RunID, e.Status, formatTime(e.StartTime), formatTime(e.CloseTime),
+		)
+	}
+
+	return w.Flush()
+}
+
+func (m *Main) Close() error {
+	if m.temporalClient != nil {
+		m.temporalClient.Close()
+	}
+
+	return nil
+}
+
+func (m *Main) print(e Execution) error {
+	if m.json {
+		return m.encode(e)
+	}
+
+	w := tabwriter.NewWriter(m.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Workflow ID:\t%s\n", e.WorkflowID)
+	fmt.Fprintf(w, "Run ID:\t%s\n", e.RunID)
+	fmt.Fprintf(w, "Status:\t%s\n", e.Status)
+	if e.StartTime != nil {
+		fmt.Fprintf(w, "Start time:\t%s\n", formatTime(e.StartTime))
+	}
+	if e.CloseTime != nil {
+		fmt.Fprintf(w, "Close time:\t%s\n", formatTime(e.CloseTime))
+	}
+	if e.Result != nil {
+		fmt.Fprintf(w, "Relative path:\t%s\n", e.Result.RelativePath)
+		if e.Result.ConfigVersion != "" {
+			fmt.Fprintf(w, "Configuration version:\t%s\n", e.Result.ConfigVersion)
+		}
+		if r := e.Result.Review; r != nil {
+			fmt.Fprintf(w, "Review:\t%s\n", r.Status)
+			if r.Reviewer != "" {
+				fmt.Fprintf(w, "Reviewer:\t%s\n", r.Reviewer)
+			}
+			if r.Note != "" {
+				fmt.Fprintf(w, "Review note:\t%s\n", r.Note)
+			}
+		}
+		if ref := e.Result.Removed; ref != nil {
+			fmt.Fprintf(w, "Removed files:\t%s\n", filepath.Join(m.cfg.ResultsDir(), ref.Path))
+		}
+		if ref := e.Result.Inventory; ref != nil {
+			fmt.Fprintf(w, "Inventory:\t%s\n", filepath.Join(m.cfg.ResultsDir(), ref.Path))
+		}
+	}
+	if e.Error != "" {
+		fmt.Fprintf(w, "Error:\t%s\n", e.Error)
+	}
+	if p := e.Progress; p != nil {
+		if p.ConfigVersion != "" {
+			fmt.Fprintf(w, "Configuration version:\t%s\n", p.ConfigVersion)
+		}
+		for _, step := range p.Completed {
+			fmt.Fprintf(w, "Completed step:\t%s (%s, %d files)\n", step.Name, step.Duration, step.FileCount)
+		}
+		if p.Step != "" {
+			fmt.Fprintf(w, "Running step:\t%s (since %s)\n", p.Step, formatTime(p.StepStartTime))
+		}
+		fmt.Fprintf(w, "Files:\t%d\n", p.FileCount)
+	}
+	for _, f := range e.Findings {
+		fmt.Fprintf(w, "Finding:\t%s\n", f)
+	}
+	if p := e.StepProgress; p != nil {
+		fmt.Fprintf(w, "Step progress:\t%d entries processed, %d files, last path %q\n", p.Walked, p.Count, p.LastPath)
+	}
+
+	return w.Flush()
+}
+
+func (m *Main) encode(v any) error {
+	enc := json.NewEncoder(m.stdout)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
+
+func newExecution(info *temporalapi_workflow.WorkflowExecutionInfo) Execution {
+	e := Execution{
+		WorkflowID: info.GetExecution().GetWorkflowId(),
+		RunID:      info.GetExecution().GetRunId(),
+		Status:     info.GetStatus().String(),
+	}
+	if info.GetStartTime() != nil {
+		t := info.GetStartTime().AsTime()
+		e.StartTime = &t
+	}
+	if info.GetCloseTime() != nil {
+		t := info.GetCloseTime().AsTime()
+		e.CloseTime = &t
+	}
+
+	return e
+}
+
+// closeStatus returns the status of an execution closed with the err returned
+// by its run Get method, the close reason is the cause of the workflow
+// execution error.
+func closeStatus(err error) temporalapi_enums.WorkflowExecutionStatus {
+	cause := err
+	var execErr *temporalsdk_temporal.WorkflowExecutionError
+	if errors.As(err, &execErr) {
+		cause = execErr.Unwrap()
+	}
+
+	switch cause.(type) {
+	case *temporalsdk_temporal.CanceledError:
+		return temporalapi_enums.WORKFLOW_EXECUTION_STATUS_CANCELED
+	case *temporalsdk_temporal.TerminatedError:
+		return temporalapi_enums.WORKFLOW_EXECUTION_STATUS_TERMINATED
+	case *temporalsdk_temporal.TimeoutError:
+		return temporalapi_enums.WORKFLOW_EXECUTION_STATUS_TIMED_OUT
+	default:
+		return temporalapi_enums.WORKFLOW_EXECUTION_STATUS_FAILED
+	}
+}
+
+// errorFindings returns the findings recorded in a workflow validation error.
+func errorFindings(err error) validation.Findings {
+	var appErr *temporalsdk_temporal.ApplicationError
+	if !errors.As(err, &appErr) || appErr.Type() != workflow.ErrTypeValidation {
+		return nil
+	}
+
+	var findings validation.Findings
+	if err := appErr.Details(&findings); err != nil {
+		return nil
+	}
+
+	return findings
+}
+
+// stepProgress returns the progress recorded in the heartbeat details of the
+// pending activities, decoded with dc, or nil if there isn't any.
+func stepProgress(
+	dc temporalsdk_converter.DataConverter,
+	pending []*temporalapi_workflow.PendingActivityInfo,
+) *activities.Progress {
+	for _, pa := range pending {
+		if pa.GetHeartbeatDetails() == nil {
+			continue
+		}
+		var p activities.Progress
+		if err := dc.FromPayloads(pa.GetHeartbeatDetails(), &p); err != nil {
+			continue
+		}
+
+		return &p
+	}
+
+	return nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return t.Local().Format(time.RFC3339)
+}
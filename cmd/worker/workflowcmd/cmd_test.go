@@ -0,0 +1,426 @@
+package workflowcmd_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/mock"
+	temporalapi_common "go.temporal.io/api/common/v1"
+	temporalapi_enums "go.temporal.io/api/enums/v1"
+	temporalapi_serviceerror "go.temporal.io/api/serviceerror"
+	temporalapi_workflow "go.temporal.io/api/workflow/v1"
+	temporalapi_workflowservice "go.temporal.io/api/workflowservice/v1"
+	temporalsdk_client "go.temporal.io/sdk/client"
+	temporalsdk_converter "go.temporal.io/sdk/converter"
+	temporalsdk_mocks "go.temporal.io/sdk/mocks"
+	temporalsdk_temporal "go.temporal.io/sdk/temporal"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gotest.tools/v3/assert"
+
+	"github.com/artefactual-sdps/preprocessing-moma/cmd/worker/workflowcmd"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/activities"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/codec"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/validation"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/workflow"
+)
+
+const (
+	workflowID = "preprocessing-1"
+	runID      = "c1d2e3f4"
+)
+
+var startTime = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func testConfig() config.Configuration {
+	return config.Configuration{
+		SharedPath: "/shared",
+		Temporal: config.Temporal{
+			Namespace:    "default",
+			TaskQueue:    "preprocessing",
+			WorkflowName: "preprocessing",
+		},
+	}
+}
+
+// newMain returns a Main using a mock Temporal client and printing its JSON
+// output to stdout.
+func newMain(t *testing.T, c *temporalsdk_mocks.Client, stdout *bytes.Buffer) *workflowcmd.Main {
+	t.Helper()
+
+	m := workflowcmd.NewMain(logr.Discard(), testConfig(), stdout, true)
+	m.SetClient(c)
+
+	return m
+}
+
+func newRun(t *testing.T) *temporalsdk_mocks.WorkflowRun {
+	t.Helper()
+
+	run := temporalsdk_mocks.NewWorkflowRun(t)
+	run.On("GetID").Return(workflowID).Maybe()
+	run.On("GetRunID").Return(runID).Maybe()
+
+	return run
+}
+
+// setResult returns a mock Run function setting the result passed to the
+// WorkflowRun Get method.
+func setResult(result workflow.PreprocessingWorkflowResult) func(mock.Arguments) {
+	return func(args mock.Arguments) {
+		*args.Get(1).(*workflow.PreprocessingWorkflowResult) = result
+	}
+}
+
+func decode(t *testing.T, stdout *bytes.Buffer) workflowcmd.Execution {
+	t.Helper()
+
+	var exec workflowcmd.Execution
+	assert.NilError(t, json.Unmarshal(stdout.Bytes(), &exec))
+
+	return exec
+}
+
+func executionInfo(status temporalapi_enums.WorkflowExecutionStatus) *temporalapi_workflow.WorkflowExecutionInfo {
+	return &temporalapi_workflow.WorkflowExecutionInfo{
+		Execution: &temporalapi_common.WorkflowExecution{WorkflowId: workflowID, RunId: runID},
+		Status:    status,
+		StartTime: timestamppb.New(startTime),
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	t.Parallel()
+
+	params := &workflow.PreprocessingWorkflowParams{RelativePath: "transfer"}
+	opts := temporalsdk_client.StartWorkflowOptions{ID: workflowID, TaskQueue: "preprocessing"}
+
+	t.Run("Starts a workflow", func(t *testing.T) {
+		t.Parallel()
+
+		c := temporalsdk_mocks.NewClient(t)
+		c.On("ExecuteWorkflow", mock.Anything, opts, "preprocessing", params).Return(newRun(t), nil)
+
+		var stdout bytes.Buffer
+		err := newMain(t, c, &stdout).Submit(context.Background(), params, workflowID, false)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, decode(t, &stdout), workflowcmd.Execution{
+			WorkflowID: workflowID,
+			RunID:      runID,
+			Status:     "Running",
+		})
+	})
+
+	t.Run("Waits for the workflow result", func(t *testing.T) {
+		t.Parallel()
+
+		findings := validation.Findings{
+			{Severity: validation.Info, Code: "unwanted-files-removed", Message: "1 file removed"},
+		}
+		result := workflow.PreprocessingWorkflowResult{RelativePath: "transfer", Findings: findings}
+		run := newRun(t)
+		run.On("Get", mock.Anything, mock.Anything).Run(setResult(result)).Return(nil)
+		c := temporalsdk_mocks.NewClient(t)
+		c.On("ExecuteWorkflow", mock.Anything, opts, "preprocessing", params).Return(run, nil)
+
+		var stdout bytes.Buffer
+		err := newMain(t, c, &stdout).Submit(context.Background(), params, workflowID, true)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, decode(t, &stdout), workflowcmd.Execution{
+			WorkflowID: workflowID,
+			RunID:      runID,
+			Status:     "Completed",
+			Result:     &result,
+			Findings:   findings,
+		})
+	})
+
+	for _, tc := range []struct {
+		name   string
+		err    error
+		status string
+	}{
+		{
+			name:   "Reports a canceled workflow",
+			err:    temporalsdk_temporal.NewCanceledError(),
+			status: "Canceled",
+		},
+		{
+			name:   "Reports a terminated workflow",
+			err:    &temporalsdk_temporal.TerminatedError{},
+			status: "Terminated",
+		},
+		{
+			name:   "Reports a timed out workflow",
+			err:    temporalsdk_temporal.NewTimeoutError(temporalapi_enums.TIMEOUT_TYPE_START_TO_CLOSE, nil),
+			status: "TimedOut",
+		},
+		{
+			name:   "Reports a failed workflow",
+			err:    temporalsdk_temporal.NewApplicationError("stale NFS file handle", activities.ErrTypeTransient),
+			status: "Failed",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			run := newRun(t)
+			run.On("Get", mock.Anything, mock.Anything).Return(tc.err)
+			c := temporalsdk_mocks.NewClient(t)
+			c.On("ExecuteWorkflow", mock.Anything, opts, "preprocessing", params).Return(run, nil)
+
+			var stdout bytes.Buffer
+			err := newMain(t, c, &stdout).Submit(context.Background(), params, workflowID, true)
+			assert.Error(t, err, "preprocessing workflow failed")
+			assert.DeepEqual(t, decode(t, &stdout), workflowcmd.Execution{
+				WorkflowID: workflowID,
+				RunID:      runID,
+				Status:     tc.status,
+				Error:      tc.err.Error(),
+			})
+		})
+	}
+
+	t.Run("Fails without a relative path", func(t *testing.T) {
+		t.Parallel()
+
+		m := newMain(t, temporalsdk_mocks.NewClient(t), &bytes.Buffer{})
+		err := m.Submit(context.Background(), &workflow.PreprocessingWorkflowParams{}, "", false)
+		assert.Error(t, err, "missing required relative path")
+	})
+}
+
+func TestStatus(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Reports the progress of a running workflow", func(t *testing.T) {
+		t.Parallel()
+
+		progress := workflow.Progress{
+			Step:      "remove-files",
+			Completed: []workflow.StepProgress{},
+			FileCount: 2,
+		}
+		value := temporalsdk_mocks.NewEncodedValue(t)
+		value.On("Get", mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(0).(*workflow.Progress) = progress
+		}).Return(nil)
+
+		details, err := temporalsdk_converter.GetDefaultDataConverter().ToPayloads(
+			activities.Progress{LastPath: "objects/a.mov", Walked: 10, Count: 2},
+		)
+		assert.NilError(t, err)
+
+		c := temporalsdk_mocks.NewClient(t)
+		c.On("DescribeWorkflowExecution", mock.Anything, workflowID, "").Return(
+			&temporalapi_workflowservice.DescribeWorkflowExecutionResponse{
+				WorkflowExecutionInfo: executionInfo(temporalapi_enums.WORKFLOW_EXECUTION_STATUS_RUNNING),
+				PendingActivities: []*temporalapi_workflow.PendingActivityInfo{
+					{ActivityId: "1"},
+					{ActivityId: "2", HeartbeatDetails: details},
+				},
+			}, nil,
+		)
+		c.On("QueryWorkflow", mock.Anything, workflowID, runID, workflow.ProgressQuery).Return(value, nil)
+
+		var stdout bytes.Buffer
+		err = newMain(t, c, &stdout).Status(context.Background(), workflowID, "")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, decode(t, &stdout), workflowcmd.Execution{
+			WorkflowID:   workflowID,
+			RunID:        runID,
+			Status:       "Running",
+			StartTime:    &startTime,
+			Progress:     &progress,
+			StepProgress: &activities.Progress{LastPath: "objects/a.mov", Walked: 10, Count: 2},
+		})
+	})
+
+	t.Run("Decodes the progress encrypted with the codec keys", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := testConfig()
+		cfg.Codec.Keys = []config.CodecKey{{ID: "k1", Key: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}}
+		pc, err := codec.New(cfg.Codec)
+		assert.NilError(t, err)
+
+		details, err := temporalsdk_converter.NewCodecDataConverter(
+			temporalsdk_converter.GetDefaultDataConverter(), pc,
+		).ToPayloads(activities.Progress{LastPath: "objects/a.mov", Walked: 10, Count: 2})
+		assert.NilError(t, err)
+
+		c := temporalsdk_mocks.NewClient(t)
+		c.On("DescribeWorkflowExecution", mock.Anything, workflowID, "").Return(
+			&temporalapi_workflowservice.DescribeWorkflowExecutionResponse{
+				WorkflowExecutionInfo: executionInfo(temporalapi_enums.WORKFLOW_EXECUTION_STATUS_RUNNING),
+				PendingActivities: []*temporalapi_workflow.PendingActivityInfo{
+					{ActivityId: "1", HeartbeatDetails: details},
+				},
+			}, nil,
+		)
+		c.On("QueryWorkflow", mock.Anything, workflowID, runID, workflow.ProgressQuery).Return(
+			nil, errors.New("no worker"),
+		)
+
+		var stdout bytes.Buffer
+		m := workflowcmd.NewMain(logr.Discard(), cfg, &stdout, true)
+		m.SetClient(c)
+		err = m.Status(context.Background(), workflowID, "")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, decode(t, &stdout).StepProgress, &activities.Progress{
+			LastPath: "objects/a.mov",
+			Walked:   10,
+			Count:    2,
+		})
+	})
+
+	t.Run("Reports the result of a completed workflow", func(t *testing.T) {
+		t.Parallel()
+
+		result := workflow.PreprocessingWorkflowResult{RelativePath: "transfer"}
+		run := newRun(t)
+		run.On("Get", mock.Anything, mock.Anything).Run(setResult(result)).Return(nil)
+		c := temporalsdk_mocks.NewClient(t)
+		c.On("DescribeWorkflowExecution", mock.Anything, workflowID, runID).Return(
+			&temporalapi_workflowservice.DescribeWorkflowExecutionResponse{
+				WorkflowExecutionInfo: executionInfo(temporalapi_enums.WORKFLOW_EXECUTION_STATUS_COMPLETED),
+			}, nil,
+		)
+		c.On("GetWorkflow", mock.Anything, workflowID, runID).Return(run)
+
+		var stdout bytes.Buffer
+		err := newMain(t, c, &stdout).Status(context.Background(), workflowID, runID)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, decode(t, &stdout), workflowcmd.Execution{
+			WorkflowID: workflowID,
+			RunID:      runID,
+			Status:     "Completed",
+			StartTime:  &startTime,
+			Result:     &result,
+		})
+	})
+
+	t.Run("Reports the findings of a rejected SIP", func(t *testing.T) {
+		t.Parallel()
+
+		findings := validation.Findings{
+			{Severity: validation.Error, Code: "timestamp-too-old", Path: "a.mov", Message: "modified in 1970"},
+		}
+		wfErr := temporalsdk_temporal.NewApplicationError("SIP rejected", workflow.ErrTypeValidation, findings)
+		run := newRun(t)
+		run.On("Get", mock.Anything, nil).Return(wfErr)
+		c := temporalsdk_mocks.NewClient(t)
+		c.On("DescribeWorkflowExecution", mock.Anything, workflowID, runID).Return(
+			&temporalapi_workflowservice.DescribeWorkflowExecutionResponse{
+				WorkflowExecutionInfo: executionInfo(temporalapi_enums.WORKFLOW_EXECUTION_STATUS_FAILED),
+			}, nil,
+		)
+		c.On("GetWorkflow", mock.Anything, workflowID, runID).Return(run)
+
+		var stdout bytes.Buffer
+		err := newMain(t, c, &stdout).Status(context.Background(), workflowID, runID)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, decode(t, &stdout), workflowcmd.Execution{
+			WorkflowID: workflowID,
+			RunID:      runID,
+			Status:     "Failed",
+			StartTime:  &startTime,
+			Error:      wfErr.Error(),
+			Findings:   findings,
+		})
+	})
+
+	t.Run("Fails without a workflow ID", func(t *testing.T) {
+		t.Parallel()
+
+		err := newMain(t, temporalsdk_mocks.NewClient(t), &bytes.Buffer{}).Status(context.Background(), "", "")
+		assert.Error(t, err, "missing required workflow ID")
+	})
+}
+
+func TestList(t *testing.T) {
+	t.Parallel()
+
+	closeTime := startTime.Add(time.Minute)
+	c := temporalsdk_mocks.NewClient(t)
+	c.On("ListWorkflow", mock.Anything, &temporalapi_workflowservice.ListWorkflowExecutionsRequest{
+		Namespace: "default",
+		PageSize:  2,
+		Query:     "WorkflowType = 'preprocessing'",
+	}).Return(&temporalapi_workflowservice.ListWorkflowExecutionsResponse{
+		Executions: []*temporalapi_workflow.WorkflowExecutionInfo{
+			executionInfo(temporalapi_enums.WORKFLOW_EXECUTION_STATUS_RUNNING),
+			{
+				Execution: &temporalapi_common.WorkflowExecution{WorkflowId: "preprocessing-0", RunId: "a1b2c3d4"},
+				Status:    temporalapi_enums.WORKFLOW_EXECUTION_STATUS_COMPLETED,
+				StartTime: timestamppb.New(startTime),
+				CloseTime: timestamppb.New(closeTime),
+			},
+		},
+		NextPageToken: []byte("next"),
+	}, nil)
+
+	var stdout bytes.Buffer
+	err := newMain(t, c, &stdout).List(context.Background(), 2)
+	assert.NilError(t, err)
+
+	var execs []workflowcmd.Execution
+	assert.NilError(t, json.Unmarshal(stdout.Bytes(), &execs))
+	assert.DeepEqual(t, execs, []workflowcmd.Execution{
+		{WorkflowID: workflowID, RunID: runID, Status: "Running", StartTime: &startTime},
+		{WorkflowID: "preprocessing-0", RunID: "a1b2c3d4", Status: "Completed", StartTime: &startTime, CloseTime: &closeTime},
+	})
+}
+
+func TestReview(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Sends the review decision", func(t *testing.T) {
+		t.Parallel()
+
+		decision := workflow.ReviewDecision{Approved: true, Reviewer: "jdoe"}
+		result := workflow.PreprocessingWorkflowResult{
+			RelativePath: "transfer",
+			Review:       &workflow.ReviewResult{Status: workflow.ReviewApproved, Reviewer: "jdoe", Time: startTime},
+		}
+		run := newRun(t)
+		run.On("Get", mock.Anything, mock.Anything).Run(setResult(result)).Return(nil)
+		c := temporalsdk_mocks.NewClient(t)
+		c.On("SignalWorkflow", mock.Anything, workflowID, "", workflow.ReviewSignal, decision).Return(nil)
+		c.On("DescribeWorkflowExecution", mock.Anything, workflowID, "").Return(
+			&temporalapi_workflowservice.DescribeWorkflowExecutionResponse{
+				WorkflowExecutionInfo: executionInfo(temporalapi_enums.WORKFLOW_EXECUTION_STATUS_COMPLETED),
+			}, nil,
+		)
+		c.On("GetWorkflow", mock.Anything, workflowID, runID).Return(run)
+
+		var stdout bytes.Buffer
+		err := newMain(t, c, &stdout).Review(context.Background(), workflowID, "", decision)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, decode(t, &stdout), workflowcmd.Execution{
+			WorkflowID: workflowID,
+			RunID:      runID,
+			Status:     "Completed",
+			StartTime:  &startTime,
+			Result:     &result,
+		})
+	})
+
+	t.Run("Fails if the workflow isn't running", func(t *testing.T) {
+		t.Parallel()
+
+		c := temporalsdk_mocks.NewClient(t)
+		c.On("SignalWorkflow", mock.Anything, workflowID, runID, workflow.ReviewSignal, mock.Anything).Return(
+			temporalapi_serviceerror.NewNotFound("workflow execution already completed"),
+		)
+
+		err := newMain(t, c, &bytes.Buffer{}).Review(context.Background(), workflowID, runID, workflow.ReviewDecision{})
+		assert.Error(t, err, "unable to send review decision: workflow execution already completed")
+	})
+}
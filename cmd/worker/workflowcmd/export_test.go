@@ -0,0 +1,8 @@
+package workflowcmd
+
+import temporalsdk_client "go.temporal.io/sdk/client"
+
+// SetClient sets the Temporal client used instead of dialing the server.
+func (m *Main) SetClient(c temporalsdk_client.Client) {
+	m.temporalClient = c
+}
@@ -0,0 +1,138 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	temporalsdk_activity "go.temporal.io/sdk/activity"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/results"
+)
+
+const (
+	CheckTimestampsName = "check-timestamps"
+
+	// TimestampsSampleSize is the maximum number of files listed in the
+	// CheckTimestampsResult samples.
+	TimestampsSampleSize = 10
+)
+
+type CheckTimestampsParams struct {
+	// Path is the SIP directory.
+	Path string
+
+	// MinTime is the earliest valid modification time, the zero time doesn't
+	// set a lower bound.
+	MinTime time.Time
+
+	// MaxTime is the latest valid modification time.
+	MaxTime time.Time
+}
+
+type CheckTimestampsResult struct {
+	// Checked is the number of files checked.
+	Checked int
+
+	// TooOld lists the first files modified before MinTime, at most
+	// TimestampsSampleSize. The results of the previous releases list all of
+	// them.
+	TooOld []FileTimestamps `json:",omitempty"`
+
+	// InFuture lists the first files modified after MaxTime, at most
+	// TimestampsSampleSize. The results of the previous releases list all of
+	// them.
+	InFuture []FileTimestamps `json:",omitempty"`
+
+	// TooOldCount and InFutureCount are the number of files modified before
+	// MinTime and after MaxTime.
+	TooOldCount   int `json:",omitempty"`
+	InFutureCount int `json:",omitempty"`
+
+	// OutOfBoundsRef references the OutOfBounds list of all the files out of
+	// the bounds in the results store, when there are any.
+	OutOfBoundsRef *results.Ref `json:",omitempty"`
+}
+
+// OutOfBounds lists the files whose modification time is out of the
+// CheckTimestampsParams bounds.
+type OutOfBounds struct {
+	TooOld   []FileTimestamps `json:",omitempty"`
+	InFuture []FileTimestamps `json:",omitempty"`
+}
+
+type CheckTimestamps struct {
+	store *results.Store
+}
+
+// NewCheckTimestamps returns a CheckTimestamps activity writing the files out
+// of the bounds to store.
+func NewCheckTimestamps(store *results.Store) *CheckTimestamps {
+	return &CheckTimestamps{store: store}
+}
+
+// Execute lists the regular files of params.Path whose modification time is
+// out of the params bounds, e.g. the files dated 1970-01-01 or in the future
+// by a camera with a wrong clock. The files aren't modified. The list is
+// written to the results store, only the counts and a sample of the files are
+// returned.
+//
+// The activity heartbeats the number of entries walked so far.
+func (a *CheckTimestamps) Execute(ctx context.Context, params *CheckTimestampsParams) (*CheckTimestampsResult, error) {
+	res := &CheckTimestampsResult{}
+	var oob OutOfBounds
+	var progress Progress
+	err := filepath.WalkDir(params.Path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(params.Path, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		ts := FileTimestamps{Path: rel, ModTime: fi.ModTime().UTC()}
+		switch {
+		case !params.MinTime.IsZero() && ts.ModTime.Before(params.MinTime):
+			oob.TooOld = append(oob.TooOld, ts)
+		case ts.ModTime.After(params.MaxTime):
+			oob.InFuture = append(oob.InFuture, ts)
+		}
+		res.Checked++
+
+		progress.LastPath = rel
+		progress.Walked++
+		temporalsdk_activity.RecordHeartbeat(ctx, progress)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fsError(fmt.Errorf("check timestamps: %w", err))
+	}
+
+	res.TooOldCount, res.InFutureCount = len(oob.TooOld), len(oob.InFuture)
+	if res.TooOldCount+res.InFutureCount == 0 {
+		return res, nil
+	}
+	res.TooOld = oob.TooOld[:min(len(oob.TooOld), TimestampsSampleSize)]
+	res.InFuture = oob.InFuture[:min(len(oob.InFuture), TimestampsSampleSize)]
+	if res.OutOfBoundsRef, err = a.store.Write(resultName(ctx, outOfBoundsResult), oob); err != nil {
+		return nil, fsError(fmt.Errorf("check timestamps: %w", err))
+	}
+
+	return res, nil
+}
@@ -0,0 +1,130 @@
+package activities_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	temporalsdk_activity "go.temporal.io/sdk/activity"
+	temporalsdk_testsuite "go.temporal.io/sdk/testsuite"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/activities"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/results"
+)
+
+func TestCheckTimestamps(t *testing.T) {
+	t.Parallel()
+
+	epoch := time.Unix(0, 0).UTC()
+	valid := time.Date(2003, 7, 14, 18, 30, 0, 0, time.UTC)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	later := time.Date(2037, 1, 1, 0, 0, 0, 0, time.UTC)
+	dir := fs.NewDir(t, "",
+		fs.WithDir("objects",
+			fs.WithFile("a.mov", "", fs.WithTimestamps(epoch, epoch)),
+			fs.WithFile("b.mov", "", fs.WithTimestamps(valid, valid)),
+			fs.WithFile("c.mov", "", fs.WithTimestamps(later, later)),
+			fs.WithSymlink("d.mov", "a.mov"),
+		),
+		fs.WithDir("old", fs.WithTimestamps(epoch, epoch)),
+	)
+
+	tests := []struct {
+		name    string
+		params  activities.CheckTimestampsParams
+		want    activities.CheckTimestampsResult
+		wantOOB *activities.OutOfBounds
+	}{
+		{
+			name: "Lists the files out of the bounds",
+			params: activities.CheckTimestampsParams{
+				MinTime: time.Date(1980, 1, 2, 0, 0, 0, 0, time.UTC),
+				MaxTime: now,
+			},
+			want: activities.CheckTimestampsResult{
+				Checked:       3,
+				TooOld:        []activities.FileTimestamps{{Path: "objects/a.mov", ModTime: epoch}},
+				InFuture:      []activities.FileTimestamps{{Path: "objects/c.mov", ModTime: later}},
+				TooOldCount:   1,
+				InFutureCount: 1,
+			},
+			wantOOB: &activities.OutOfBounds{
+				TooOld:   []activities.FileTimestamps{{Path: "objects/a.mov", ModTime: epoch}},
+				InFuture: []activities.FileTimestamps{{Path: "objects/c.mov", ModTime: later}},
+			},
+		},
+		{
+			name:   "Doesn't check the minimum time if zero",
+			params: activities.CheckTimestampsParams{MaxTime: later},
+			want:   activities.CheckTimestampsResult{Checked: 3},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := results.NewStore(t.TempDir())
+			ts := &temporalsdk_testsuite.WorkflowTestSuite{}
+			env := ts.NewTestActivityEnvironment()
+			env.RegisterActivityWithOptions(
+				activities.NewCheckTimestamps(store).Execute,
+				temporalsdk_activity.RegisterOptions{Name: activities.CheckTimestampsName},
+			)
+
+			params := tt.params
+			params.Path = dir.Path()
+			future, err := env.ExecuteActivity(activities.CheckTimestampsName, &params)
+			assert.NilError(t, err)
+
+			var res activities.CheckTimestampsResult
+			_ = future.Get(&res)
+			if tt.wantOOB != nil {
+				var oob activities.OutOfBounds
+				assert.NilError(t, store.Load(res.OutOfBoundsRef, &oob))
+				assert.DeepEqual(t, &oob, tt.wantOOB)
+				res.OutOfBoundsRef = nil
+			}
+			assert.DeepEqual(t, res, tt.want)
+		})
+	}
+}
+
+func TestCheckTimestampsSample(t *testing.T) {
+	t.Parallel()
+
+	epoch := time.Unix(0, 0).UTC()
+	var ops []fs.PathOp
+	for i := 0; i < activities.TimestampsSampleSize+5; i++ {
+		ops = append(ops, fs.WithFile(fmt.Sprintf("%02d.mov", i), "", fs.WithTimestamps(epoch, epoch)))
+	}
+	dir := fs.NewDir(t, "", ops...)
+
+	store := results.NewStore(t.TempDir())
+	ts := &temporalsdk_testsuite.WorkflowTestSuite{}
+	env := ts.NewTestActivityEnvironment()
+	env.RegisterActivityWithOptions(
+		activities.NewCheckTimestamps(store).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.CheckTimestampsName},
+	)
+
+	future, err := env.ExecuteActivity(activities.CheckTimestampsName, &activities.CheckTimestampsParams{
+		Path:    dir.Path(),
+		MinTime: time.Date(1980, 1, 2, 0, 0, 0, 0, time.UTC),
+		MaxTime: time.Now(),
+	})
+	assert.NilError(t, err)
+
+	// Only a sample of the files is returned, the store lists all of them.
+	var res activities.CheckTimestampsResult
+	_ = future.Get(&res)
+	assert.Equal(t, res.TooOldCount, activities.TimestampsSampleSize+5)
+	assert.Equal(t, len(res.TooOld), activities.TimestampsSampleSize)
+	assert.Equal(t, res.TooOld[0].Path, "00.mov")
+
+	var oob activities.OutOfBounds
+	assert.NilError(t, store.Load(res.OutOfBoundsRef, &oob))
+	assert.Equal(t, len(oob.TooOld), activities.TimestampsSampleSize+5)
+}
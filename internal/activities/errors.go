@@ -0,0 +1,46 @@
+package activities
+
+import (
+	"errors"
+	"io/fs"
+
+	temporalsdk_temporal "go.temporal.io/sdk/temporal"
+)
+
+// Activity error types, they can be used in the retry policy
+// NonRetryableErrorTypes.
+const (
+	// ErrTypeTransient is the type of the errors that may not happen again if
+	// the activity is retried, e.g. a network filesystem I/O error.
+	ErrTypeTransient = "TransientIOError"
+
+	// ErrTypeInvalidContent is the type of the errors caused by the SIP content
+	// or the activity parameters. These errors are never retried.
+	ErrTypeInvalidContent = "InvalidContentError"
+)
+
+// transientError returns a retryable error of ErrTypeTransient type wrapping
+// err.
+func transientError(err error) error {
+	return temporalsdk_temporal.NewApplicationErrorWithCause(err.Error(), ErrTypeTransient, err)
+}
+
+// invalidContentError returns a non-retryable error of ErrTypeInvalidContent
+// type wrapping err.
+func invalidContentError(err error) error {
+	return temporalsdk_temporal.NewNonRetryableApplicationError(err.Error(), ErrTypeInvalidContent, err)
+}
+
+// fsError classifies a filesystem error. Missing files, invalid arguments and
+// permission issues won't be fixed by retrying the activity, any other error
+// is considered transient.
+func fsError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) ||
+		errors.Is(err, fs.ErrInvalid) ||
+		errors.Is(err, fs.ErrExist) ||
+		errors.Is(err, fs.ErrPermission) {
+		return invalidContentError(err)
+	}
+
+	return transientError(err)
+}
@@ -0,0 +1,54 @@
+package activities
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	temporalsdk_temporal "go.temporal.io/sdk/temporal"
+	"gotest.tools/v3/assert"
+)
+
+func TestFSError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		err          error
+		wantType     string
+		nonRetryable bool
+	}{
+		{
+			name:         "Missing file is invalid content",
+			err:          fs.ErrNotExist,
+			wantType:     ErrTypeInvalidContent,
+			nonRetryable: true,
+		},
+		{
+			name:         "Permission issue is invalid content",
+			err:          fs.ErrPermission,
+			wantType:     ErrTypeInvalidContent,
+			nonRetryable: true,
+		},
+		{
+			name:     "I/O error is transient",
+			err:      errors.New("stale NFS file handle"),
+			wantType: ErrTypeTransient,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := fsError(fmt.Errorf("open: %w", tt.err))
+
+			var appErr *temporalsdk_temporal.ApplicationError
+			assert.Assert(t, errors.As(err, &appErr))
+			assert.Equal(t, appErr.Type(), tt.wantType)
+			assert.Equal(t, appErr.NonRetryable(), tt.nonRetryable)
+			assert.Assert(t, errors.Is(err, tt.err))
+		})
+	}
+}
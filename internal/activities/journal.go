@@ -0,0 +1,202 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.artefactual.dev/tools/temporal"
+	temporalapi_enums "go.temporal.io/api/enums/v1"
+	temporalapi_serviceerror "go.temporal.io/api/serviceerror"
+	temporalsdk_activity "go.temporal.io/sdk/activity"
+	temporalsdk_client "go.temporal.io/sdk/client"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/fsutil"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/journal"
+)
+
+const (
+	RollbackJournalName = "rollback-journal"
+	PurgeTrashName      = "purge-trash"
+
+	// DeleteJournalName is the activity deleting the journal of the
+	// successful executions started before the trash retention, it's
+	// registered to complete them.
+	DeleteJournalName = "delete-journal"
+)
+
+// openJournal opens the journal of the workflow run of the activity in
+// journals. It returns a nil Journal, making the mutations without recording
+// them, if journals is nil.
+func openJournal(ctx context.Context, journals *journal.Store) (*journal.Journal, error) {
+	if journals == nil {
+		return nil, nil
+	}
+	we := temporalsdk_activity.GetInfo(ctx).WorkflowExecution
+
+	return journals.Open(we.ID, we.RunID)
+}
+
+// journalPaths opens the journal recording the mutations of paths when enabled
+// is set, see openJournal. The removed paths are renamed into the trash of the
+// journal, so the journals directory must be in the filesystem of paths: a
+// non-retryable error is returned otherwise. The returned errors are already
+// classified.
+func journalPaths(ctx context.Context, journals *journal.Store, enabled bool, paths ...string) (*journal.Journal, error) {
+	if !enabled || journals == nil {
+		return nil, nil
+	}
+
+	if err := os.MkdirAll(journals.Dir(), 0o700); err != nil {
+		return nil, fsError(fmt.Errorf("journal: %w", err))
+	}
+	for _, path := range paths {
+		same, err := fsutil.SameFilesystem(path, journals.Dir())
+		if err != nil {
+			return nil, fsError(fmt.Errorf("journal: %w", err))
+		}
+		if !same {
+			return nil, invalidContentError(fmt.Errorf(
+				"journal: the journals directory %q isn't in the filesystem of %q", journals.Dir(), path,
+			))
+		}
+	}
+
+	j, err := openJournal(ctx, journals)
+	if err != nil {
+		return nil, fsError(err)
+	}
+
+	return j, nil
+}
+
+type RollbackJournalParams struct{}
+
+type RollbackJournalResult struct {
+	// Count is the number of mutations undone.
+	Count int
+}
+
+type RollbackJournal struct {
+	journals *journal.Store
+}
+
+// NewRollbackJournal returns a RollbackJournal activity rolling back the
+// journals of journals.
+func NewRollbackJournal(journals *journal.Store) *RollbackJournal {
+	return &RollbackJournal{journals: journals}
+}
+
+// Execute undoes the filesystem mutations recorded in the journal of the
+// workflow run in reverse order, restoring the removed files from its trash.
+// The journal is kept, a retried activity skips the mutations already undone.
+func (a *RollbackJournal) Execute(ctx context.Context, params *RollbackJournalParams) (*RollbackJournalResult, error) {
+	j, err := openJournal(ctx, a.journals)
+	if err != nil {
+		return nil, fsError(fmt.Errorf("rollback journal: %w", err))
+	}
+	if j == nil {
+		return nil, fsError(errors.New("rollback journal: missing journal directory"))
+	}
+
+	n, err := j.Rollback()
+	if err != nil {
+		return nil, fsError(fmt.Errorf("rollback journal: %w", err))
+	}
+	temporal.GetLogger(ctx).V(1).Info("Journal rolled back.", "journal", j.Dir(), "count", n)
+
+	return &RollbackJournalResult{Count: n}, nil
+}
+
+type DeleteJournalParams struct{}
+
+type DeleteJournalResult struct{}
+
+type DeleteJournal struct {
+	journals *journal.Store
+}
+
+// NewDeleteJournal returns a DeleteJournal activity deleting the journals of
+// journals.
+func NewDeleteJournal(journals *journal.Store) *DeleteJournal {
+	return &DeleteJournal{journals: journals}
+}
+
+// Execute deletes the journal of the workflow run with its trash, once the
+// mutations don't need to be rolled back.
+func (a *DeleteJournal) Execute(ctx context.Context, params *DeleteJournalParams) (*DeleteJournalResult, error) {
+	j, err := openJournal(ctx, a.journals)
+	if err != nil {
+		return nil, fsError(fmt.Errorf("delete journal: %w", err))
+	}
+	if j == nil {
+		return &DeleteJournalResult{}, nil
+	}
+	if err := j.Delete(); err != nil {
+		return nil, fsError(fmt.Errorf("delete journal: %w", err))
+	}
+	removeEmptyDir(filepath.Dir(j.Dir()))
+
+	return &DeleteJournalResult{}, nil
+}
+
+type PurgeTrashParams struct {
+	// Retention is the minimum age of the journals purged, since their last
+	// entry.
+	Retention time.Duration
+}
+
+type PurgeTrashResult struct {
+	// Count is the number of journals purged.
+	Count int
+}
+
+type PurgeTrash struct {
+	journals *journal.Store
+	client   temporalsdk_client.Client
+}
+
+// NewPurgeTrash returns a PurgeTrash activity purging the journals of
+// journals, using c to check that their workflow runs aren't running.
+func NewPurgeTrash(journals *journal.Store, c temporalsdk_client.Client) *PurgeTrash {
+	return &PurgeTrash{journals: journals, client: c}
+}
+
+// Execute deletes the journals of the workflow runs, with the removed files in
+// their trash, whose last entry is older than params.Retention. The journals of
+// the runs still running are kept.
+func (a *PurgeTrash) Execute(ctx context.Context, params *PurgeTrashParams) (*PurgeTrashResult, error) {
+	if params.Retention <= 0 {
+		return nil, invalidContentError(fmt.Errorf("purge trash: invalid retention %s", params.Retention))
+	}
+
+	purged, err := a.journals.Purge(time.Now().Add(-params.Retention), func(r journal.Run) (bool, error) {
+		return IsRunning(ctx, a.client, r.WorkflowID, r.RunID)
+	})
+	for _, r := range purged {
+		temporal.GetLogger(ctx).V(1).Info("Trash purged.", "workflowID", r.WorkflowID, "runID", r.RunID)
+	}
+	if err != nil {
+		return nil, fsError(fmt.Errorf("purge trash: %w", err))
+	}
+
+	return &PurgeTrashResult{Count: len(purged)}, nil
+}
+
+// IsRunning reports whether the workflowID run is running. A run deleted by
+// the retention of the namespace isn't running.
+func IsRunning(ctx context.Context, c temporalsdk_client.Client, workflowID, runID string) (bool, error) {
+	resp, err := c.DescribeWorkflowExecution(ctx, workflowID, runID)
+	var notFound *temporalapi_serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("describe workflow %s run %s: %w", workflowID, runID, err)
+	}
+
+	return resp.GetWorkflowExecutionInfo().GetStatus() == temporalapi_enums.WORKFLOW_EXECUTION_STATUS_RUNNING, nil
+}
@@ -0,0 +1,203 @@
+package activities_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	temporalapi_enums "go.temporal.io/api/enums/v1"
+	temporalapi_serviceerror "go.temporal.io/api/serviceerror"
+	temporalapi_workflow "go.temporal.io/api/workflow/v1"
+	temporalapi_workflowservice "go.temporal.io/api/workflowservice/v1"
+	temporalsdk_activity "go.temporal.io/sdk/activity"
+	temporalsdk_mocks "go.temporal.io/sdk/mocks"
+	temporalsdk_testsuite "go.temporal.io/sdk/testsuite"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/activities"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/journal"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/report"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/results"
+)
+
+func TestJournal(t *testing.T) {
+	t.Parallel()
+
+	sip := []fs.PathOp{
+		fs.WithFile(".DS_Store", ""),
+		fs.WithFile("a.txt", "a"),
+		fs.WithDir("metadata",
+			fs.WithDir("submissionDocumentation",
+				fs.WithFile("preprocessing-report.md", "old report"),
+			),
+		),
+		fs.WithDir("z",
+			fs.WithFile("._c.txt", ""),
+			fs.WithFile("c.txt", "c"),
+		),
+	}
+	dir := fs.NewDir(t, "", sip...)
+	original := func() fs.Manifest { return fs.Expected(t, append(sip, fs.MatchAnyFileMode)...) }
+
+	store := results.NewStore(t.TempDir())
+	journals := journal.NewStore(t.TempDir())
+
+	// The activities run in the same workflow run of the test environment, so
+	// they share its journal.
+	ts := &temporalsdk_testsuite.WorkflowTestSuite{}
+	env := ts.NewTestActivityEnvironment()
+	env.RegisterActivityWithOptions(
+		activities.NewRemoveFiles(store, journals).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.RemoveFilesName},
+	)
+	env.RegisterActivityWithOptions(
+		activities.NewWriteReport(store, journals).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.WriteReportName},
+	)
+	env.RegisterActivityWithOptions(
+		activities.NewRollbackJournal(journals).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.RollbackJournalName},
+	)
+	env.RegisterActivityWithOptions(
+		activities.NewDeleteJournal(journals).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.DeleteJournalName},
+	)
+
+	_, err := env.ExecuteActivity(activities.RemoveFilesName, &activities.RemoveFilesParams{
+		Path:           dir.Path(),
+		RemoveNames:    []string{".DS_Store"},
+		RemovePatterns: []string{`^\._`},
+		Journal:        true,
+	})
+	assert.NilError(t, err)
+	_, err = env.ExecuteActivity(activities.WriteReportName, &activities.WriteReportParams{
+		Path:    dir.Path(),
+		Format:  report.Markdown,
+		Report:  report.Report{SIP: "sip"},
+		Journal: true,
+	})
+	assert.NilError(t, err)
+
+	b, err := os.ReadFile(dir.Join("metadata", "submissionDocumentation", "preprocessing-report.md"))
+	assert.NilError(t, err)
+	assert.Assert(t, string(b) != "old report")
+	_, err = os.Stat(dir.Join(".DS_Store"))
+	assert.Assert(t, os.IsNotExist(err))
+
+	future, err := env.ExecuteActivity(activities.RollbackJournalName, &activities.RollbackJournalParams{})
+	assert.NilError(t, err)
+	var res activities.RollbackJournalResult
+	_ = future.Get(&res)
+	// The removed .DS_Store, ._c.txt and old report, and the created report.
+	assert.Equal(t, res.Count, 4)
+	assert.Assert(t, fs.Equal(dir.Path(), original()))
+
+	// A retried rollback leaves the restored tree untouched.
+	future, err = env.ExecuteActivity(activities.RollbackJournalName, &activities.RollbackJournalParams{})
+	assert.NilError(t, err)
+	_ = future.Get(&res)
+	assert.Equal(t, res.Count, 0)
+	assert.Assert(t, fs.Equal(dir.Path(), original()))
+
+	_, err = env.ExecuteActivity(activities.DeleteJournalName, &activities.DeleteJournalParams{})
+	assert.NilError(t, err)
+	entries, err := os.ReadDir(journals.Dir())
+	assert.NilError(t, err)
+	assert.Equal(t, len(entries), 0)
+}
+
+func TestPurgeTrash(t *testing.T) {
+	t.Parallel()
+
+	dir := fs.NewDir(t, "", fs.WithFile("a.txt", "a"), fs.WithFile("b.txt", "b"), fs.WithFile("c.txt", "c"))
+	journals := journal.NewStore(t.TempDir())
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		j, err := journals.Open("wid-"+name, "rid")
+		assert.NilError(t, err)
+		assert.NilError(t, j.Remove(dir.Join(name)))
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	for _, id := range []string{"wid-a.txt", "wid-c.txt"} {
+		assert.NilError(t, os.Chtimes(filepath.Join(journals.Dir(), id, "rid", journal.FileName), old, old))
+	}
+
+	// The wid-a.txt run was deleted by the namespace retention and the
+	// wid-c.txt run is still running.
+	c := temporalsdk_mocks.NewClient(t)
+	c.On("DescribeWorkflowExecution", mock.Anything, "wid-a.txt", "rid").Return(
+		nil, temporalapi_serviceerror.NewNotFound("workflow not found"),
+	)
+	c.On("DescribeWorkflowExecution", mock.Anything, "wid-c.txt", "rid").Return(
+		&temporalapi_workflowservice.DescribeWorkflowExecutionResponse{
+			WorkflowExecutionInfo: &temporalapi_workflow.WorkflowExecutionInfo{
+				Status: temporalapi_enums.WORKFLOW_EXECUTION_STATUS_RUNNING,
+			},
+		}, nil,
+	)
+
+	ts := &temporalsdk_testsuite.WorkflowTestSuite{}
+	env := ts.NewTestActivityEnvironment()
+	env.RegisterActivityWithOptions(
+		activities.NewPurgeTrash(journals, c).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.PurgeTrashName},
+	)
+
+	future, err := env.ExecuteActivity(activities.PurgeTrashName, &activities.PurgeTrashParams{Retention: 24 * time.Hour})
+	assert.NilError(t, err)
+	var res activities.PurgeTrashResult
+	_ = future.Get(&res)
+	assert.Equal(t, res.Count, 1)
+
+	runs, err := journals.Runs()
+	assert.NilError(t, err)
+	assert.Equal(t, len(runs), 2)
+	assert.Equal(t, runs[0].WorkflowID, "wid-c.txt")
+	assert.Equal(t, runs[1].WorkflowID, "wid-b.txt")
+
+	_, err = env.ExecuteActivity(activities.PurgeTrashName, &activities.PurgeTrashParams{})
+	assert.ErrorContains(t, err, "purge trash: invalid retention 0s")
+}
+
+func TestIsRunning(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	status := func(s temporalapi_enums.WorkflowExecutionStatus) *temporalapi_workflowservice.DescribeWorkflowExecutionResponse {
+		return &temporalapi_workflowservice.DescribeWorkflowExecutionResponse{
+			WorkflowExecutionInfo: &temporalapi_workflow.WorkflowExecutionInfo{Status: s},
+		}
+	}
+
+	c := temporalsdk_mocks.NewClient(t)
+	c.On("DescribeWorkflowExecution", ctx, "running", "rid").Return(
+		status(temporalapi_enums.WORKFLOW_EXECUTION_STATUS_RUNNING), nil,
+	)
+	c.On("DescribeWorkflowExecution", ctx, "completed", "rid").Return(
+		status(temporalapi_enums.WORKFLOW_EXECUTION_STATUS_COMPLETED), nil,
+	)
+	c.On("DescribeWorkflowExecution", ctx, "deleted", "rid").Return(
+		nil, temporalapi_serviceerror.NewNotFound("workflow not found"),
+	)
+	c.On("DescribeWorkflowExecution", ctx, "unavailable", "rid").Return(
+		nil, temporalapi_serviceerror.NewUnavailable("server unavailable"),
+	)
+
+	running, err := activities.IsRunning(ctx, c, "running", "rid")
+	assert.NilError(t, err)
+	assert.Equal(t, running, true)
+
+	running, err = activities.IsRunning(ctx, c, "completed", "rid")
+	assert.NilError(t, err)
+	assert.Equal(t, running, false)
+
+	running, err = activities.IsRunning(ctx, c, "deleted", "rid")
+	assert.NilError(t, err)
+	assert.Equal(t, running, false)
+
+	_, err = activities.IsRunning(ctx, c, "unavailable", "rid")
+	assert.Error(t, err, "describe workflow unavailable run rid: server unavailable")
+}
@@ -0,0 +1,210 @@
+package activities
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	temporalsdk_activity "go.temporal.io/sdk/activity"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/appledouble"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/fsutil"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/journal"
+)
+
+const PreserveMacMetadataName = "preserve-mac-metadata"
+
+// MacMetadataDir is the directory of the macOS metadata sidecars, relative to
+// the SIP. The sidecar of a payload file is "<MacMetadataDir>/<path>.json".
+const MacMetadataDir = "metadata/macos"
+
+// MacMetadata is the content of the sidecar of a payload file.
+type MacMetadata struct {
+	// Path is the payload file, relative to the SIP.
+	Path string
+
+	// Missing is true when the payload file doesn't exist, e.g. for an
+	// AppleDouble file left after its file was deleted.
+	Missing bool `json:",omitempty"`
+
+	Entries []MacMetadataEntry
+}
+
+// MacMetadataEntry is the metadata of a payload file read from one source.
+type MacMetadataEntry struct {
+	// Source is the file the metadata was read from, relative to the SIP:
+	// the AppleDouble file or the payload file itself for its extended
+	// attributes.
+	Source string
+
+	AppleDouble *appledouble.File  `json:",omitempty"`
+	Xattrs      []appledouble.Attr `json:",omitempty"`
+
+	// Error describes why a malformed AppleDouble file couldn't be parsed.
+	Error string `json:",omitempty"`
+}
+
+type PreserveMacMetadataParams struct {
+	// Path is the SIP directory.
+	Path string
+
+	// Journal records the sidecars written in the journal of the workflow
+	// run, so they're removed by a rollback.
+	Journal bool `json:",omitempty"`
+}
+
+type PreserveMacMetadataResult struct {
+	// Count is the number of sidecars written.
+	Count int
+
+	// Sources is the number of AppleDouble files and files with extended
+	// attributes read.
+	Sources int
+}
+
+type PreserveMacMetadata struct {
+	journals *journal.Store
+}
+
+// NewPreserveMacMetadata returns a PreserveMacMetadata activity recording the
+// sidecars it writes in the journal of the workflow run in journals.
+func NewPreserveMacMetadata(journals *journal.Store) *PreserveMacMetadata {
+	return &PreserveMacMetadata{journals: journals}
+}
+
+// Execute parses the AppleDouble files ("._<name>") and the extended
+// attributes of the user namespace of the files in params.Path, and writes
+// the metadata of each payload file in a JSON sidecar in the MacMetadataDir,
+// so it's preserved when the AppleDouble files are removed. The files that
+// aren't AppleDouble files despite their name are ignored.
+//
+// The activity heartbeats the number of entries walked so far, a retried
+// activity starts again and overwrites the sidecars.
+func (a *PreserveMacMetadata) Execute(ctx context.Context, params *PreserveMacMetadataParams) (*PreserveMacMetadataResult, error) {
+	res := &PreserveMacMetadataResult{}
+	sidecars := map[string]*MacMetadata{}
+	add := func(payload string, e MacMetadataEntry) {
+		if sidecars[payload] == nil {
+			sidecars[payload] = &MacMetadata{Path: payload}
+		}
+		sidecars[payload].Entries = append(sidecars[payload].Entries, e)
+		res.Sources++
+	}
+
+	var progress Progress
+	err := filepath.WalkDir(params.Path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(params.Path, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == MacMetadataDir {
+			return fs.SkipDir
+		}
+
+		progress.LastPath = rel
+		progress.Walked++
+		temporalsdk_activity.RecordHeartbeat(ctx, progress)
+
+		if name := d.Name(); d.Type().IsRegular() && strings.HasPrefix(name, appledouble.Prefix) && name != appledouble.Prefix {
+			e, ok, err := readAppleDouble(p)
+			if err != nil {
+				return err
+			}
+			if ok {
+				e.Source = rel
+				add(path.Join(path.Dir(rel), strings.TrimPrefix(name, appledouble.Prefix)), e)
+				return nil
+			}
+		}
+
+		attrs, err := fsutil.Xattrs(p)
+		if err != nil {
+			return err
+		}
+		if len(attrs) > 0 {
+			e := MacMetadataEntry{Source: rel}
+			for _, attr := range attrs {
+				e.Xattrs = append(e.Xattrs, appledouble.Attr{Name: attr.Name, Value: attr.Value})
+			}
+			add(rel, e)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fsError(fmt.Errorf("preserve mac metadata: %w", err))
+	}
+
+	j, err := journalPaths(ctx, a.journals, params.Journal, params.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	payloads := make([]string, 0, len(sidecars))
+	for payload := range sidecars {
+		payloads = append(payloads, payload)
+	}
+	sort.Strings(payloads)
+
+	for _, payload := range payloads {
+		m := sidecars[payload]
+		if _, err := os.Lstat(filepath.Join(params.Path, filepath.FromSlash(payload))); errors.Is(err, fs.ErrNotExist) {
+			m.Missing = true
+		} else if err != nil {
+			return nil, fsError(fmt.Errorf("preserve mac metadata: %w", err))
+		}
+
+		b, err := json.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return nil, invalidContentError(fmt.Errorf("preserve mac metadata: %v", err))
+		}
+		dest := filepath.Join(params.Path, filepath.FromSlash(MacMetadataDir), filepath.FromSlash(payload)+".json")
+		if err := j.MkdirAll(filepath.Dir(dest), 0o700); err != nil {
+			return nil, fsError(fmt.Errorf("preserve mac metadata: %w", err))
+		}
+		if err := j.WriteFile(dest, append(b, '\n'), 0o600); err != nil {
+			return nil, fsError(fmt.Errorf("preserve mac metadata: %w", err))
+		}
+		res.Count++
+	}
+
+	return res, nil
+}
+
+// readAppleDouble returns the metadata entry of the AppleDouble file p, with
+// the parsing error of a malformed file. It reports false if the file isn't an
+// AppleDouble file.
+func readAppleDouble(p string) (MacMetadataEntry, bool, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return MacMetadataEntry{}, false, err
+	}
+
+	f, err := appledouble.Parse(b)
+	if errors.Is(err, appledouble.ErrNotAppleDouble) {
+		return MacMetadataEntry{}, false, nil
+	}
+	if err != nil {
+		return MacMetadataEntry{Error: err.Error()}, true, nil
+	}
+
+	return MacMetadataEntry{AppleDouble: f}, true, nil
+}
@@ -0,0 +1,103 @@
+package activities_test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	temporalsdk_activity "go.temporal.io/sdk/activity"
+	temporalsdk_testsuite "go.temporal.io/sdk/testsuite"
+	"golang.org/x/sys/unix"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/activities"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/journal"
+)
+
+// appleDoubleComment is an AppleDouble file with the "Conservation notes"
+// Finder comment entry.
+const appleDoubleComment = "\x00\x05\x16\x07\x00\x02\x00\x00" +
+	"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x01" +
+	"\x00\x00\x00\x04\x00\x00\x00\x26\x00\x00\x00\x12" +
+	"Conservation notes"
+
+func TestPreserveMacMetadata(t *testing.T) {
+	t.Parallel()
+
+	dir := fs.NewDir(t, "",
+		fs.WithDir("objects",
+			fs.WithFile("._a.tif", appleDoubleComment),
+			fs.WithFile("a.tif", "a"),
+			fs.WithFile("._deleted.tif", appleDoubleComment),
+			fs.WithFile("._broken.tif", appleDoubleComment[:30]),
+			fs.WithFile("broken.tif", "b"),
+			fs.WithFile("._not-appledouble", "text"),
+		),
+	)
+	err := unix.Setxattr(dir.Join("objects", "a.tif"), "user.com.apple.metadata:kMDItemFinderComment", []byte("xattr comment"), 0)
+	xattrs := err == nil
+
+	journals := journal.NewStore(t.TempDir())
+	ts := &temporalsdk_testsuite.WorkflowTestSuite{}
+	env := ts.NewTestActivityEnvironment()
+	env.RegisterActivityWithOptions(
+		activities.NewPreserveMacMetadata(journals).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.PreserveMacMetadataName},
+	)
+
+	future, err := env.ExecuteActivity(activities.PreserveMacMetadataName, &activities.PreserveMacMetadataParams{Path: dir.Path(), Journal: true})
+	assert.NilError(t, err)
+	var res activities.PreserveMacMetadataResult
+	_ = future.Get(&res)
+	assert.Equal(t, res.Count, 3)
+
+	assertSidecar := func(name, want string) {
+		t.Helper()
+		b, err := os.ReadFile(dir.Join("metadata", "macos", "objects", name+".json"))
+		assert.NilError(t, err)
+		var got, expected any
+		assert.NilError(t, json.Unmarshal(b, &got))
+		assert.NilError(t, json.Unmarshal([]byte(want), &expected))
+		assert.DeepEqual(t, got, expected)
+	}
+
+	xattrsEntry := ""
+	if xattrs {
+		xattrsEntry = `, {
+			"Source": "objects/a.tif",
+			"Xattrs": [{"Name": "user.com.apple.metadata:kMDItemFinderComment", "Value": "xattr comment"}]
+		}`
+	}
+	assertSidecar("a.tif", `{
+		"Path": "objects/a.tif",
+		"Entries": [{
+			"Source": "objects/._a.tif",
+			"AppleDouble": {"Comment": "Conservation notes"}
+		}`+xattrsEntry+`]
+	}`)
+	assertSidecar("deleted.tif", `{
+		"Path": "objects/deleted.tif",
+		"Missing": true,
+		"Entries": [{
+			"Source": "objects/._deleted.tif",
+			"AppleDouble": {"Comment": "Conservation notes"}
+		}]
+	}`)
+	assertSidecar("broken.tif", `{
+		"Path": "objects/broken.tif",
+		"Entries": [{
+			"Source": "objects/._broken.tif",
+			"Error": "appledouble: truncated entries"
+		}]
+	}`)
+
+	// The sidecars are journaled, so they're removed by a rollback.
+	j, err := journals.Open("default-test-workflow-id", "default-test-run-id")
+	assert.NilError(t, err)
+	_, err = j.Rollback()
+	assert.NilError(t, err)
+	_, err = os.Stat(dir.Join("metadata"))
+	assert.Assert(t, os.IsNotExist(err))
+}
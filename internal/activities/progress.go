@@ -0,0 +1,100 @@
+package activities
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	temporalsdk_activity "go.temporal.io/sdk/activity"
+)
+
+// Progress is recorded in the heartbeat details of the activities walking a
+// directory tree, so a retried activity can resume where the previous attempt
+// stopped instead of starting from scratch.
+type Progress struct {
+	// LastPath is the last path processed, relative to the walked directory.
+	LastPath string
+
+	// Walked is the number of entries processed so far.
+	Walked int
+
+	// Count is the number of entries modified (e.g. removed) so far.
+	Count int
+
+	// Modified lists the paths of the entries modified so far, relative to the
+	// walked directory.
+	//
+	// Deprecated: set by the previous releases, the activities write the
+	// modified paths to the results store.
+	Modified []string `json:",omitempty"`
+}
+
+// lastProgress returns the progress recorded by a previous attempt of the
+// activity, or a zero Progress when there isn't one.
+func lastProgress(ctx context.Context) Progress {
+	var p Progress
+	if !temporalsdk_activity.HasHeartbeatDetails(ctx) {
+		return p
+	}
+	if err := temporalsdk_activity.GetHeartbeatDetails(ctx, &p); err != nil {
+		return Progress{}
+	}
+
+	return p
+}
+
+// resumeWalk walks the root directory like filepath.WalkDir, but it skips the
+// entries that were already processed up to and including last, a path
+// relative to root. The root directory itself is never passed to fn.
+//
+// filepath.WalkDir visits the entries in lexical order, so comparing the path
+// elements is enough to know if an entry was visited before last. Directories
+// containing last are still walked to reach the entries after it.
+func resumeWalk(root, last string, fn func(path, rel string, d fs.DirEntry) error) error {
+	lastElems := splitPath(last)
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		if last != "" {
+			elems := splitPath(rel)
+			if c := slices.Compare(elems, lastElems); c <= 0 {
+				switch {
+				case d.IsDir() && (c == 0 || isAncestor(elems, lastElems)):
+					// Walk the contents, they may be after last.
+					return nil
+				case d.IsDir():
+					return fs.SkipDir
+				default:
+					return nil
+				}
+			}
+		}
+
+		return fn(path, rel, d)
+	})
+}
+
+func splitPath(p string) []string {
+	if p == "" {
+		return nil
+	}
+
+	return strings.Split(filepath.ToSlash(p), "/")
+}
+
+func isAncestor(elems, of []string) bool {
+	return len(elems) < len(of) && slices.Equal(elems, of[:len(elems)])
+}
@@ -0,0 +1,258 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"slices"
+
+	"go.artefactual.dev/tools/temporal"
+	temporalsdk_activity "go.temporal.io/sdk/activity"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/journal"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/results"
+)
+
+const (
+	RemoveFilesName = "remove-files"
+
+	// RemoveFilesLegacyName is the activity name used by the workflow
+	// executions started before the "remove-files" rename, it's registered to
+	// complete them.
+	RemoveFilesLegacyName = "remove-files-activity"
+)
+
+// removedChunkSize is the maximum number of entries walked between two
+// checkpoints of the RemoveFiles progress. Each checkpoint writes the paths
+// removed since the previous one to a chunk of the removed files list, before
+// the progress is recorded in the heartbeats.
+const removedChunkSize = 1000
+
+type RemoveFilesParams struct {
+	// Path is the directory from which files should be removed.
+	Path string
+
+	// RemoveNames is a list of file names that should be removed.
+	RemoveNames []string
+
+	// RemovePatterns is a list of regular expressions matching the file names
+	// that should be removed.
+	RemovePatterns []string
+
+	// Journal records the removals in the journal of the workflow run, the
+	// removed files are moved to its trash instead of being deleted.
+	Journal bool `json:",omitempty"`
+}
+
+type RemoveFilesResult struct {
+	// Count is the number of files removed from Path. A removed directory
+	// counts as one item no matter how many items it contains.
+	Count int
+
+	// RemovedRef references the list of the removed files paths, relative to
+	// Path, in the results store. It's nil when no file was removed.
+	RemovedRef *results.Ref `json:",omitempty"`
+
+	// Removed lists the paths of the removed files, relative to Path.
+	//
+	// Deprecated: set by the previous releases, the list is written to the
+	// results store and referenced by RemovedRef.
+	Removed []string `json:",omitempty"`
+}
+
+type RemoveFiles struct {
+	store    *results.Store
+	journals *journal.Store
+}
+
+// NewRemoveFiles returns a RemoveFiles activity writing the list of removed
+// files to store, and moving the removed files to the trash of their journal
+// in journals when it's enabled.
+func NewRemoveFiles(store *results.Store, journals *journal.Store) *RemoveFiles {
+	return &RemoveFiles{store: store, journals: journals}
+}
+
+// Execute deletes any file or directory in params.Path (and sub-directories)
+// whose name matches one of params.RemoveNames or params.RemovePatterns. The
+// removed files are moved to the trash of the workflow run, and listed in its
+// journal.
+//
+// The activity heartbeats its Progress after each entry and, when it's retried,
+// it resumes from the last path recorded by the previous attempt. The removed
+// paths aren't recorded in the heartbeats, they're written in chunks to the
+// results store at each checkpoint.
+func (a *RemoveFiles) Execute(ctx context.Context, params *RemoveFilesParams) (*RemoveFilesResult, error) {
+	logger := temporal.GetLogger(ctx)
+
+	patterns := make([]*regexp.Regexp, 0, len(params.RemovePatterns))
+	for _, p := range params.RemovePatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, invalidContentError(fmt.Errorf("invalid pattern: %v", err))
+		}
+		patterns = append(patterns, re)
+	}
+
+	if len(params.RemoveNames) == 0 && len(patterns) == 0 {
+		return &RemoveFilesResult{}, nil
+	}
+
+	fi, err := os.Stat(params.Path)
+	if err != nil {
+		return nil, fsError(fmt.Errorf("remove files: %w", err))
+	}
+	if !fi.IsDir() {
+		return nil, invalidContentError(fmt.Errorf("remove files: %q: not a directory", params.Path))
+	}
+
+	j, err := journalPaths(ctx, a.journals, params.Journal, params.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The paths removed by the previous attempts are listed in the chunks
+	// written to the results store, the heartbeat details only record where
+	// to resume.
+	progress := lastProgress(ctx)
+	chunks, count, err := a.removedChunks(ctx)
+	if err != nil {
+		return nil, fsError(fmt.Errorf("remove files: %w", err))
+	}
+	if len(progress.Modified) > 0 && chunks == 0 {
+		// Recorded by a previous release.
+		if _, err := a.store.Write(removedChunkName(ctx, 1), progress.Modified); err != nil {
+			return nil, fsError(fmt.Errorf("remove files: %w", err))
+		}
+		chunks, count = 1, len(progress.Modified)
+	}
+	progress.Modified = nil
+	progress.Count = count
+	if progress.LastPath != "" {
+		logger.V(1).Info("Resuming remove files.", "LastPath", progress.LastPath, "Count", progress.Count)
+	}
+
+	// checkpoint is the progress recorded in the heartbeats, the paths removed
+	// up to its LastPath are written to the results store.
+	checkpoint := progress
+	var pending []string
+	flush := func() error {
+		if len(pending) > 0 {
+			if _, err := a.store.Write(removedChunkName(ctx, chunks+1), pending); err != nil {
+				return err
+			}
+			chunks++
+			pending = nil
+		}
+		checkpoint = progress
+
+		return nil
+	}
+
+	err = resumeWalk(params.Path, progress.LastPath, func(path, rel string, d fs.DirEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		matches := slices.Contains(params.RemoveNames, d.Name())
+		for _, re := range patterns {
+			if matches {
+				break
+			}
+			matches = re.MatchString(d.Name())
+		}
+
+		if matches {
+			if err := j.Remove(path); err != nil {
+				return fmt.Errorf("remove file: %w", err)
+			}
+			progress.Count++
+			pending = append(pending, rel)
+		}
+
+		progress.LastPath = rel
+		progress.Walked++
+		if progress.Walked-checkpoint.Walked >= removedChunkSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		temporalsdk_activity.RecordHeartbeat(ctx, checkpoint)
+
+		if matches && d.IsDir() {
+			return fs.SkipDir
+		}
+
+		return nil
+	})
+	// Keep the paths removed before an error, the next attempt resumes after
+	// them.
+	if ferr := flush(); ferr != nil && err == nil {
+		err = ferr
+	}
+	temporalsdk_activity.RecordHeartbeat(ctx, checkpoint)
+	if err != nil {
+		return nil, fsError(fmt.Errorf("remove files: %w", err))
+	}
+
+	res := &RemoveFilesResult{Count: progress.Count}
+	if chunks > 0 {
+		res.RemovedRef, err = a.writeRemoved(ctx, chunks)
+		if err != nil {
+			return nil, fsError(fmt.Errorf("remove files: %w", err))
+		}
+	}
+
+	return res, nil
+}
+
+// removedChunks returns the number of chunks of the removed files list written
+// by the previous attempts of the activity, and the number of paths they list.
+func (a *RemoveFiles) removedChunks(ctx context.Context) (chunks, count int, err error) {
+	for {
+		var paths []string
+		err := a.store.Read(removedChunkName(ctx, chunks+1), &paths)
+		if errors.Is(err, fs.ErrNotExist) {
+			return chunks, count, nil
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		chunks++
+		count += len(paths)
+	}
+}
+
+// writeRemoved writes the removed files list from its chunks, and removes
+// them.
+func (a *RemoveFiles) writeRemoved(ctx context.Context, chunks int) (*results.Ref, error) {
+	var removed []string
+	for i := 1; i <= chunks; i++ {
+		var paths []string
+		if err := a.store.Read(removedChunkName(ctx, i), &paths); err != nil {
+			return nil, err
+		}
+		removed = append(removed, paths...)
+	}
+
+	ref, err := a.store.Write(resultName(ctx, removedFilesResult), removed)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 1; i <= chunks; i++ {
+		if err := a.store.Remove(removedChunkName(ctx, i)); err != nil {
+			return nil, err
+		}
+	}
+
+	return ref, nil
+}
+
+// removedChunkName returns the store name of the i chunk of the removed files
+// list.
+func removedChunkName(ctx context.Context, i int) string {
+	return resultName(ctx, fmt.Sprintf(removedFilesChunk, i))
+}
@@ -0,0 +1,180 @@
+package activities_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	temporalsdk_activity "go.temporal.io/sdk/activity"
+	temporalsdk_temporal "go.temporal.io/sdk/temporal"
+	temporalsdk_testsuite "go.temporal.io/sdk/testsuite"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/activities"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/results"
+)
+
+func TestRemoveFiles(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		params    activities.RemoveFilesParams
+		heartbeat *activities.Progress
+		chunks    [][]string
+		wantCount int
+		wantFiles []string
+		wantFS    fs.Manifest
+		wantErr   string
+	}{
+		{
+			name: "Removes files by name and pattern",
+			params: activities.RemoveFilesParams{
+				RemoveNames:    []string{".DS_Store"},
+				RemovePatterns: []string{`^\._`},
+			},
+			wantCount: 4,
+			wantFiles: []string{".DS_Store", "._a.txt", "dir/.DS_Store", "z/._c.txt"},
+			wantFS: fs.Expected(t,
+				fs.WithFile("a.txt", "a"),
+				fs.WithDir("dir", fs.WithFile("b.txt", "b")),
+				fs.WithDir("z", fs.WithFile("c.txt", "c")),
+			),
+		},
+		{
+			name: "Resumes after the last heartbeat path",
+			params: activities.RemoveFilesParams{
+				RemoveNames:    []string{".DS_Store"},
+				RemovePatterns: []string{`^\._`},
+			},
+			heartbeat: &activities.Progress{
+				LastPath: "dir/b.txt",
+				Walked:   5,
+				Count:    2,
+			},
+			chunks:    [][]string{{".DS_Store"}, {"._a.txt"}},
+			wantCount: 3,
+			wantFiles: []string{".DS_Store", "._a.txt", "z/._c.txt"},
+			// Entries up to dir/b.txt are skipped, only z/._c.txt is removed.
+			wantFS: fs.Expected(t,
+				fs.WithFile(".DS_Store", ""),
+				fs.WithFile("._a.txt", ""),
+				fs.WithFile("a.txt", "a"),
+				fs.WithDir("dir",
+					fs.WithFile(".DS_Store", ""),
+					fs.WithFile("b.txt", "b"),
+				),
+				fs.WithDir("z", fs.WithFile("c.txt", "c")),
+			),
+		},
+		{
+			name: "Resumes the removed files list of a previous release",
+			params: activities.RemoveFilesParams{
+				RemoveNames:    []string{".DS_Store"},
+				RemovePatterns: []string{`^\._`},
+			},
+			heartbeat: &activities.Progress{
+				LastPath: "dir/b.txt",
+				Walked:   5,
+				Count:    2,
+				Modified: []string{".DS_Store", "._a.txt"},
+			},
+			wantCount: 3,
+			wantFiles: []string{".DS_Store", "._a.txt", "z/._c.txt"},
+			wantFS: fs.Expected(t,
+				fs.WithFile(".DS_Store", ""),
+				fs.WithFile("._a.txt", ""),
+				fs.WithFile("a.txt", "a"),
+				fs.WithDir("dir",
+					fs.WithFile(".DS_Store", ""),
+					fs.WithFile("b.txt", "b"),
+				),
+				fs.WithDir("z", fs.WithFile("c.txt", "c")),
+			),
+		},
+		{
+			name:    "Fails with an invalid pattern",
+			params:  activities.RemoveFilesParams{RemovePatterns: []string{"("}},
+			wantErr: "invalid pattern",
+		},
+		{
+			name: "Fails with a missing path",
+			params: activities.RemoveFilesParams{
+				Path:        "missing",
+				RemoveNames: []string{".DS_Store"},
+			},
+			wantErr: "no such file or directory",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := fs.NewDir(t, "",
+				fs.WithFile(".DS_Store", ""),
+				fs.WithFile("._a.txt", ""),
+				fs.WithFile("a.txt", "a"),
+				fs.WithDir("dir",
+					fs.WithFile(".DS_Store", ""),
+					fs.WithFile("b.txt", "b"),
+				),
+				fs.WithDir("z",
+					fs.WithFile("._c.txt", ""),
+					fs.WithFile("c.txt", "c"),
+				),
+			)
+
+			store := results.NewStore(t.TempDir())
+			for i, chunk := range tt.chunks {
+				name := fmt.Sprintf("default-test-workflow-id/default-test-run-id/removed-files.%04d.json", i+1)
+				_, err := store.Write(name, chunk)
+				assert.NilError(t, err)
+			}
+			ts := &temporalsdk_testsuite.WorkflowTestSuite{}
+			env := ts.NewTestActivityEnvironment()
+			env.RegisterActivityWithOptions(
+				activities.NewRemoveFiles(store, nil).Execute,
+				temporalsdk_activity.RegisterOptions{Name: activities.RemoveFilesName},
+			)
+			if tt.heartbeat != nil {
+				env.SetHeartbeatDetails(tt.heartbeat)
+			}
+
+			params := tt.params
+			params.Path = dir.Join(params.Path)
+			future, err := env.ExecuteActivity(activities.RemoveFilesName, &params)
+
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+
+				// Invalid params or content errors are never retried.
+				var appErr *temporalsdk_temporal.ApplicationError
+				assert.Assert(t, errors.As(err, &appErr))
+				assert.Equal(t, appErr.Type(), activities.ErrTypeInvalidContent)
+				assert.Assert(t, appErr.NonRetryable())
+				return
+			}
+			assert.NilError(t, err)
+
+			var res activities.RemoveFilesResult
+			_ = future.Get(&res)
+			assert.Equal(t, res.Count, tt.wantCount)
+			assert.Assert(t, fs.Equal(dir.Path(), tt.wantFS))
+
+			// The removed files are listed in the results store.
+			var removed []string
+			assert.NilError(t, store.Load(res.RemovedRef, &removed))
+			assert.DeepEqual(t, removed, tt.wantFiles)
+
+			// The chunks of the list are removed once it's written.
+			entries, err := os.ReadDir(filepath.Join(store.Dir(), "default-test-workflow-id", "default-test-run-id"))
+			assert.NilError(t, err)
+			assert.Equal(t, len(entries), 1)
+			assert.Equal(t, entries[0].Name(), "removed-files.json")
+		})
+	}
+}
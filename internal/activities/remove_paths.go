@@ -4,35 +4,53 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
+	"path/filepath"
 
-	"go.artefactual.dev/tools/temporal"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/journal"
 )
 
 const RemovePathsName = "remove-paths"
 
 type RemovePathsParams struct {
 	Paths []string
+
+	// Journal records the removals in the journal of the workflow run, the
+	// removed paths are moved to its trash instead of being deleted.
+	Journal bool `json:",omitempty"`
 }
 type RemovePathsResult struct{}
 
-type RemovePaths struct{}
+type RemovePaths struct {
+	journals *journal.Store
+}
 
-func NewRemovePaths() *RemovePaths {
-	return &RemovePaths{}
+// NewRemovePaths returns a RemovePaths activity moving the removed paths to
+// the trash of their journal in journals when it's enabled.
+func NewRemovePaths(journals *journal.Store) *RemovePaths {
+	return &RemovePaths{journals: journals}
 }
 
+// Execute removes params.Paths, moving them to the trash of the workflow run
+// when params.Journal is set.
 func (a *RemovePaths) Execute(ctx context.Context, params *RemovePathsParams) (*RemovePathsResult, error) {
-	var e error
+	parents := make([]string, 0, len(params.Paths))
+	for _, path := range params.Paths {
+		parents = append(parents, filepath.Dir(path))
+	}
+	j, err := journalPaths(ctx, a.journals, params.Journal, parents...)
+	if err != nil {
+		return nil, err
+	}
 
+	var e error
 	for _, path := range params.Paths {
-		if err := os.RemoveAll(path); err != nil {
-			e = errors.Join(e, fmt.Errorf("error removing path: %v", err))
+		if err := j.Remove(path); err != nil {
+			e = errors.Join(e, fmt.Errorf("error removing path: %w", err))
 		}
 	}
 
 	if e != nil {
-		return nil, temporal.NewNonRetryableError(e)
+		return nil, fsError(e)
 	}
 
 	return &RemovePathsResult{}, nil
@@ -11,17 +11,20 @@ import (
 	"gotest.tools/v3/fs"
 
 	"github.com/artefactual-sdps/preprocessing-moma/internal/activities"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/journal"
 )
 
 func TestRemovePaths(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
-		name    string
-		dir     *fs.Dir
-		paths   []string
-		want    activities.RemovePathsResult
-		wantErr string
+		name     string
+		dir      *fs.Dir
+		paths    []string
+		journals *journal.Store
+		journal  bool
+		want     activities.RemovePathsResult
+		wantErr  string
 	}{
 		{
 			name:  "Succeeds with single path",
@@ -35,6 +38,21 @@ func TestRemovePaths(t *testing.T) {
 			paths: []string{"folder1", "folder2"},
 			want:  activities.RemovePathsResult{},
 		},
+		{
+			name:     "Moves the paths to the trash",
+			dir:      fs.NewDir(t, "", fs.WithDir("folder1"), fs.WithFile("file", "")),
+			paths:    []string{"folder1", "file"},
+			journals: journal.NewStore(t.TempDir()),
+			journal:  true,
+			want:     activities.RemovePathsResult{},
+		},
+		{
+			name:     "Deletes the paths when the journal isn't enabled",
+			dir:      fs.NewDir(t, "", fs.WithDir("folder1"), fs.WithFile("file", "")),
+			paths:    []string{"folder1", "file"},
+			journals: journal.NewStore(t.TempDir()),
+			want:     activities.RemovePathsResult{},
+		},
 		{
 			name:    "Fails with single path",
 			dir:     fs.NewDir(t, "", fs.WithDir("folder"), fs.WithMode(0o000)),
@@ -61,13 +79,13 @@ func TestRemovePaths(t *testing.T) {
 			ts := &temporalsdk_testsuite.WorkflowTestSuite{}
 			env := ts.NewTestActivityEnvironment()
 			env.RegisterActivityWithOptions(
-				activities.NewRemovePaths().Execute,
+				activities.NewRemovePaths(tt.journals).Execute,
 				temporalsdk_activity.RegisterOptions{Name: activities.RemovePathsName},
 			)
 
 			future, err := env.ExecuteActivity(
 				activities.RemovePathsName,
-				&activities.RemovePathsParams{Paths: paths},
+				&activities.RemovePathsParams{Paths: paths, Journal: tt.journal},
 			)
 
 			if tt.wantErr != "" {
@@ -89,6 +107,18 @@ func TestRemovePaths(t *testing.T) {
 				_, err = os.Stat(path)
 				assert.ErrorContains(t, err, "no such file or directory")
 			}
+
+			if tt.journals != nil {
+				j, err := tt.journals.Open("default-test-workflow-id", "default-test-run-id")
+				assert.NilError(t, err)
+				trashed, err := j.Trashed()
+				assert.NilError(t, err)
+				if tt.journal {
+					assert.Equal(t, len(trashed), len(paths))
+				} else {
+					assert.Equal(t, len(trashed), 0)
+				}
+			}
 		})
 	}
 }
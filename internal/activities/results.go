@@ -0,0 +1,34 @@
+package activities
+
+import (
+	"context"
+	"net/url"
+	"path"
+
+	temporalsdk_activity "go.temporal.io/sdk/activity"
+)
+
+// Names of the results written to the results store.
+const (
+	removedFilesResult = "removed-files.json"
+	inventoryResult    = "inventory.json"
+	outOfBoundsResult  = "timestamps-out-of-bounds.json"
+
+	// removedFilesChunk is the name format of the chunks of the removed files
+	// list written while the files are removed, numbered from 1.
+	removedFilesChunk = "removed-files.%04d.json"
+)
+
+// runName returns the slash-separated name of the workflow run of the
+// activity, e.g. the directory of its results.
+func runName(ctx context.Context) string {
+	we := temporalsdk_activity.GetInfo(ctx).WorkflowExecution
+
+	return path.Join(url.PathEscape(we.ID), url.PathEscape(we.RunID))
+}
+
+// resultName returns the store name of the name result of the activity, unique
+// for each workflow run.
+func resultName(ctx context.Context, name string) string {
+	return path.Join(runName(ctx), name)
+}
@@ -0,0 +1,284 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"go.artefactual.dev/tools/temporal"
+	temporalsdk_activity "go.temporal.io/sdk/activity"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/fsutil"
+)
+
+const (
+	StageName        = "stage"
+	PublishStageName = "publish-stage"
+	DiscardStageName = "discard-stage"
+)
+
+const (
+	// stagedDir and originalDir are the directories of the staged copy and of
+	// the original SIP being replaced, in the staging directory of a workflow
+	// run.
+	stagedDir   = "staged"
+	originalDir = "original"
+
+	// stageMarker is written in the staged copy before it replaces the SIP,
+	// so a retried publication knows if the SIP was replaced. It's removed
+	// once the publication completes.
+	stageMarker = ".preprocessing-staged"
+)
+
+// stageRunDir returns the staging directory of the workflow run of the
+// activity in dir.
+func stageRunDir(ctx context.Context, dir string) string {
+	return filepath.Join(dir, filepath.FromSlash(runName(ctx)))
+}
+
+type StageParams struct {
+	// Path is the SIP copied into the staging directory.
+	Path string
+
+	// Hardlinks links the files that can't be reflinked instead of copying
+	// them.
+	Hardlinks bool
+}
+
+type StageResult struct {
+	// Path is the staged copy of the SIP, processed instead of the SIP.
+	Path string
+
+	// FileCount is the number of files copied.
+	FileCount int
+}
+
+type Stage struct {
+	dir string
+}
+
+// NewStage returns a Stage activity copying the SIPs into the dir staging
+// directory.
+func NewStage(dir string) *Stage {
+	return &Stage{dir: dir}
+}
+
+// Execute copies the params.Path SIP into the staging directory of the
+// workflow run with fsutil.CopyTree. The SIP isn't modified.
+//
+// The activity heartbeats the number of entries copied. A retried activity
+// discards the copy of the previous attempt and starts from scratch.
+func (a *Stage) Execute(ctx context.Context, params *StageParams) (*StageResult, error) {
+	logger := temporal.GetLogger(ctx)
+
+	fi, err := os.Stat(params.Path)
+	if err != nil {
+		return nil, fsError(fmt.Errorf("stage: %w", err))
+	}
+	if !fi.IsDir() {
+		return nil, invalidContentError(fmt.Errorf("stage: %q: not a directory", params.Path))
+	}
+
+	parent := filepath.Join(stageRunDir(ctx, a.dir), stagedDir)
+	if err := os.RemoveAll(parent); err != nil {
+		return nil, fsError(fmt.Errorf("stage: %w", err))
+	}
+	if err := os.MkdirAll(parent, 0o700); err != nil {
+		return nil, fsError(fmt.Errorf("stage: %w", err))
+	}
+
+	// The staged copy replaces the SIP with a rename.
+	same, err := fsutil.SameFilesystem(params.Path, parent)
+	if err != nil {
+		return nil, fsError(fmt.Errorf("stage: %w", err))
+	}
+	if !same {
+		return nil, invalidContentError(fmt.Errorf(
+			"stage: the staging directory %q isn't in the filesystem of %q", a.dir, params.Path,
+		))
+	}
+
+	path := filepath.Join(parent, filepath.Base(params.Path))
+	logger.V(1).Info("Staging SIP.", "path", params.Path, "stagePath", path)
+
+	var walked int
+	count, err := fsutil.CopyTree(params.Path, path, fsutil.CopyOptions{
+		Hardlinks: params.Hardlinks,
+		Progress: func(rel string) error {
+			walked++
+			temporalsdk_activity.RecordHeartbeat(ctx, walked)
+			return ctx.Err()
+		},
+	})
+	if err != nil {
+		return nil, fsError(fmt.Errorf("stage: %w", err))
+	}
+
+	return &StageResult{Path: path, FileCount: count}, nil
+}
+
+type PublishStageParams struct {
+	// Path is the SIP replaced by its staged copy.
+	Path string
+}
+
+type PublishStageResult struct{}
+
+type PublishStage struct {
+	dir string
+}
+
+// NewPublishStage returns a PublishStage activity publishing the staged copies
+// of the dir staging directory.
+func NewPublishStage(dir string) *PublishStage {
+	return &PublishStage{dir: dir}
+}
+
+// Execute replaces the params.Path SIP with its staged copy, then deletes the
+// SIP and the staging directory of the workflow run.
+//
+// The paths are exchanged atomically when the filesystem supports it,
+// otherwise the SIP is moved into the staging directory before the staged copy
+// is moved to params.Path. A retried activity resumes an interrupted
+// publication.
+func (a *PublishStage) Execute(ctx context.Context, params *PublishStageParams) (*PublishStageResult, error) {
+	runDir := stageRunDir(ctx, a.dir)
+	marker := filepath.Join(params.Path, stageMarker)
+	published, err := exists(marker)
+	if err != nil {
+		return nil, fsError(fmt.Errorf("publish stage: %w", err))
+	}
+	if !published {
+		name := filepath.Base(params.Path)
+		stagePath := filepath.Join(runDir, stagedDir, name)
+		original := filepath.Join(runDir, originalDir, name)
+		if err := publish(stagePath, params.Path, original); err != nil {
+			return nil, fsError(fmt.Errorf("publish stage: %w", err))
+		}
+	}
+
+	// Delete the original SIP before the marker, so a retried activity
+	// doesn't publish the original SIP again.
+	if err := os.RemoveAll(runDir); err != nil {
+		return nil, fsError(fmt.Errorf("publish stage: %w", err))
+	}
+	if err := os.Remove(marker); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fsError(fmt.Errorf("publish stage: %w", err))
+	}
+	removeEmptyDir(filepath.Dir(runDir))
+
+	return &PublishStageResult{}, nil
+}
+
+// publish replaces path with stagePath, moving path to original if they can't
+// be exchanged.
+func publish(stagePath, path, original string) error {
+	staged, err := exists(stagePath)
+	if err != nil {
+		return err
+	}
+	if !staged {
+		// A previous attempt completed the publication.
+		return nil
+	}
+
+	if err := os.WriteFile(filepath.Join(stagePath, stageMarker), nil, 0o600); err != nil {
+		return err
+	}
+
+	found, err := exists(path)
+	if err != nil {
+		return err
+	}
+	if !found {
+		// A previous attempt moved the SIP to original.
+		return os.Rename(stagePath, path)
+	}
+
+	err = fsutil.Exchange(stagePath, path)
+	if !errors.Is(err, errors.ErrUnsupported) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(original), 0o700); err != nil {
+		return err
+	}
+	if err := os.Rename(path, original); err != nil {
+		return err
+	}
+
+	return os.Rename(stagePath, path)
+}
+
+type DiscardStageParams struct {
+	// Path is the SIP.
+	Path string
+}
+
+type DiscardStageResult struct{}
+
+type DiscardStage struct {
+	dir string
+}
+
+// NewDiscardStage returns a DiscardStage activity discarding the staged copies
+// of the dir staging directory.
+func NewDiscardStage(dir string) *DiscardStage {
+	return &DiscardStage{dir: dir}
+}
+
+// Execute deletes the staging directory of the workflow run, with the staged
+// copy of the params.Path SIP (complete or not), leaving the SIP untouched.
+//
+// The SIP is moved back to params.Path if an interrupted publication moved it
+// into the staging directory. It returns an error without deleting anything if
+// the staged copy already replaced the SIP.
+func (a *DiscardStage) Execute(ctx context.Context, params *DiscardStageParams) (*DiscardStageResult, error) {
+	runDir := stageRunDir(ctx, a.dir)
+	published, err := exists(filepath.Join(params.Path, stageMarker))
+	if err != nil {
+		return nil, fsError(fmt.Errorf("discard stage: %w", err))
+	}
+	if published {
+		return nil, invalidContentError(fmt.Errorf(
+			"discard stage: %q already replaced by its staged copy, the SIP is kept in %q", params.Path, runDir,
+		))
+	}
+
+	found, err := exists(params.Path)
+	if err != nil {
+		return nil, fsError(fmt.Errorf("discard stage: %w", err))
+	}
+	if !found {
+		original := filepath.Join(runDir, originalDir, filepath.Base(params.Path))
+		if err := os.Rename(original, params.Path); err != nil {
+			return nil, fsError(fmt.Errorf("discard stage: restore SIP: %w", err))
+		}
+	}
+
+	if err := os.RemoveAll(runDir); err != nil {
+		return nil, fsError(fmt.Errorf("discard stage: %w", err))
+	}
+	removeEmptyDir(filepath.Dir(runDir))
+
+	return &DiscardStageResult{}, nil
+}
+
+// exists reports whether path exists, without following symbolic links.
+func exists(path string) (bool, error) {
+	_, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// removeEmptyDir removes dir if it's empty, e.g. the staging directory of a
+// workflow ID without other runs.
+func removeEmptyDir(dir string) {
+	_ = os.Remove(dir)
+}
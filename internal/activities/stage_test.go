@@ -0,0 +1,184 @@
+package activities_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	temporalsdk_activity "go.temporal.io/sdk/activity"
+	temporalsdk_testsuite "go.temporal.io/sdk/testsuite"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/activities"
+)
+
+// stageTestEnv returns an activity test environment with the staging
+// activities registered, using dir as the staging directory. The staged copies
+// are in "<dir>/default-test-workflow-id/default-test-run-id/staged".
+func stageTestEnv(t *testing.T, dir string) *temporalsdk_testsuite.TestActivityEnvironment {
+	t.Helper()
+
+	ts := &temporalsdk_testsuite.WorkflowTestSuite{}
+	env := ts.NewTestActivityEnvironment()
+	env.RegisterActivityWithOptions(
+		activities.NewStage(dir).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.StageName},
+	)
+	env.RegisterActivityWithOptions(
+		activities.NewPublishStage(dir).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.PublishStageName},
+	)
+	env.RegisterActivityWithOptions(
+		activities.NewDiscardStage(dir).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.DiscardStageName},
+	)
+
+	return env
+}
+
+func TestStage(t *testing.T) {
+	t.Parallel()
+
+	shared := fs.NewDir(t, "",
+		fs.WithDir("sip",
+			fs.WithFile(".DS_Store", ""),
+			fs.WithDir("objects", fs.WithFile("image.tif", "image")),
+		),
+		fs.WithDir("staging"),
+	)
+	env := stageTestEnv(t, shared.Join("staging"))
+
+	future, err := env.ExecuteActivity(activities.StageName, &activities.StageParams{Path: shared.Join("sip")})
+	assert.NilError(t, err)
+
+	var res activities.StageResult
+	assert.NilError(t, future.Get(&res))
+	assert.Equal(t, res.Path, shared.Join("staging", "default-test-workflow-id", "default-test-run-id", "staged", "sip"))
+	assert.Equal(t, res.FileCount, 2)
+
+	// Process the staged copy, the SIP isn't modified.
+	assert.NilError(t, os.Remove(filepath.Join(res.Path, ".DS_Store")))
+	assert.Assert(t, fs.Equal(shared.Join("sip"), fs.Expected(t,
+		fs.WithFile(".DS_Store", ""),
+		fs.WithDir("objects", fs.WithFile("image.tif", "image")),
+		fs.MatchAnyFileMode,
+	)))
+
+	// A retried activity copies the SIP again.
+	_, err = env.ExecuteActivity(activities.StageName, &activities.StageParams{Path: shared.Join("sip")})
+	assert.NilError(t, err)
+	assert.NilError(t, os.Remove(filepath.Join(res.Path, ".DS_Store")))
+
+	_, err = env.ExecuteActivity(activities.PublishStageName, &activities.PublishStageParams{Path: shared.Join("sip")})
+	assert.NilError(t, err)
+	assert.Assert(t, fs.Equal(shared.Path(), fs.Expected(t,
+		fs.WithDir("sip", fs.WithDir("objects", fs.WithFile("image.tif", "image"))),
+		fs.WithDir("staging"),
+		fs.MatchAnyFileMode,
+	)))
+
+	// A retried publication doesn't modify the published SIP.
+	_, err = env.ExecuteActivity(activities.PublishStageName, &activities.PublishStageParams{Path: shared.Join("sip")})
+	assert.NilError(t, err)
+	assert.Assert(t, fs.Equal(shared.Join("sip"), fs.Expected(t,
+		fs.WithDir("objects", fs.WithFile("image.tif", "image")),
+		fs.MatchAnyFileMode,
+	)))
+}
+
+func TestPublishStageResumes(t *testing.T) {
+	t.Parallel()
+
+	// The SIP was moved to the staging directory by an interrupted
+	// publication.
+	shared := fs.NewDir(t, "",
+		fs.WithDir("staging",
+			fs.WithDir("default-test-workflow-id",
+				fs.WithDir("default-test-run-id",
+					fs.WithDir("staged", fs.WithDir("sip", fs.WithFile("processed.txt", "")),
+						fs.WithFile(".preprocessing-staged", "")),
+					fs.WithDir("original", fs.WithDir("sip", fs.WithFile("original.txt", ""))),
+				),
+			),
+		),
+	)
+	env := stageTestEnv(t, shared.Join("staging"))
+
+	_, err := env.ExecuteActivity(activities.PublishStageName, &activities.PublishStageParams{Path: shared.Join("sip")})
+	assert.NilError(t, err)
+	assert.Assert(t, fs.Equal(shared.Path(), fs.Expected(t,
+		fs.WithDir("sip", fs.WithFile("processed.txt", "")),
+		fs.WithDir("staging"),
+		fs.MatchAnyFileMode,
+	)))
+}
+
+func TestDiscardStage(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Deletes the staged copy", func(t *testing.T) {
+		t.Parallel()
+
+		shared := fs.NewDir(t, "", fs.WithDir("sip", fs.WithFile("original.txt", "")), fs.WithDir("staging"))
+		env := stageTestEnv(t, shared.Join("staging"))
+
+		_, err := env.ExecuteActivity(activities.StageName, &activities.StageParams{Path: shared.Join("sip")})
+		assert.NilError(t, err)
+
+		_, err = env.ExecuteActivity(activities.DiscardStageName, &activities.DiscardStageParams{Path: shared.Join("sip")})
+		assert.NilError(t, err)
+		assert.Assert(t, fs.Equal(shared.Path(), fs.Expected(t,
+			fs.WithDir("sip", fs.WithFile("original.txt", "")),
+			fs.WithDir("staging"),
+			fs.MatchAnyFileMode,
+		)))
+	})
+
+	t.Run("Restores the SIP moved by an interrupted publication", func(t *testing.T) {
+		t.Parallel()
+
+		shared := fs.NewDir(t, "",
+			fs.WithDir("staging",
+				fs.WithDir("default-test-workflow-id",
+					fs.WithDir("default-test-run-id",
+						fs.WithDir("staged", fs.WithDir("sip", fs.WithFile("processed.txt", ""))),
+						fs.WithDir("original", fs.WithDir("sip", fs.WithFile("original.txt", ""))),
+					),
+				),
+			),
+		)
+		env := stageTestEnv(t, shared.Join("staging"))
+
+		_, err := env.ExecuteActivity(activities.DiscardStageName, &activities.DiscardStageParams{Path: shared.Join("sip")})
+		assert.NilError(t, err)
+		assert.Assert(t, fs.Equal(shared.Path(), fs.Expected(t,
+			fs.WithDir("sip", fs.WithFile("original.txt", "")),
+			fs.WithDir("staging"),
+			fs.MatchAnyFileMode,
+		)))
+	})
+
+	t.Run("Keeps the SIP replaced by the staged copy", func(t *testing.T) {
+		t.Parallel()
+
+		shared := fs.NewDir(t, "",
+			fs.WithDir("sip", fs.WithFile("processed.txt", ""), fs.WithFile(".preprocessing-staged", "")),
+			fs.WithDir("staging",
+				fs.WithDir("default-test-workflow-id",
+					fs.WithDir("default-test-run-id",
+						fs.WithDir("staged", fs.WithDir("sip", fs.WithFile("original.txt", ""))),
+					),
+				),
+			),
+		)
+		env := stageTestEnv(t, shared.Join("staging"))
+
+		_, err := env.ExecuteActivity(activities.DiscardStageName, &activities.DiscardStageParams{Path: shared.Join("sip")})
+		assert.ErrorContains(t, err, "already replaced by its staged copy")
+		assert.Assert(t, fs.Equal(shared.Join("staging", "default-test-workflow-id", "default-test-run-id"), fs.Expected(t,
+			fs.WithDir("staged", fs.WithDir("sip", fs.WithFile("original.txt", ""))),
+			fs.MatchAnyFileMode,
+		)))
+	})
+}
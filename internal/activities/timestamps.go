@@ -0,0 +1,251 @@
+package activities
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	temporalsdk_activity "go.temporal.io/sdk/activity"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/fsutil"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/journal"
+)
+
+const (
+	CaptureTimestampsName = "capture-timestamps"
+	RestoreTimestampsName = "restore-timestamps"
+)
+
+// TimestampsFile is the file of the timestamps captured at intake, relative
+// to the SIP.
+const TimestampsFile = "metadata/preprocessing-timestamps.json"
+
+// FileTimestamps are the timestamps of a file or directory of the SIP captured
+// at intake.
+type FileTimestamps struct {
+	// Path is the file path, relative to the SIP, or "." for the SIP
+	// directory.
+	Path string
+
+	Dir bool `json:",omitempty"`
+
+	ModTime time.Time
+
+	// AccessTime and ChangeTime are nil when the platform doesn't provide
+	// them.
+	AccessTime *time.Time `json:",omitempty"`
+	ChangeTime *time.Time `json:",omitempty"`
+}
+
+type CaptureTimestampsParams struct {
+	// Path is the SIP directory, the TimestampsFile is written into it.
+	Path string
+
+	// SourcePath is the directory the timestamps are read from, e.g. the SIP
+	// of a staged copy in Path (default: Path).
+	SourcePath string `json:",omitempty"`
+
+	// Journal records the TimestampsFile written in the journal of the
+	// workflow run, so it's removed by a rollback.
+	Journal bool `json:",omitempty"`
+}
+
+type CaptureTimestampsResult struct {
+	// Count is the number of files and directories captured.
+	Count int
+}
+
+type CaptureTimestamps struct {
+	journals *journal.Store
+}
+
+// NewCaptureTimestamps returns a CaptureTimestamps activity recording the
+// TimestampsFile it writes in the journal of the workflow run in journals.
+func NewCaptureTimestamps(journals *journal.Store) *CaptureTimestamps {
+	return &CaptureTimestamps{journals: journals}
+}
+
+// Execute writes the modification, access and status change times of the
+// regular files and directories of params.SourcePath in the TimestampsFile of
+// params.Path. The other file types are ignored.
+//
+// The activity heartbeats the number of entries walked so far, a retried
+// activity starts again and overwrites the TimestampsFile.
+func (a *CaptureTimestamps) Execute(ctx context.Context, params *CaptureTimestampsParams) (*CaptureTimestampsResult, error) {
+	src := params.SourcePath
+	if src == "" {
+		src = params.Path
+	}
+
+	var captured []FileTimestamps
+	var progress Progress
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == TimestampsFile || (!d.IsDir() && !d.Type().IsRegular()) {
+			// Skip the file of a previous attempt.
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		ts := FileTimestamps{Path: rel, Dir: d.IsDir(), ModTime: fi.ModTime().UTC()}
+		if atime, ctime, ok := fsutil.Times(fi); ok {
+			atime, ctime = atime.UTC(), ctime.UTC()
+			ts.AccessTime, ts.ChangeTime = &atime, &ctime
+		}
+		captured = append(captured, ts)
+
+		progress.LastPath = rel
+		progress.Walked++
+		temporalsdk_activity.RecordHeartbeat(ctx, progress)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fsError(fmt.Errorf("capture timestamps: %w", err))
+	}
+
+	b, err := json.MarshalIndent(captured, "", "  ")
+	if err != nil {
+		return nil, invalidContentError(fmt.Errorf("capture timestamps: %v", err))
+	}
+
+	j, err := journalPaths(ctx, a.journals, params.Journal, params.Path)
+	if err != nil {
+		return nil, err
+	}
+	dest := filepath.Join(params.Path, filepath.FromSlash(TimestampsFile))
+	if err := j.MkdirAll(filepath.Dir(dest), 0o700); err != nil {
+		return nil, fsError(fmt.Errorf("capture timestamps: %w", err))
+	}
+	if err := j.WriteFile(dest, append(b, '\n'), 0o600); err != nil {
+		return nil, fsError(fmt.Errorf("capture timestamps: %w", err))
+	}
+
+	return &CaptureTimestampsResult{Count: len(captured)}, nil
+}
+
+type RestoreTimestampsParams struct {
+	// Path is the SIP directory, with the TimestampsFile.
+	Path string
+}
+
+type RestoreTimestampsResult struct {
+	// Restored is the number of modification times restored.
+	Restored int
+
+	// Unchanged is the number of modification times that didn't change.
+	Unchanged int
+
+	// Missing is the number of captured files that don't exist anymore, e.g.
+	// the removed unwanted files.
+	Missing int
+
+	// Failed lists the files whose modification time couldn't be restored.
+	Failed []TimestampError `json:",omitempty"`
+}
+
+// TimestampError describes why the modification time of a file couldn't be
+// restored.
+type TimestampError struct {
+	// Path is the file path, relative to the SIP.
+	Path string
+
+	Error string
+}
+
+type RestoreTimestamps struct{}
+
+// NewRestoreTimestamps returns a RestoreTimestamps activity.
+func NewRestoreTimestamps() *RestoreTimestamps {
+	return &RestoreTimestamps{}
+}
+
+// Execute sets the modification times of the files and directories of
+// params.Path back to the times captured in its TimestampsFile, the
+// directories after their content. The files that can't be restored are
+// listed in the result, they don't fail the activity.
+//
+// The activity heartbeats the number of files processed so far.
+func (a *RestoreTimestamps) Execute(ctx context.Context, params *RestoreTimestampsParams) (*RestoreTimestampsResult, error) {
+	b, err := os.ReadFile(filepath.Join(params.Path, filepath.FromSlash(TimestampsFile)))
+	if err != nil {
+		return nil, fsError(fmt.Errorf("restore timestamps: %w", err))
+	}
+	var captured []FileTimestamps
+	if err := json.Unmarshal(b, &captured); err != nil {
+		return nil, invalidContentError(fmt.Errorf("restore timestamps: %s: %v", TimestampsFile, err))
+	}
+
+	// Restore the files first, then the directories starting with the
+	// deepest ones, so their times aren't changed by the restore of their
+	// content.
+	depth := func(ts FileTimestamps) int {
+		if ts.Path == "." {
+			return 0
+		}
+		return strings.Count(ts.Path, "/") + 1
+	}
+	sort.SliceStable(captured, func(i, j int) bool {
+		a, b := captured[i], captured[j]
+		if a.Dir != b.Dir {
+			return !a.Dir
+		}
+		return a.Dir && depth(a) > depth(b)
+	})
+
+	res := &RestoreTimestampsResult{}
+	var progress Progress
+	for _, ts := range captured {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		path := filepath.Join(params.Path, filepath.FromSlash(ts.Path))
+		fi, err := os.Lstat(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			res.Missing++
+		case err != nil:
+			res.Failed = append(res.Failed, TimestampError{Path: ts.Path, Error: err.Error()})
+		case fi.ModTime().Equal(ts.ModTime):
+			res.Unchanged++
+		case fi.IsDir() != ts.Dir:
+			res.Failed = append(res.Failed, TimestampError{Path: ts.Path, Error: "file type changed"})
+		default:
+			// The zero access time is left unchanged.
+			if err := os.Chtimes(path, time.Time{}, ts.ModTime); err != nil {
+				res.Failed = append(res.Failed, TimestampError{Path: ts.Path, Error: err.Error()})
+				break
+			}
+			res.Restored++
+			progress.Count++
+		}
+
+		progress.LastPath = ts.Path
+		progress.Walked++
+		temporalsdk_activity.RecordHeartbeat(ctx, progress)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,84 @@
+package activities_test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	temporalsdk_activity "go.temporal.io/sdk/activity"
+	temporalsdk_testsuite "go.temporal.io/sdk/testsuite"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/activities"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/journal"
+)
+
+func TestTimestamps(t *testing.T) {
+	t.Parallel()
+
+	old := time.Date(2003, 7, 14, 18, 30, 0, 0, time.UTC)
+	dir := fs.NewDir(t, "",
+		fs.WithFile(".DS_Store", "", fs.WithTimestamps(old, old)),
+		fs.WithDir("objects",
+			fs.WithFile("a.mov", "a", fs.WithTimestamps(old, old)),
+			fs.WithFile("b.mov", "b", fs.WithTimestamps(old, old)),
+			fs.WithFile("c.mov", "c", fs.WithTimestamps(old, old)),
+		),
+	)
+	assert.NilError(t, os.Chtimes(dir.Join("objects"), old, old))
+
+	ts := &temporalsdk_testsuite.WorkflowTestSuite{}
+	env := ts.NewTestActivityEnvironment()
+	env.RegisterActivityWithOptions(
+		activities.NewCaptureTimestamps(journal.NewStore(t.TempDir())).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.CaptureTimestampsName},
+	)
+	env.RegisterActivityWithOptions(
+		activities.NewRestoreTimestamps().Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.RestoreTimestampsName},
+	)
+
+	future, err := env.ExecuteActivity(activities.CaptureTimestampsName, &activities.CaptureTimestampsParams{Path: dir.Path()})
+	assert.NilError(t, err)
+	var captureRes activities.CaptureTimestampsResult
+	_ = future.Get(&captureRes)
+	assert.Equal(t, captureRes.Count, 6)
+
+	b, err := os.ReadFile(dir.Join("metadata", "preprocessing-timestamps.json"))
+	assert.NilError(t, err)
+	var captured []activities.FileTimestamps
+	assert.NilError(t, json.Unmarshal(b, &captured))
+	assert.Equal(t, len(captured), 6)
+	assert.Equal(t, captured[0].Path, ".")
+	assert.Equal(t, captured[1].Path, ".DS_Store")
+	assert.Assert(t, captured[1].ModTime.Equal(old))
+	assert.Assert(t, captured[1].AccessTime != nil && captured[1].ChangeTime != nil)
+
+	// Transform the SIP: a file is modified, one is removed and one is
+	// replaced by a broken symbolic link.
+	assert.NilError(t, os.Remove(dir.Join(".DS_Store")))
+	assert.NilError(t, os.WriteFile(dir.Join("objects", "a.mov"), []byte("A"), 0o600))
+	assert.NilError(t, os.Remove(dir.Join("objects", "c.mov")))
+	assert.NilError(t, os.Symlink("missing", dir.Join("objects", "c.mov")))
+
+	future, err = env.ExecuteActivity(activities.RestoreTimestampsName, &activities.RestoreTimestampsParams{Path: dir.Path()})
+	assert.NilError(t, err)
+	var res activities.RestoreTimestampsResult
+	_ = future.Get(&res)
+	assert.Equal(t, res.Restored, 3) // objects/a.mov, objects and the SIP.
+	assert.Equal(t, res.Unchanged, 1)
+	assert.Equal(t, res.Missing, 1)
+	assert.Equal(t, len(res.Failed), 1)
+	assert.Equal(t, res.Failed[0].Path, "objects/c.mov")
+
+	for _, path := range []string{"objects/a.mov", "objects/b.mov", "objects"} {
+		fi, err := os.Stat(dir.Join(path))
+		assert.NilError(t, err)
+		assert.Assert(t, fi.ModTime().Equal(old), "%s: %s", path, fi.ModTime())
+	}
+	fi, err := os.Stat(dir.Path())
+	assert.NilError(t, err)
+	assert.Assert(t, fi.ModTime().Equal(captured[0].ModTime))
+}
@@ -0,0 +1,202 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	temporalsdk_activity "go.temporal.io/sdk/activity"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/journal"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/report"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/results"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/version"
+)
+
+const WriteReportName = "write-report"
+
+// ReportDir is the directory of the preprocessing report, relative to the SIP,
+// so it's preserved in the AIP.
+const ReportDir = "metadata/submissionDocumentation"
+
+type WriteReportParams struct {
+	// Path is the SIP directory.
+	Path string
+
+	// Format is the report format, report.HTML or report.Markdown.
+	Format string
+
+	// Report is the report of the workflow steps, the activity adds the
+	// format summary, the checksums and the worker version.
+	Report report.Report
+
+	// RemovedRef references the list of the removed files in the results
+	// store, the activity adds them to the Report.
+	RemovedRef *results.Ref `json:",omitempty"`
+
+	// Journal records the report written in the journal of the workflow run,
+	// so it's removed by a rollback.
+	Journal bool `json:",omitempty"`
+}
+
+type WriteReportResult struct {
+	// Path is the report path, relative to the SIP directory.
+	Path string
+
+	// InventoryRef references the inventory of the SIP files, with their size
+	// and checksum, in the results store.
+	InventoryRef *results.Ref `json:",omitempty"`
+
+	// FileCount and TotalBytes are the number and total size of the files in
+	// the inventory.
+	FileCount  int   `json:",omitempty"`
+	TotalBytes int64 `json:",omitempty"`
+}
+
+type WriteReport struct {
+	store    *results.Store
+	journals *journal.Store
+}
+
+// NewWriteReport returns a WriteReport activity loading the list of removed
+// files from, and writing the SIP inventory to, store. The creation of the
+// report is recorded in the journal of the workflow run in journals.
+func NewWriteReport(store *results.Store, journals *journal.Store) *WriteReport {
+	return &WriteReport{store: store, journals: journals}
+}
+
+// Execute writes the preprocessing report in the ReportDir of params.Path. An
+// existing report is moved to the trash of the workflow run.
+//
+// The activity heartbeats the number of files checksummed so far.
+func (a *WriteReport) Execute(ctx context.Context, params *WriteReportParams) (*WriteReportResult, error) {
+	r := params.Report
+	r.GeneratedAt = time.Now()
+	r.Version = version.Long
+	r.GitCommit = version.GitCommit
+
+	if params.RemovedRef != nil {
+		err := a.store.Load(params.RemovedRef, &r.Removed)
+		if errors.Is(err, results.ErrDigestMismatch) {
+			return nil, invalidContentError(fmt.Errorf("write report: %v", err))
+		}
+		if err != nil {
+			return nil, fsError(fmt.Errorf("write report: %w", err))
+		}
+	}
+
+	rel := filepath.Join(ReportDir, report.Filename(params.Format))
+
+	formats := map[string]*report.Format{}
+	var progress Progress
+	err := filepath.WalkDir(params.Path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		p, err := filepath.Rel(params.Path, path)
+		if err != nil {
+			return err
+		}
+		if p == rel {
+			// Skip the report of a previous attempt.
+			return nil
+		}
+
+		f, err := checksum(path)
+		if err != nil {
+			return err
+		}
+		f.Path = filepath.ToSlash(p)
+		r.Files = append(r.Files, f)
+
+		ext := strings.ToLower(filepath.Ext(path))
+		if formats[ext] == nil {
+			formats[ext] = &report.Format{Extension: ext}
+		}
+		formats[ext].Count++
+		formats[ext].Size += f.Size
+
+		progress.LastPath = p
+		progress.Walked++
+		temporalsdk_activity.RecordHeartbeat(ctx, progress)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fsError(fmt.Errorf("write report: %w", err))
+	}
+
+	for _, f := range formats {
+		r.Formats = append(r.Formats, *f)
+	}
+	sort.Slice(r.Formats, func(i, j int) bool {
+		return r.Formats[i].Extension < r.Formats[j].Extension
+	})
+
+	var buf bytes.Buffer
+	if err := report.Write(&buf, params.Format, &r); err != nil {
+		return nil, invalidContentError(fmt.Errorf("write report: %v", err))
+	}
+
+	j, err := journalPaths(ctx, a.journals, params.Journal, params.Path)
+	if err != nil {
+		return nil, err
+	}
+	dest := filepath.Join(params.Path, rel)
+	if err := j.MkdirAll(filepath.Dir(dest), 0o700); err != nil {
+		return nil, fsError(fmt.Errorf("write report: %w", err))
+	}
+	if err := j.WriteFile(dest, buf.Bytes(), 0o600); err != nil {
+		return nil, fsError(fmt.Errorf("write report: %w", err))
+	}
+
+	inventory, err := a.store.Write(resultName(ctx, inventoryResult), r.Files)
+	if err != nil {
+		return nil, fsError(fmt.Errorf("write report: %w", err))
+	}
+
+	res := &WriteReportResult{
+		Path:         filepath.ToSlash(rel),
+		InventoryRef: inventory,
+		FileCount:    len(r.Files),
+	}
+	for _, f := range r.Files {
+		res.TotalBytes += f.Size
+	}
+
+	return res, nil
+}
+
+// checksum returns the size and SHA-256 checksum of the path file.
+func checksum(path string) (report.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return report.File{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return report.File{}, err
+	}
+
+	return report.File{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
@@ -0,0 +1,86 @@
+package activities_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	temporalsdk_activity "go.temporal.io/sdk/activity"
+	temporalsdk_testsuite "go.temporal.io/sdk/testsuite"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/activities"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/report"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/results"
+)
+
+func TestWriteReport(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range report.Formats {
+		t.Run(format, func(t *testing.T) {
+			t.Parallel()
+
+			dir := fs.NewDir(t, "",
+				fs.WithFile("small.txt", "I am a small file.\n"),
+				fs.WithDir("objects", fs.WithFile("image.TIF", "image")),
+			)
+
+			store := results.NewStore(t.TempDir())
+			removed, err := store.Write("removed-files.json", []string{".DS_Store"})
+			assert.NilError(t, err)
+
+			ts := &temporalsdk_testsuite.WorkflowTestSuite{}
+			env := ts.NewTestActivityEnvironment()
+			env.RegisterActivityWithOptions(
+				activities.NewWriteReport(store, nil).Execute,
+				temporalsdk_activity.RegisterOptions{Name: activities.WriteReportName},
+			)
+
+			params := &activities.WriteReportParams{
+				Path:       dir.Path(),
+				Format:     format,
+				Report:     report.Report{SIP: "sip"},
+				RemovedRef: removed,
+			}
+			future, err := env.ExecuteActivity(activities.WriteReportName, params)
+			assert.NilError(t, err)
+
+			var res activities.WriteReportResult
+			_ = future.Get(&res)
+			assert.Equal(t, res.Path, "metadata/submissionDocumentation/"+report.Filename(format))
+			assert.Equal(t, res.FileCount, 2)
+			assert.Equal(t, res.TotalBytes, int64(24))
+
+			b, err := os.ReadFile(dir.Join(res.Path))
+			assert.NilError(t, err)
+			content := string(b)
+			for _, s := range []string{
+				// SHA-256 of small.txt.
+				"4450c8a88130a3b397bfc659245c4f0f87a8c79d017a60bdb1bd32f4b51c8133",
+				".DS",
+				"small.txt",
+				"image.TIF",
+				".tif",
+			} {
+				assert.Assert(t, strings.Contains(content, s), "report doesn't contain %q", s)
+			}
+
+			// The SIP inventory is written to the results store.
+			var inventory []report.File
+			assert.NilError(t, store.Load(res.InventoryRef, &inventory))
+			assert.DeepEqual(t, inventory, []report.File{
+				{Path: "objects/image.TIF", Size: 5, SHA256: "6105d6cc76af400325e94d588ce511be5bfdbb73b437dc51eca43917d7a43e3d"},
+				{Path: "small.txt", Size: 19, SHA256: "4450c8a88130a3b397bfc659245c4f0f87a8c79d017a60bdb1bd32f4b51c8133"},
+			})
+
+			// A new attempt overwrites the report without checksumming it.
+			_, err = env.ExecuteActivity(activities.WriteReportName, params)
+			assert.NilError(t, err)
+			b, err = os.ReadFile(dir.Join(res.Path))
+			assert.NilError(t, err)
+			assert.Assert(t, !strings.Contains(string(b), "preprocessing-report"))
+		})
+	}
+}
@@ -0,0 +1,266 @@
+// Package appledouble parses the AppleDouble files (e.g. "._name") written by
+// macOS to keep the Finder metadata and the extended attributes of a file on
+// the filesystems that can't store them.
+package appledouble
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Prefix is the name prefix of the AppleDouble file of a file, in the same
+// directory.
+const Prefix = "._"
+
+const (
+	magic     = 0x00051607
+	version1  = 0x00010000
+	version2  = 0x00020000
+	headerLen = 26
+	entryLen  = 12
+
+	attrMagic     = 0x41545452 // "ATTR"
+	attrHeaderLen = 36
+	attrEntryLen  = 11
+
+	finderInfoLen = 32
+)
+
+// Entry IDs.
+const (
+	idResourceFork = 2
+	idRealName     = 3
+	idComment      = 4
+	idFileDates    = 8
+	idFinderInfo   = 9
+)
+
+// ErrNotAppleDouble is returned by Parse when the data isn't an AppleDouble
+// file.
+var ErrNotAppleDouble = errors.New("not an AppleDouble file")
+
+// dateEpoch is the origin of the AppleDouble file dates.
+var dateEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// File is the metadata recorded in an AppleDouble file.
+type File struct {
+	// RealName is the name of the file on the Mac.
+	RealName string `json:",omitempty"`
+
+	// Comment is the Finder comment of the file.
+	Comment string `json:",omitempty"`
+
+	Dates      *Dates      `json:",omitempty"`
+	FinderInfo *FinderInfo `json:",omitempty"`
+
+	// ResourceForkSize is the size of the resource fork of the file, its
+	// content isn't parsed.
+	ResourceForkSize int `json:",omitempty"`
+
+	// Attrs lists the extended attributes of the file, e.g. the Finder tags
+	// ("com.apple.metadata:_kMDItemUserTags") or comment
+	// ("com.apple.metadata:kMDItemFinderComment").
+	Attrs []Attr `json:",omitempty"`
+}
+
+// Dates are the dates of a file, the unknown dates are nil.
+type Dates struct {
+	Created  *time.Time `json:",omitempty"`
+	Modified *time.Time `json:",omitempty"`
+	Backup   *time.Time `json:",omitempty"`
+	Accessed *time.Time `json:",omitempty"`
+}
+
+// FinderInfo is the Finder information of a file.
+type FinderInfo struct {
+	// Type and Creator are the classic Mac OS type and creator codes.
+	Type    string `json:",omitempty"`
+	Creator string `json:",omitempty"`
+
+	// Flags are the Finder flags, e.g. 0x4000 for an invisible file.
+	Flags uint16 `json:",omitempty"`
+
+	// Label is the Finder color label, from 1 to 7, or 0 without label.
+	Label int `json:",omitempty"`
+}
+
+// Attr is an extended attribute.
+type Attr struct {
+	Name  string
+	Value []byte
+}
+
+// MarshalJSON encodes the attribute with its Decode value, or with its raw
+// value in base64 if it can't be decoded.
+func (a Attr) MarshalJSON() ([]byte, error) {
+	v := struct {
+		Name   string
+		Value  any    `json:",omitempty"`
+		Base64 string `json:",omitempty"`
+	}{Name: a.Name}
+	if d, ok := a.Decode(); ok {
+		v.Value = d
+	} else {
+		v.Base64 = base64.StdEncoding.EncodeToString(a.Value)
+	}
+
+	return json.Marshal(v)
+}
+
+// Decode returns the value of the attribute decoded from a binary property
+// list, or as a string if it's text. It reports false if the value is binary
+// data.
+func (a Attr) Decode() (any, bool) {
+	if bytes.HasPrefix(a.Value, []byte(plistMagic)) {
+		if v, err := decodePlist(a.Value); err == nil {
+			return v, true
+		}
+		return nil, false
+	}
+
+	s := strings.TrimRight(string(a.Value), "\x00")
+	if s == "" || !utf8.ValidString(s) {
+		return nil, false
+	}
+	for _, r := range s {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return nil, false
+		}
+	}
+
+	return s, true
+}
+
+// Parse parses the AppleDouble file data. It returns ErrNotAppleDouble if data
+// isn't an AppleDouble file, or an error if it's malformed.
+func Parse(data []byte) (*File, error) {
+	if len(data) < headerLen || binary.BigEndian.Uint32(data) != magic {
+		return nil, ErrNotAppleDouble
+	}
+	if v := binary.BigEndian.Uint32(data[4:]); v != version1 && v != version2 {
+		return nil, fmt.Errorf("appledouble: unsupported version %#x", v)
+	}
+
+	n := int(binary.BigEndian.Uint16(data[24:]))
+	if len(data) < headerLen+n*entryLen {
+		return nil, errors.New("appledouble: truncated entries")
+	}
+
+	f := &File{}
+	for i := 0; i < n; i++ {
+		e := data[headerLen+i*entryLen:]
+		id := binary.BigEndian.Uint32(e)
+		off := int64(binary.BigEndian.Uint32(e[4:]))
+		length := int64(binary.BigEndian.Uint32(e[8:]))
+		if off+length > int64(len(data)) {
+			return nil, fmt.Errorf("appledouble: entry %d out of bounds", id)
+		}
+		b := data[off : off+length]
+
+		switch id {
+		case idResourceFork:
+			f.ResourceForkSize = len(b)
+		case idRealName:
+			f.RealName = string(b)
+		case idComment:
+			f.Comment = string(b)
+		case idFileDates:
+			f.Dates = parseDates(b)
+		case idFinderInfo:
+			if len(b) < finderInfoLen {
+				return nil, errors.New("appledouble: truncated Finder info")
+			}
+			f.FinderInfo = parseFinderInfo(b)
+			attrs, err := parseAttrs(data, int(off)+finderInfoLen)
+			if err != nil {
+				return nil, err
+			}
+			f.Attrs = attrs
+		}
+	}
+
+	return f, nil
+}
+
+func parseDates(b []byte) *Dates {
+	if len(b) < 16 {
+		return nil
+	}
+	date := func(b []byte) *time.Time {
+		v := int32(binary.BigEndian.Uint32(b))
+		if v == -1<<31 {
+			return nil
+		}
+		t := dateEpoch.Add(time.Duration(v) * time.Second)
+		return &t
+	}
+
+	return &Dates{
+		Created:  date(b),
+		Modified: date(b[4:]),
+		Backup:   date(b[8:]),
+		Accessed: date(b[12:]),
+	}
+}
+
+func parseFinderInfo(b []byte) *FinderInfo {
+	if bytes.Equal(b[:finderInfoLen], make([]byte, finderInfoLen)) {
+		return nil
+	}
+	code := func(b []byte) string {
+		s := strings.TrimRight(string(b), "\x00")
+		for _, r := range s {
+			if r < 0x20 || r > 0x7e {
+				return ""
+			}
+		}
+		return s
+	}
+	flags := binary.BigEndian.Uint16(b[8:])
+
+	return &FinderInfo{
+		Type:    code(b[:4]),
+		Creator: code(b[4:8]),
+		Flags:   flags,
+		Label:   int(flags&0x000e) >> 1,
+	}
+}
+
+// parseAttrs parses the extended attributes stored by macOS after the Finder
+// info, in the "ATTR" header starting two padding bytes after off.
+func parseAttrs(data []byte, off int) ([]Attr, error) {
+	off += 2
+	if len(data) < off+attrHeaderLen || binary.BigEndian.Uint32(data[off:]) != attrMagic {
+		return nil, nil
+	}
+
+	n := int(binary.BigEndian.Uint16(data[off+34:]))
+	attrs := make([]Attr, 0, n)
+	p := off + attrHeaderLen
+	for i := 0; i < n; i++ {
+		if len(data) < p+attrEntryLen {
+			return nil, errors.New("appledouble: truncated extended attributes")
+		}
+		valueOff := int64(binary.BigEndian.Uint32(data[p:]))
+		valueLen := int64(binary.BigEndian.Uint32(data[p+4:]))
+		nameLen := int(data[p+10])
+		if len(data) < p+attrEntryLen+nameLen || valueOff+valueLen > int64(len(data)) {
+			return nil, errors.New("appledouble: extended attribute out of bounds")
+		}
+		name := strings.TrimRight(string(data[p+attrEntryLen:p+attrEntryLen+nameLen]), "\x00")
+		attrs = append(attrs, Attr{Name: name, Value: data[valueOff : valueOff+valueLen]})
+
+		// The entries are aligned on 4 bytes.
+		p = (p + attrEntryLen + nameLen + 3) &^ 3
+	}
+
+	return attrs, nil
+}
@@ -0,0 +1,188 @@
+package appledouble_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/appledouble"
+)
+
+// tagsPlist is the binary property list of the ["Red\n6"] Finder tags.
+var tagsPlist = []byte(
+	"bplist00" +
+		"\xa1\x01" + // Array of one object: 1.
+		"\x55Red\n6" + // ASCII string of 5 bytes.
+		"\x08\x0a" + // Offset table.
+		"\x00\x00\x00\x00\x00\x00\x01\x01" +
+		"\x00\x00\x00\x00\x00\x00\x00\x02" +
+		"\x00\x00\x00\x00\x00\x00\x00\x00" +
+		"\x00\x00\x00\x00\x00\x00\x00\x10",
+)
+
+// build returns an AppleDouble file with the Finder info and attrs in its
+// Finder info entry, followed by the other entries.
+func build(finderInfo []byte, attrs []appledouble.Attr, entries map[uint32][]byte) []byte {
+	ids := []uint32{9}
+	for _, id := range []uint32{2, 3, 4, 8} {
+		if entries[id] != nil {
+			ids = append(ids, id)
+		}
+	}
+
+	be := binary.BigEndian
+	header := make([]byte, 26+12*len(ids))
+	be.PutUint32(header, 0x00051607)
+	be.PutUint32(header[4:], 0x00020000)
+	be.PutUint16(header[24:], uint16(len(ids)))
+
+	// The Finder info, with the extended attributes after two padding bytes.
+	fi := append(append([]byte{}, finderInfo...), 0, 0)
+	if len(attrs) > 0 {
+		start := len(header) + len(fi)
+		attrHeader := make([]byte, 36)
+		be.PutUint32(attrHeader, 0x41545452)
+		be.PutUint16(attrHeader[34:], uint16(len(attrs)))
+
+		var table []byte
+		for _, a := range attrs {
+			e := make([]byte, 11+len(a.Name)+1)
+			e[10] = byte(len(a.Name) + 1)
+			copy(e[11:], a.Name)
+			for (start+36+len(table)+len(e))%4 != 0 {
+				e = append(e, 0)
+			}
+			table = append(table, e...)
+		}
+		valueOff := start + 36 + len(table)
+		var values []byte
+		p := 0
+		for _, a := range attrs {
+			be.PutUint32(table[p:], uint32(valueOff+len(values)))
+			be.PutUint32(table[p+4:], uint32(len(a.Value)))
+			values = append(values, a.Value...)
+			p += 11 + len(a.Name) + 1
+			for (start+36+p)%4 != 0 {
+				p++
+			}
+		}
+		fi = append(append(append(fi, attrHeader...), table...), values...)
+	}
+
+	data := append([]byte{}, header...)
+	for i, id := range ids {
+		b := fi
+		if id != 9 {
+			b = entries[id]
+		}
+		e := header[26+12*i:]
+		be.PutUint32(e, id)
+		be.PutUint32(e[4:], uint32(len(data)))
+		be.PutUint32(e[8:], uint32(len(b)))
+		data = append(data, b...)
+	}
+	copy(data, header)
+
+	return data
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	finderInfo := make([]byte, 32)
+	copy(finderInfo, "TIFF8BIM")
+	binary.BigEndian.PutUint16(finderInfo[8:], 0x000c) // Label 6.
+
+	dates := make([]byte, 16)
+	binary.BigEndian.PutUint32(dates, uint32(86400))
+	binary.BigEndian.PutUint32(dates[4:], uint32(172800))
+	binary.BigEndian.PutUint32(dates[8:], 0x80000000)
+	binary.BigEndian.PutUint32(dates[12:], 0x80000000)
+
+	data := build(
+		finderInfo,
+		[]appledouble.Attr{
+			{Name: "com.apple.metadata:_kMDItemUserTags", Value: tagsPlist},
+			{Name: "com.apple.metadata:kMDItemFinderComment", Value: []byte("Scanned by the conservator\x00")},
+			{Name: "com.apple.quarantine", Value: []byte{0x01, 0x02}},
+		},
+		map[uint32][]byte{
+			2: make([]byte, 286),
+			4: []byte("Finder comment"),
+			8: dates,
+		},
+	)
+
+	f, err := appledouble.Parse(data)
+	assert.NilError(t, err)
+
+	created := time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+	modified := time.Date(2000, 1, 3, 0, 0, 0, 0, time.UTC)
+	assert.DeepEqual(t, f, &appledouble.File{
+		Comment: "Finder comment",
+		Dates:   &appledouble.Dates{Created: &created, Modified: &modified},
+		FinderInfo: &appledouble.FinderInfo{
+			Type:    "TIFF",
+			Creator: "8BIM",
+			Flags:   0x000c,
+			Label:   6,
+		},
+		ResourceForkSize: 286,
+		Attrs: []appledouble.Attr{
+			{Name: "com.apple.metadata:_kMDItemUserTags", Value: tagsPlist},
+			{Name: "com.apple.metadata:kMDItemFinderComment", Value: []byte("Scanned by the conservator\x00")},
+			{Name: "com.apple.quarantine", Value: []byte{0x01, 0x02}},
+		},
+	})
+
+	b, err := json.Marshal(f.Attrs)
+	assert.NilError(t, err)
+	assert.Equal(t, string(b), `[`+
+		`{"Name":"com.apple.metadata:_kMDItemUserTags","Value":["Red\n6"]},`+
+		`{"Name":"com.apple.metadata:kMDItemFinderComment","Value":"Scanned by the conservator"},`+
+		`{"Name":"com.apple.quarantine","Base64":"AQI="}`+
+		`]`)
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+
+	valid := build(make([]byte, 32), nil, map[uint32][]byte{4: []byte("comment")})
+
+	for _, tc := range []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{
+			name:    "Rejects a file without the AppleDouble magic number",
+			data:    []byte("\x00\x05\x16\x00 AppleSingle file header"),
+			wantErr: appledouble.ErrNotAppleDouble.Error(),
+		},
+		{
+			name:    "Rejects a short file",
+			data:    []byte{0x00, 0x05, 0x16, 0x07},
+			wantErr: appledouble.ErrNotAppleDouble.Error(),
+		},
+		{
+			name:    "Rejects a truncated file",
+			data:    valid[:len(valid)-2],
+			wantErr: "appledouble: entry 4 out of bounds",
+		},
+		{
+			name:    "Rejects an unsupported version",
+			data:    append([]byte{0x00, 0x05, 0x16, 0x07, 0x00, 0x03, 0x00, 0x00}, valid[8:]...),
+			wantErr: "appledouble: unsupported version 0x30000",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := appledouble.Parse(tc.data)
+			assert.Error(t, err, tc.wantErr)
+		})
+	}
+}
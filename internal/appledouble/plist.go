@@ -0,0 +1,241 @@
+package appledouble
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf16"
+)
+
+// plistMagic is the header of the binary property lists, the format of most
+// of the macOS extended attributes values.
+const plistMagic = "bplist00"
+
+const (
+	plistTrailerLen = 32
+
+	// plistMaxDepth limits the nesting of the decoded objects, so a malformed
+	// list referencing its own objects can't recurse forever.
+	plistMaxDepth = 32
+)
+
+// plistEpoch is the origin of the binary property list dates.
+var plistEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var errPlist = errors.New("appledouble: malformed binary property list")
+
+type plist struct {
+	data    []byte
+	offsets []uint64
+	refSize int
+}
+
+// decodePlist decodes the binary property list data into nil, bool, int64,
+// float64, time.Time, []byte, string, []any and map[string]any values.
+func decodePlist(data []byte) (any, error) {
+	if len(data) < len(plistMagic)+plistTrailerLen {
+		return nil, errPlist
+	}
+	t := data[len(data)-plistTrailerLen:]
+	offSize := int(t[6])
+	refSize := int(t[7])
+	count := binary.BigEndian.Uint64(t[8:])
+	top := binary.BigEndian.Uint64(t[16:])
+	tableOff := binary.BigEndian.Uint64(t[24:])
+	if offSize < 1 || offSize > 8 || refSize < 1 || refSize > 8 || top >= count ||
+		tableOff > uint64(len(data)) || count > (uint64(len(data))-tableOff)/uint64(offSize) {
+		return nil, errPlist
+	}
+
+	p := &plist{data: data, refSize: refSize, offsets: make([]uint64, count)}
+	for i := range p.offsets {
+		p.offsets[i] = readUint(data[tableOff+uint64(i*offSize):], offSize)
+	}
+
+	return p.object(top, 0)
+}
+
+func readUint(b []byte, n int) uint64 {
+	var v uint64
+	for _, c := range b[:n] {
+		v = v<<8 | uint64(c)
+	}
+
+	return v
+}
+
+// bytes returns the n bytes at off.
+func (p *plist) bytes(off, n uint64) ([]byte, error) {
+	if off > uint64(len(p.data)) || n > uint64(len(p.data))-off {
+		return nil, errPlist
+	}
+
+	return p.data[off : off+n], nil
+}
+
+func (p *plist) object(ref uint64, depth int) (any, error) {
+	if ref >= uint64(len(p.offsets)) || depth > plistMaxDepth {
+		return nil, errPlist
+	}
+	off := p.offsets[ref]
+	b, err := p.bytes(off, 1)
+	if err != nil {
+		return nil, err
+	}
+	marker, info := b[0]>>4, uint64(b[0]&0x0f)
+	off++
+
+	switch marker {
+	case 0x0:
+		switch info {
+		case 0x0:
+			return nil, nil
+		case 0x8:
+			return false, nil
+		case 0x9:
+			return true, nil
+		}
+	case 0x1:
+		// The 16 bytes integers are stored in the last 8 bytes.
+		if info > 4 {
+			return nil, errPlist
+		}
+		b, err := p.bytes(off, uint64(1)<<info)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) == 16 {
+			b = b[8:]
+		}
+		return int64(readUint(b, len(b))), nil
+	case 0x2:
+		b, err := p.bytes(off, uint64(1)<<info)
+		if err != nil {
+			return nil, err
+		}
+		switch len(b) {
+		case 4:
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+		case 8:
+			return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+		}
+	case 0x3:
+		b, err := p.bytes(off, 8)
+		if err != nil || info != 0x3 {
+			return nil, errPlist
+		}
+		s := math.Float64frombits(binary.BigEndian.Uint64(b))
+		return plistEpoch.Add(time.Duration(s * float64(time.Second))), nil
+	case 0x4, 0x5, 0x6:
+		n, off, err := p.count(info, off)
+		if err != nil {
+			return nil, err
+		}
+		if marker == 0x6 {
+			b, err := p.bytes(off, 2*n)
+			if err != nil {
+				return nil, err
+			}
+			u := make([]uint16, n)
+			for i := range u {
+				u[i] = binary.BigEndian.Uint16(b[2*i:])
+			}
+			return string(utf16.Decode(u)), nil
+		}
+		b, err := p.bytes(off, n)
+		if err != nil {
+			return nil, err
+		}
+		if marker == 0x4 {
+			return b, nil
+		}
+		return string(b), nil
+	case 0x8:
+		b, err := p.bytes(off, info+1)
+		if err != nil {
+			return nil, err
+		}
+		return int64(readUint(b, len(b))), nil
+	case 0xa, 0xc:
+		n, off, err := p.count(info, off)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := p.refs(off, n)
+		if err != nil {
+			return nil, err
+		}
+		a := make([]any, 0, n)
+		for _, r := range refs {
+			v, err := p.object(r, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		return a, nil
+	case 0xd:
+		n, off, err := p.count(info, off)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := p.refs(off, 2*n)
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]any, n)
+		for i := uint64(0); i < n; i++ {
+			k, err := p.object(refs[i], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			v, err := p.object(refs[n+i], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(k)] = v
+		}
+		return m, nil
+	}
+
+	return nil, errPlist
+}
+
+// count returns the number of items of an object with the info low nibble of
+// its marker, followed by an integer object when it's 0xf, and the offset of
+// its content.
+func (p *plist) count(info, off uint64) (uint64, uint64, error) {
+	if info != 0xf {
+		return info, off, nil
+	}
+	b, err := p.bytes(off, 1)
+	if err != nil || b[0]>>4 != 0x1 || b[0]&0x0f > 3 {
+		return 0, 0, errPlist
+	}
+	n := uint64(1) << (b[0] & 0x0f)
+	b, err = p.bytes(off+1, n)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return readUint(b, len(b)), off + 1 + n, nil
+}
+
+// refs returns the n object references at off.
+func (p *plist) refs(off, n uint64) ([]uint64, error) {
+	if n > uint64(len(p.data)) {
+		return nil, errPlist
+	}
+	b, err := p.bytes(off, n*uint64(p.refSize))
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]uint64, n)
+	for i := range refs {
+		refs[i] = readUint(b[i*p.refSize:], p.refSize)
+	}
+
+	return refs, nil
+}
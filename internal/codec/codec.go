@@ -0,0 +1,173 @@
+// Package codec encrypts the Temporal payloads, e.g. the workflow inputs and
+// results, so the file names and metadata of restricted collections aren't
+// stored in clear text by the Temporal server.
+package codec
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	temporalapi_common "go.temporal.io/api/common/v1"
+	temporalsdk_client "go.temporal.io/sdk/client"
+	temporalsdk_converter "go.temporal.io/sdk/converter"
+	temporalsdk_temporal "go.temporal.io/sdk/temporal"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+)
+
+const (
+	// MetadataEncodingEncrypted is the encoding of the encrypted payloads.
+	MetadataEncodingEncrypted = "binary/encrypted"
+
+	// MetadataKeyID is the payload metadata key of the encryption key ID.
+	MetadataKeyID = "encryption-key-id"
+)
+
+// Key is an AES key identified by ID in the encrypted payloads.
+type Key struct {
+	ID  string
+	Key []byte
+}
+
+// AESCodec is a PayloadCodec encrypting the payloads with AES-GCM.
+//
+// The payloads are encrypted with the first key, and decrypted with the key
+// of their key ID, so the payloads encrypted with a previous key can still be
+// decrypted after a key rotation. The payloads that aren't encrypted are
+// decoded as is.
+type AESCodec struct {
+	keyID string
+	aeads map[string]cipher.AEAD
+}
+
+var _ temporalsdk_converter.PayloadCodec = (*AESCodec)(nil)
+
+// NewAESCodec returns an AESCodec using keys, at least one key is required.
+func NewAESCodec(keys []Key) (*AESCodec, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("codec: missing keys")
+	}
+
+	c := &AESCodec{keyID: keys[0].ID, aeads: make(map[string]cipher.AEAD, len(keys))}
+	for _, k := range keys {
+		if _, ok := c.aeads[k.ID]; ok {
+			return nil, fmt.Errorf("codec: duplicate key ID %q", k.ID)
+		}
+		block, err := aes.NewCipher(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("codec: key %q: %v", k.ID, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("codec: key %q: %v", k.ID, err)
+		}
+		c.aeads[k.ID] = aead
+	}
+
+	return c, nil
+}
+
+// New returns an AESCodec using the cfg keys, or nil if there are no keys.
+func New(cfg config.CodecConfig) (*AESCodec, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]Key, 0, len(cfg.Keys))
+	for _, k := range cfg.Keys {
+		b, err := base64.StdEncoding.DecodeString(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("codec: key %q: invalid base64 value", k.ID)
+		}
+		keys = append(keys, Key{ID: k.ID, Key: b})
+	}
+
+	return NewAESCodec(keys)
+}
+
+// Encode implements converter.PayloadCodec.Encode.
+func (c *AESCodec) Encode(payloads []*temporalapi_common.Payload) ([]*temporalapi_common.Payload, error) {
+	aead := c.aeads[c.keyID]
+	res := make([]*temporalapi_common.Payload, len(payloads))
+	for i, p := range payloads {
+		b, err := proto.Marshal(p)
+		if err != nil {
+			return nil, fmt.Errorf("codec: encode payload: %v", err)
+		}
+
+		nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(b)+aead.Overhead())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, fmt.Errorf("codec: encode payload: %v", err)
+		}
+
+		// The key ID is authenticated, so it can't be changed.
+		res[i] = &temporalapi_common.Payload{
+			Metadata: map[string][]byte{
+				temporalsdk_converter.MetadataEncoding: []byte(MetadataEncodingEncrypted),
+				MetadataKeyID:                          []byte(c.keyID),
+			},
+			Data: aead.Seal(nonce, nonce, b, []byte(c.keyID)),
+		}
+	}
+
+	return res, nil
+}
+
+// Decode implements converter.PayloadCodec.Decode.
+func (c *AESCodec) Decode(payloads []*temporalapi_common.Payload) ([]*temporalapi_common.Payload, error) {
+	res := make([]*temporalapi_common.Payload, len(payloads))
+	for i, p := range payloads {
+		if string(p.GetMetadata()[temporalsdk_converter.MetadataEncoding]) != MetadataEncodingEncrypted {
+			res[i] = p
+			continue
+		}
+
+		keyID := string(p.GetMetadata()[MetadataKeyID])
+		aead, ok := c.aeads[keyID]
+		if !ok {
+			return nil, fmt.Errorf("codec: decode payload: unknown key ID %q", keyID)
+		}
+
+		data := p.GetData()
+		if len(data) < aead.NonceSize() {
+			return nil, errors.New("codec: decode payload: invalid data")
+		}
+		b, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(keyID))
+		if err != nil {
+			return nil, fmt.Errorf("codec: decode payload: %v", err)
+		}
+
+		res[i] = &temporalapi_common.Payload{}
+		if err := proto.Unmarshal(b, res[i]); err != nil {
+			return nil, fmt.Errorf("codec: decode payload: %v", err)
+		}
+	}
+
+	return res, nil
+}
+
+// ClientOptions returns opts with the data and failure converters encrypting
+// the payloads with c, including the error messages and stack traces. opts is
+// returned unchanged if c is nil.
+func ClientOptions(opts temporalsdk_client.Options, c *AESCodec) temporalsdk_client.Options {
+	if c == nil {
+		return opts
+	}
+
+	dc := temporalsdk_converter.NewCodecDataConverter(temporalsdk_converter.GetDefaultDataConverter(), c)
+	opts.DataConverter = dc
+	opts.FailureConverter = temporalsdk_temporal.NewDefaultFailureConverter(
+		temporalsdk_temporal.DefaultFailureConverterOptions{
+			DataConverter:          dc,
+			EncodeCommonAttributes: true,
+		},
+	)
+
+	return opts
+}
@@ -0,0 +1,148 @@
+package codec_test
+
+import (
+	"bytes"
+	"testing"
+
+	temporalapi_common "go.temporal.io/api/common/v1"
+	temporalsdk_client "go.temporal.io/sdk/client"
+	temporalsdk_converter "go.temporal.io/sdk/converter"
+	"gotest.tools/v3/assert"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/codec"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+)
+
+var (
+	key1 = codec.Key{ID: "k1", Key: []byte("0123456789abcdef0123456789abcdef")}
+	key2 = codec.Key{ID: "k2", Key: []byte("fedcba9876543210fedcba9876543210")}
+)
+
+func payload(t *testing.T, v any) *temporalapi_common.Payload {
+	t.Helper()
+
+	p, err := temporalsdk_converter.GetDefaultDataConverter().ToPayload(v)
+	assert.NilError(t, err)
+
+	return p
+}
+
+func TestAESCodec(t *testing.T) {
+	t.Parallel()
+
+	c, err := codec.NewAESCodec([]codec.Key{key1})
+	assert.NilError(t, err)
+
+	p := payload(t, "restricted/collection/file.tif")
+	encoded, err := c.Encode([]*temporalapi_common.Payload{p})
+	assert.NilError(t, err)
+	assert.Equal(t, len(encoded), 1)
+	assert.Equal(t, string(encoded[0].Metadata["encoding"]), codec.MetadataEncodingEncrypted)
+	assert.Equal(t, string(encoded[0].Metadata[codec.MetadataKeyID]), "k1")
+	assert.Assert(t, !bytes.Contains(encoded[0].Data, []byte("file.tif")))
+
+	decoded, err := c.Decode(encoded)
+	assert.NilError(t, err)
+	assert.Equal(t, decoded[0].String(), p.String())
+
+	// The payloads that aren't encrypted are decoded as is.
+	decoded, err = c.Decode([]*temporalapi_common.Payload{p})
+	assert.NilError(t, err)
+	assert.Equal(t, decoded[0], p)
+}
+
+func TestAESCodecKeyRotation(t *testing.T) {
+	t.Parallel()
+
+	old, err := codec.NewAESCodec([]codec.Key{key1})
+	assert.NilError(t, err)
+	encoded, err := old.Encode([]*temporalapi_common.Payload{payload(t, "old")})
+	assert.NilError(t, err)
+
+	c, err := codec.NewAESCodec([]codec.Key{key2, key1})
+	assert.NilError(t, err)
+
+	// Payloads encrypted with the previous key are still decoded.
+	decoded, err := c.Decode(encoded)
+	assert.NilError(t, err)
+	assert.Equal(t, decoded[0].String(), payload(t, "old").String())
+
+	// New payloads are encrypted with the first key.
+	encoded, err = c.Encode([]*temporalapi_common.Payload{payload(t, "new")})
+	assert.NilError(t, err)
+	assert.Equal(t, string(encoded[0].Metadata[codec.MetadataKeyID]), "k2")
+
+	// The previous codec doesn't know the new key.
+	_, err = old.Decode(encoded)
+	assert.Error(t, err, `codec: decode payload: unknown key ID "k2"`)
+}
+
+func TestAESCodecAuthenticatesKeyID(t *testing.T) {
+	t.Parallel()
+
+	c, err := codec.NewAESCodec([]codec.Key{key1, {ID: "k1-copy", Key: key1.Key}})
+	assert.NilError(t, err)
+
+	encoded, err := c.Encode([]*temporalapi_common.Payload{payload(t, "value")})
+	assert.NilError(t, err)
+
+	encoded[0].Metadata[codec.MetadataKeyID] = []byte("k1-copy")
+	_, err = c.Decode(encoded)
+	assert.Error(t, err, "codec: decode payload: cipher: message authentication failed")
+}
+
+func TestNewAESCodec(t *testing.T) {
+	t.Parallel()
+
+	_, err := codec.NewAESCodec(nil)
+	assert.Error(t, err, "codec: missing keys")
+
+	_, err = codec.NewAESCodec([]codec.Key{key1, key1})
+	assert.Error(t, err, `codec: duplicate key ID "k1"`)
+
+	_, err = codec.NewAESCodec([]codec.Key{{ID: "short", Key: []byte("short")}})
+	assert.Error(t, err, `codec: key "short": crypto/aes: invalid key size 5`)
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	c, err := codec.New(config.CodecConfig{})
+	assert.NilError(t, err)
+	assert.Assert(t, c == nil)
+
+	c, err = codec.New(config.CodecConfig{
+		Keys: []config.CodecKey{{ID: "k1", Key: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}},
+	})
+	assert.NilError(t, err)
+
+	// The base64 decoded key decrypts the payloads encrypted with key1.
+	encoded, err := c.Encode([]*temporalapi_common.Payload{payload(t, "value")})
+	assert.NilError(t, err)
+	k1, err := codec.NewAESCodec([]codec.Key{key1})
+	assert.NilError(t, err)
+	_, err = k1.Decode(encoded)
+	assert.NilError(t, err)
+}
+
+func TestClientOptions(t *testing.T) {
+	t.Parallel()
+
+	opts := codec.ClientOptions(temporalsdk_client.Options{HostPort: "host:port"}, nil)
+	assert.Assert(t, opts.DataConverter == nil)
+	assert.Assert(t, opts.FailureConverter == nil)
+
+	c, err := codec.NewAESCodec([]codec.Key{key1})
+	assert.NilError(t, err)
+	opts = codec.ClientOptions(temporalsdk_client.Options{HostPort: "host:port"}, c)
+	assert.Equal(t, opts.HostPort, "host:port")
+	assert.Assert(t, opts.FailureConverter != nil)
+
+	p, err := opts.DataConverter.ToPayload("value")
+	assert.NilError(t, err)
+	assert.Equal(t, string(p.Metadata[codec.MetadataKeyID]), "k1")
+
+	var v string
+	assert.NilError(t, opts.DataConverter.FromPayload(p, &v))
+	assert.Equal(t, v, "value")
+}
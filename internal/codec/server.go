@@ -0,0 +1,67 @@
+package codec
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"slices"
+	"strings"
+
+	temporalsdk_converter "go.temporal.io/sdk/converter"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+)
+
+// maxRequestSize limits the size of the codec server request bodies.
+const maxRequestSize = 16 << 20
+
+// Handler returns the codec server HTTP handler, encoding and decoding the
+// payloads of the POST /encode and /decode requests with c, e.g. for the
+// Temporal UI and the Temporal CLI --codec-endpoint flag.
+//
+// The requests must be authorized by one of the cfg tokens, and the cfg
+// origins are allowed to call the handler from a browser.
+func Handler(c temporalsdk_converter.PayloadCodec, cfg config.CodecServerConfig) http.Handler {
+	h := temporalsdk_converter.NewPayloadCodecHTTPHandler(c)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" && slices.Contains(cfg.Origins, origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-Namespace")
+			w.Header().Add("Vary", "Origin")
+		}
+
+		// Browsers don't send the Authorization header in preflight requests.
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if !authorized(r, cfg.Tokens) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// authorized reports whether the r bearer token is one of tokens.
+func authorized(r *http.Request, tokens []string) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+
+	var match bool
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			match = true
+		}
+	}
+
+	return match
+}
@@ -0,0 +1,94 @@
+package codec_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	temporalapi_common "go.temporal.io/api/common/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"gotest.tools/v3/assert"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/codec"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+)
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	c, err := codec.NewAESCodec([]codec.Key{key1})
+	assert.NilError(t, err)
+	encoded, err := c.Encode([]*temporalapi_common.Payload{payload(t, "file.tif")})
+	assert.NilError(t, err)
+	body, err := protojson.Marshal(&temporalapi_common.Payloads{Payloads: encoded})
+	assert.NilError(t, err)
+
+	h := codec.Handler(c, config.CodecServerConfig{
+		Origins: []string{"https://temporal.example.org"},
+		Tokens:  []string{"token"},
+	})
+
+	type test struct {
+		name       string
+		method     string
+		headers    map[string]string
+		wantStatus int
+		wantBody   string
+		wantOrigin string
+	}
+	for _, tc := range []test{
+		{
+			name:       "Decodes the payloads of authorized requests",
+			method:     http.MethodPost,
+			headers:    map[string]string{"Authorization": "Bearer token"},
+			wantStatus: http.StatusOK,
+			wantBody:   `"ImZpbGUudGlmIg=="`,
+		},
+		{
+			name:       "Rejects requests without a token",
+			method:     http.MethodPost,
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   "Unauthorized",
+		},
+		{
+			name:       "Rejects requests with an unknown token",
+			method:     http.MethodPost,
+			headers:    map[string]string{"Authorization": "Bearer other"},
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   "Unauthorized",
+		},
+		{
+			name:   "Answers the preflight requests of the allowed origins",
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://temporal.example.org",
+				"Access-Control-Request-Headers": "authorization,content-type,x-namespace",
+			},
+			wantStatus: http.StatusOK,
+			wantOrigin: "https://temporal.example.org",
+		},
+		{
+			name:       "Doesn't set the CORS headers for other origins",
+			method:     http.MethodPost,
+			headers:    map[string]string{"Origin": "https://example.org", "Authorization": "Bearer token"},
+			wantStatus: http.StatusOK,
+			wantBody:   `"ImZpbGUudGlmIg=="`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(tc.method, "/decode", strings.NewReader(string(body)))
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, rec.Code, tc.wantStatus)
+			assert.Assert(t, strings.Contains(rec.Body.String(), tc.wantBody), rec.Body.String())
+			assert.Equal(t, rec.Header().Get("Access-Control-Allow-Origin"), tc.wantOrigin)
+		})
+	}
+}
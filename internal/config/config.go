@@ -1,12 +1,21 @@
 package config
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"math"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/report"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/validation"
 )
 
 type ConfigurationValidator interface {
@@ -30,8 +39,79 @@ type Configuration struct {
 	// Enduro and preservation processing.
 	SharedPath string
 
-	Temporal Temporal
-	Worker   WorkerConfig
+	// ResultsPath is the directory of the large activity results, e.g. the
+	// lists of removed files, referenced by the workflow results (default:
+	// "<SharedPath>/.results"). Enduro must be able to access it.
+	ResultsPath string
+
+	// StagingPath is the directory of the staged SIP copies processed when
+	// Staging is enabled (default: "<SharedPath>/.staging"). It must be in the
+	// same filesystem as SharedPath.
+	StagingPath string
+
+	// JournalPath is the directory of the journals of the SIP mutations of the
+	// workflow runs, with the trash of the removed files (default:
+	// "<SharedPath>/.journal"). It must be in the same filesystem as
+	// SharedPath.
+	JournalPath string
+
+	Temporal       Temporal
+	Worker         WorkerConfig
+	Activities     ActivitiesConfig
+	UnwantedFiles  UnwantedFilesConfig
+	Review         ReviewConfig
+	Validation     ValidationConfig
+	Report         ReportConfig
+	Lock           LockConfig
+	MacMetadata    MacMetadataConfig
+	Timestamps     TimestampsConfig
+	TimestampCheck TimestampCheckConfig
+	Staging        StagingConfig
+	Journal        JournalConfig
+	Trash          TrashConfig
+	Codec          CodecConfig
+}
+
+// Provider provides the configuration to the components that can apply
+// configuration changes without restarting the worker.
+type Provider interface {
+	Config() Configuration
+}
+
+// Config returns c, so a Configuration is a Provider of a static
+// configuration.
+func (c Configuration) Config() Configuration {
+	return c
+}
+
+// ResultsDir returns the ResultsPath or, if it's empty, the default results
+// directory in the SharedPath.
+func (c Configuration) ResultsDir() string {
+	if c.ResultsPath != "" {
+		return c.ResultsPath
+	}
+
+	return filepath.Join(c.SharedPath, ".results")
+}
+
+// StagingDir returns the StagingPath or, if it's empty, the default staging
+// directory in the SharedPath.
+func (c Configuration) StagingDir() string {
+	if c.StagingPath != "" {
+		return c.StagingPath
+	}
+
+	return filepath.Join(c.SharedPath, ".staging")
+}
+
+// JournalDir returns the JournalPath or, if it's empty, the default journal
+// directory in the SharedPath.
+func (c Configuration) JournalDir() string {
+	if c.JournalPath != "" {
+		return c.JournalPath
+	}
+
+	return filepath.Join(c.SharedPath, ".journal")
 }
 
 type Temporal struct {
@@ -55,6 +135,325 @@ type WorkerConfig struct {
 	// MaxConcurrentSessions limits the number of workflow sessions the
 	// preprocessing worker can handle simultaneously (default: 1).
 	MaxConcurrentSessions int
+
+	// MaxConcurrentActivityExecutionSize limits the number of activities the
+	// worker can execute simultaneously (default: Temporal SDK default).
+	MaxConcurrentActivityExecutionSize int
+
+	// MaxConcurrentWorkflowTaskExecutionSize limits the number of workflow
+	// tasks the worker can execute simultaneously. It can't be 1 (default:
+	// Temporal SDK default).
+	MaxConcurrentWorkflowTaskExecutionSize int
+
+	// MaxConcurrentActivityTaskPollers sets the number of goroutines polling
+	// the Temporal server for activity tasks (default: Temporal SDK default).
+	MaxConcurrentActivityTaskPollers int
+
+	// MaxConcurrentWorkflowTaskPollers sets the number of goroutines polling
+	// the Temporal server for workflow tasks. It can't be 1 (default: Temporal
+	// SDK default).
+	MaxConcurrentWorkflowTaskPollers int
+
+	// WorkerActivitiesPerSecond limits the number of activities per second
+	// this worker can start, values lower than 1 are allowed (default: Temporal
+	// SDK default).
+	WorkerActivitiesPerSecond float64
+
+	// TaskQueueActivitiesPerSecond limits the number of activities per second
+	// started across all the workers polling the activity task queue, it's
+	// enforced by the Temporal server (default: Temporal SDK default).
+	TaskQueueActivitiesPerSecond float64
+
+	// StickyWorkflowCacheSize sets the number of workflow executions cached by
+	// the worker process (default: Temporal SDK default).
+	StickyWorkflowCacheSize int
+
+	// ActivityTaskQueue is a dedicated Temporal task queue for the
+	// preprocessing activities. When set, a separate worker polls this queue
+	// for activity tasks and Temporal.TaskQueue is only used for workflow
+	// tasks (optional).
+	ActivityTaskQueue string
+
+	// SessionCreationTimeout is how long a workflow waits for a worker to
+	// accept its session, e.g. when all the workers run MaxConcurrentSessions
+	// sessions. The workflow fails when it expires (default: "10m").
+	SessionCreationTimeout time.Duration
+
+	// SessionExecutionTimeout is the maximum duration of the session
+	// processing a SIP, the activities still running when it expires are
+	// canceled (default: "24h").
+	SessionExecutionTimeout time.Duration
+}
+
+type UnwantedFilesConfig struct {
+	// Names lists the names of the files and directories removed from the
+	// SIPs (default: [".DS_Store"]).
+	Names []string
+
+	// Patterns lists regular expressions matching the names of the files and
+	// directories removed from the SIPs (optional).
+	Patterns []string
+}
+
+type ReviewConfig struct {
+	// Timeout is the maximum time a workflow waits for the review decision of
+	// a SIP, when a review is requested. The SIP is rejected when it expires,
+	// zero waits indefinitely (default: "72h").
+	Timeout time.Duration
+}
+
+// Lock modes.
+const (
+	// LockModeWait waits until the relative path is released.
+	LockModeWait = "wait"
+
+	// LockModeFail fails the workflow immediately.
+	LockModeFail = "fail"
+)
+
+// LockModes lists the valid LockConfig.Mode values.
+var LockModes = []string{LockModeWait, LockModeFail}
+
+type LockConfig struct {
+	// Mode sets what a workflow does when the relative path is being
+	// processed by another workflow execution, "wait" or "fail" (default:
+	// "wait").
+	Mode string
+
+	// Timeout is the maximum time a workflow waits for the relative path to
+	// be released in "wait" mode, zero waits indefinitely (default: "0s").
+	Timeout time.Duration
+
+	// PollInterval is the time between the attempts to lock the relative path
+	// in "wait" mode (default: "1m").
+	PollInterval time.Duration
+}
+
+type MacMetadataConfig struct {
+	// Enabled preserves the metadata of the AppleDouble files ("._<name>")
+	// and the extended attributes of the SIP files in JSON sidecars in the SIP
+	// metadata/macos directory, before the unwanted files are removed
+	// (default: false).
+	Enabled bool
+}
+
+type TimestampsConfig struct {
+	// Enabled captures the timestamps of the SIP files at intake in the SIP
+	// metadata/preprocessing-timestamps.json file, and restores their
+	// modification times once the SIP is processed (default: false).
+	Enabled bool
+}
+
+type TimestampCheckConfig struct {
+	// Enabled reports the SIP files modified before MinDate or later than
+	// MaxFuture after the workflow start as warning findings, e.g. the files
+	// dated by a camera with a reset or wrong clock (default: false).
+	Enabled bool
+
+	// MinDate is the earliest valid modification date, "YYYY-MM-DD" in UTC.
+	// The default flags the Unix (1970-01-01) and FAT (1980-01-01) epochs, no
+	// lower bound is checked if empty (default: "1980-01-02").
+	MinDate string
+
+	// MaxFuture is how far after the workflow start a modification time is
+	// valid, allowing for the clock differences between the systems
+	// (default: "24h").
+	MaxFuture time.Duration
+}
+
+type StagingConfig struct {
+	// Enabled processes a copy of the SIP in the StagingPath, which replaces
+	// the SIP only when the workflow succeeds. The SIP is left untouched when
+	// the workflow fails (default: false).
+	Enabled bool
+
+	// Hardlinks links the SIP files into the staged copy when the filesystem
+	// can't reflink them, instead of copying them. It's faster and it doesn't
+	// use more space, but the staged files share their content and metadata
+	// with the SIP files (default: false).
+	Hardlinks bool
+}
+
+type JournalConfig struct {
+	// Enabled journals the mutations of the SIP, moving the removed files to
+	// the trash instead of deleting them, and rolls them back when the
+	// workflow fails or is canceled. It can't be enabled with Staging
+	// (default: false).
+	Enabled bool
+}
+
+type TrashConfig struct {
+	// Retention is how long the journals, with the trash of the removed files
+	// when Journal is enabled, are kept after their last mutation. Zero keeps
+	// them indefinitely (default: "720h").
+	Retention time.Duration
+
+	// Schedule is the cron expression, in UTC, of the housekeeping workflow
+	// purging the journals older than Retention, it's applied by the bootstrap
+	// command (default: "0 3 * * *").
+	Schedule string
+}
+
+type ValidationConfig struct {
+	// BlockingSeverity is the minimum severity ("info", "warning" or "error")
+	// of the findings failing the preprocessing, so the SIP isn't ingested
+	// (default: "error").
+	BlockingSeverity string
+}
+
+type ReportConfig struct {
+	// Format is the format of the preprocessing report written in the SIP
+	// metadata/submissionDocumentation directory, "html" or "markdown"
+	// (default: "html").
+	Format string
+}
+
+type CodecConfig struct {
+	// Keys lists the AES keys encrypting the Temporal payloads, e.g. the
+	// workflow inputs and results. The payloads are encrypted with the first
+	// key and decrypted with the key of their key ID, add a new key first to
+	// rotate the keys. The payloads aren't encrypted if there are no keys.
+	Keys []CodecKey `secret:"true"`
+
+	// Server is the codec server used by the Temporal UI and CLI to decode the
+	// encrypted payloads.
+	Server CodecServerConfig
+}
+
+type CodecKey struct {
+	// ID identifies the key in the encrypted payloads (required).
+	ID string
+
+	// Key is the base64 encoded AES key, of 16, 24 or 32 bytes (required).
+	Key string
+}
+
+type CodecServerConfig struct {
+	// Address is the host and port the codec server listens on, e.g.
+	// "localhost:8089". The codec server is disabled if empty.
+	Address string
+
+	// Origins lists the origins allowed to call the codec server from a
+	// browser, e.g. the Temporal UI "https://temporal.example.org".
+	Origins []string
+
+	// Tokens lists the tokens authorizing the codec server requests, sent in an
+	// "Authorization: Bearer <token>" header (required with an Address).
+	Tokens []string `secret:"true"`
+}
+
+type ActivitiesConfig struct {
+	// Default sets the options for activities without their own
+	// configuration, and the options left empty in an activity configuration.
+	Default ActivityConfig
+
+	// RemoveFiles sets the options for the activity removing unwanted files.
+	RemoveFiles ActivityConfig
+
+	// MacMetadata sets the options for the activity preserving the macOS
+	// metadata.
+	MacMetadata ActivityConfig
+
+	// Timestamps sets the options for the activities capturing, restoring and
+	// checking the file timestamps.
+	Timestamps ActivityConfig
+
+	// WriteReport sets the options for the activity writing the preprocessing
+	// report.
+	WriteReport ActivityConfig
+
+	// Stage sets the options for the activities staging a copy of the SIP,
+	// publishing it and discarding it.
+	Stage ActivityConfig
+}
+
+// Defaults of the activity options, they are also used by the workflow for the
+// options left empty. The executions started before the options were
+// configurable keep attempting their activities once.
+const (
+	DefaultStartToCloseTimeout = 5 * time.Minute
+	DefaultMaximumAttempts     = 3
+)
+
+// Defaults of the session timeouts, they are also used by the workflow when
+// the timeouts are zero.
+const (
+	DefaultSessionCreationTimeout  = 10 * time.Minute
+	DefaultSessionExecutionTimeout = 24 * time.Hour
+)
+
+type ActivityConfig struct {
+	// StartToCloseTimeout is the maximum time of a single activity attempt
+	// (default: "5m", "24h" for RemoveFiles, MacMetadata, Timestamps,
+	// WriteReport and Stage).
+	StartToCloseTimeout time.Duration
+
+	// HeartbeatTimeout is the maximum time between activity heartbeats, long
+	// running activities fail when they don't report progress within this time
+	// (default: no heartbeat timeout, "1m" for RemoveFiles, MacMetadata,
+	// Timestamps, WriteReport and Stage).
+	HeartbeatTimeout time.Duration
+
+	// RetryPolicy sets how failed activity attempts are retried.
+	RetryPolicy RetryPolicyConfig
+}
+
+type RetryPolicyConfig struct {
+	// InitialInterval is the time to wait before the first retry (default:
+	// "1s").
+	InitialInterval time.Duration
+
+	// BackoffCoefficient multiplies the interval between retries after each
+	// attempt, it must be 1 or larger (default: 2).
+	BackoffCoefficient float64
+
+	// MaximumInterval caps the interval between retries (default: 100x
+	// InitialInterval).
+	MaximumInterval time.Duration
+
+	// MaximumAttempts is the maximum number of attempts, including the first
+	// one (default: 3).
+	MaximumAttempts int
+
+	// NonRetryableErrorTypes lists the error types that are never retried.
+	// Activity errors caused by invalid content are never retried, this list
+	// can be used to also stop retrying transient errors, e.g.
+	// ["TransientIOError"] (optional).
+	NonRetryableErrorTypes []string
+}
+
+// Merge returns a copy of c with its zero values replaced by the values in d.
+func (c ActivityConfig) Merge(d ActivityConfig) ActivityConfig {
+	if c.StartToCloseTimeout == 0 {
+		c.StartToCloseTimeout = d.StartToCloseTimeout
+	}
+	if c.HeartbeatTimeout == 0 {
+		c.HeartbeatTimeout = d.HeartbeatTimeout
+	}
+	c.RetryPolicy = c.RetryPolicy.Merge(d.RetryPolicy)
+
+	return c
+}
+
+// Merge returns a copy of c with its zero values replaced by the values in d.
+func (c RetryPolicyConfig) Merge(d RetryPolicyConfig) RetryPolicyConfig {
+	if c.InitialInterval == 0 {
+		c.InitialInterval = d.InitialInterval
+	}
+	if c.BackoffCoefficient == 0 {
+		c.BackoffCoefficient = d.BackoffCoefficient
+	}
+	if c.MaximumInterval == 0 {
+		c.MaximumInterval = d.MaximumInterval
+	}
+	if c.MaximumAttempts == 0 {
+		c.MaximumAttempts = d.MaximumAttempts
+	}
+	if c.NonRetryableErrorTypes == nil {
+		c.NonRetryableErrorTypes = d.NonRetryableErrorTypes
+	}
+
+	return c
 }
 
 func (c Configuration) Validate() error {
@@ -79,29 +478,270 @@ func (c Configuration) Validate() error {
 		))
 	}
 
+	errs = errors.Join(errs, c.Worker.Validate(c.Temporal.TaskQueue))
+	for _, p := range c.UnwantedFiles.Patterns {
+		if _, err := regexp.Compile(p); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("UnwantedFiles.Patterns: %v", err))
+		}
+	}
+	if c.Review.Timeout < 0 {
+		errs = errors.Join(errs, fmt.Errorf(
+			"Review.Timeout: %s is less than the minimum value (0s)",
+			c.Review.Timeout,
+		))
+	}
+	if m := c.Lock.Mode; m != "" && !slices.Contains(LockModes, m) {
+		errs = errors.Join(errs, fmt.Errorf(
+			"Lock.Mode: %q is not one of: %s", m, strings.Join(LockModes, ", "),
+		))
+	}
+	if c.Lock.Timeout < 0 {
+		errs = errors.Join(errs, fmt.Errorf(
+			"Lock.Timeout: %s is less than the minimum value (0s)",
+			c.Lock.Timeout,
+		))
+	}
+	if c.Lock.PollInterval < 0 {
+		errs = errors.Join(errs, fmt.Errorf(
+			"Lock.PollInterval: %s is less than the minimum value (0s)",
+			c.Lock.PollInterval,
+		))
+	}
+	if c.Trash.Retention < 0 {
+		errs = errors.Join(errs, fmt.Errorf(
+			"Trash.Retention: %s is less than the minimum value (0s)",
+			c.Trash.Retention,
+		))
+	}
+	if c.Trash.Retention > 0 && c.Trash.Schedule == "" {
+		errs = errors.Join(errs, errors.New("Trash.Schedule: missing required value"))
+	}
+	if d := c.TimestampCheck.MinDate; d != "" {
+		if _, err := time.Parse(time.DateOnly, d); err != nil {
+			errs = errors.Join(errs, fmt.Errorf(
+				"TimestampCheck.MinDate: %q is not a valid date (YYYY-MM-DD)", d,
+			))
+		}
+	}
+	if c.TimestampCheck.MaxFuture < 0 {
+		errs = errors.Join(errs, fmt.Errorf(
+			"TimestampCheck.MaxFuture: %s is less than the minimum value (0s)",
+			c.TimestampCheck.MaxFuture,
+		))
+	}
+	if c.Staging.Enabled && c.Journal.Enabled {
+		errs = errors.Join(errs, errors.New("Journal.Enabled: can't be enabled with Staging.Enabled"))
+	}
+	if s := c.Validation.BlockingSeverity; s != "" {
+		if _, err := validation.ParseSeverity(s); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("Validation.BlockingSeverity: %v", err))
+		}
+	}
+	errs = errors.Join(errs, c.Activities.Default.Validate("Activities.Default", ActivityConfig{}))
+	errs = errors.Join(errs, c.Activities.RemoveFiles.Validate("Activities.RemoveFiles", c.Activities.Default))
+	errs = errors.Join(errs, c.Activities.MacMetadata.Validate("Activities.MacMetadata", c.Activities.Default))
+	errs = errors.Join(errs, c.Activities.Timestamps.Validate("Activities.Timestamps", c.Activities.Default))
+	errs = errors.Join(errs, c.Activities.WriteReport.Validate("Activities.WriteReport", c.Activities.Default))
+	errs = errors.Join(errs, c.Activities.Stage.Validate("Activities.Stage", c.Activities.Default))
+	if f := c.Report.Format; f != "" && !slices.Contains(report.Formats, f) {
+		errs = errors.Join(errs, fmt.Errorf(
+			"Report.Format: %q is not one of: %s", f, strings.Join(report.Formats, ", "),
+		))
+	}
+	errs = errors.Join(errs, c.Codec.Validate())
+
 	return errs
 }
 
-func Read(config *Configuration, configFile string) (found bool, configFileUsed string, err error) {
-	v := viper.New()
+// Validate checks the codec keys and server. The key values are never
+// included in the errors.
+func (c CodecConfig) Validate() error {
+	var errs error
+
+	ids := map[string]bool{}
+	for i, k := range c.Keys {
+		if k.ID == "" {
+			errs = errors.Join(errs, fmt.Errorf("Codec.Keys[%d].ID: missing required value", i))
+		} else if ids[k.ID] {
+			errs = errors.Join(errs, fmt.Errorf("Codec.Keys[%d].ID: duplicate key ID %q", i, k.ID))
+		}
+		ids[k.ID] = true
+
+		b, err := base64.StdEncoding.DecodeString(k.Key)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("Codec.Keys[%d].Key: invalid base64 value", i))
+		} else if n := len(b); n != 16 && n != 24 && n != 32 {
+			errs = errors.Join(errs, fmt.Errorf(
+				"Codec.Keys[%d].Key: %d bytes key, must be 16, 24 or 32 bytes", i, n,
+			))
+		}
+	}
+
+	if c.Server.Address != "" {
+		if len(c.Keys) == 0 {
+			errs = errors.Join(errs, errors.New("Codec.Server.Address: requires Codec.Keys"))
+		}
+		if len(c.Server.Tokens) == 0 {
+			errs = errors.Join(errs, errors.New("Codec.Server.Tokens: required with Codec.Server.Address"))
+		}
+	}
+
+	return errs
+}
+
+// Validate checks the worker tuning values. A zero value means the Temporal
+// SDK default is used, so only negative values (and the values rejected by the
+// SDK) are considered invalid.
+func (c WorkerConfig) Validate(taskQueue string) error {
+	var errs error
+
+	for _, f := range []struct {
+		name  string
+		value float64
+	}{
+		{"MaxConcurrentActivityExecutionSize", float64(c.MaxConcurrentActivityExecutionSize)},
+		{"MaxConcurrentWorkflowTaskExecutionSize", float64(c.MaxConcurrentWorkflowTaskExecutionSize)},
+		{"MaxConcurrentActivityTaskPollers", float64(c.MaxConcurrentActivityTaskPollers)},
+		{"MaxConcurrentWorkflowTaskPollers", float64(c.MaxConcurrentWorkflowTaskPollers)},
+		{"WorkerActivitiesPerSecond", c.WorkerActivitiesPerSecond},
+		{"TaskQueueActivitiesPerSecond", c.TaskQueueActivitiesPerSecond},
+		{"StickyWorkflowCacheSize", float64(c.StickyWorkflowCacheSize)},
+	} {
+		if f.value < 0 {
+			errs = errors.Join(errs, fmt.Errorf(
+				"Worker.%s: %v is less than the minimum value (0)", f.name, f.value,
+			))
+		}
+	}
+
+	// The Temporal SDK panics when these values are set to 1, as pollers
+	// alternate between the sticky and non-sticky queues.
+	if c.MaxConcurrentWorkflowTaskExecutionSize == 1 {
+		errs = errors.Join(errs, errors.New(
+			"Worker.MaxConcurrentWorkflowTaskExecutionSize: 1 is not a valid value",
+		))
+	}
+	if c.MaxConcurrentWorkflowTaskPollers == 1 {
+		errs = errors.Join(errs, errors.New(
+			"Worker.MaxConcurrentWorkflowTaskPollers: 1 is not a valid value",
+		))
+	}
+
+	for _, f := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"SessionCreationTimeout", c.SessionCreationTimeout},
+		{"SessionExecutionTimeout", c.SessionExecutionTimeout},
+	} {
+		if f.value < 0 {
+			errs = errors.Join(errs, fmt.Errorf(
+				"Worker.%s: %s is less than the minimum value (0s)", f.name, f.value,
+			))
+		}
+	}
+
+	if c.ActivityTaskQueue != "" && c.ActivityTaskQueue == taskQueue {
+		errs = errors.Join(errs, fmt.Errorf(
+			"Worker.ActivityTaskQueue: %q must be different from Temporal.TaskQueue",
+			c.ActivityTaskQueue,
+		))
+	}
+
+	return errs
+}
+
+// ReadOption configures Read.
+type ReadOption func(*readOptions)
+
+type readOptions struct {
+	unknownKeys *[]string
+}
+
+// WarnUnknownKeys makes Read store the unknown configuration keys found in the
+// configuration file in keys, instead of returning an error.
+func WarnUnknownKeys(keys *[]string) ReadOption {
+	return func(o *readOptions) {
+		o.unknownKeys = keys
+	}
+}
+
+func Read(config *Configuration, configFile string, opts ...ReadOption) (found bool, configFileUsed string, err error) {
+	var o readOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	v, found, err := load(config, configFile)
+	if err != nil {
+		return found, "", err
+	}
+
+	if keys := unknownKeys(v); len(keys) > 0 {
+		if o.unknownKeys == nil {
+			return true, "", fmt.Errorf("unknown configuration keys: %s", strings.Join(keys, ", "))
+		}
+		*o.unknownKeys = keys
+	}
+
+	if err := config.Validate(); err != nil {
+		return true, "", errors.Join(
+			fmt.Errorf("invalid configuration:"),
+			err,
+		)
+	}
+
+	return true, v.ConfigFileUsed(), nil
+}
+
+// load reads the configuration file, environment variables and defaults into
+// config without validating it.
+func load(config *Configuration, configFile string) (v *viper.Viper, found bool, err error) {
+	v = viper.New()
 
 	v.AddConfigPath(".")
 	v.AddConfigPath("$HOME/.config/")
 	v.AddConfigPath("/etc")
 	v.SetConfigName("preprocessing")
-	v.SetEnvPrefix("ENDURO_PREPROCESSING")
+	v.SetEnvPrefix(envPrefix)
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	v.AutomaticEnv()
 
 	// Defaults.
 	v.SetDefault("Worker.MaxConcurrentSessions", 1)
+	v.SetDefault("Worker.SessionCreationTimeout", DefaultSessionCreationTimeout)
+	v.SetDefault("Worker.SessionExecutionTimeout", DefaultSessionExecutionTimeout)
+	v.SetDefault("Activities.Default.StartToCloseTimeout", DefaultStartToCloseTimeout)
+	v.SetDefault("Activities.Default.RetryPolicy.InitialInterval", "1s")
+	v.SetDefault("Activities.Default.RetryPolicy.BackoffCoefficient", 2)
+	v.SetDefault("Activities.Default.RetryPolicy.MaximumAttempts", DefaultMaximumAttempts)
+	v.SetDefault("Activities.RemoveFiles.StartToCloseTimeout", "24h")
+	v.SetDefault("Activities.RemoveFiles.HeartbeatTimeout", "1m")
+	v.SetDefault("Activities.MacMetadata.StartToCloseTimeout", "24h")
+	v.SetDefault("Activities.MacMetadata.HeartbeatTimeout", "1m")
+	v.SetDefault("Activities.Timestamps.StartToCloseTimeout", "24h")
+	v.SetDefault("Activities.Timestamps.HeartbeatTimeout", "1m")
+	v.SetDefault("Activities.WriteReport.StartToCloseTimeout", "24h")
+	v.SetDefault("Activities.WriteReport.HeartbeatTimeout", "1m")
+	v.SetDefault("Activities.Stage.StartToCloseTimeout", "24h")
+	v.SetDefault("Activities.Stage.HeartbeatTimeout", "1m")
+	v.SetDefault("Report.Format", report.HTML)
+	v.SetDefault("UnwantedFiles.Names", []string{".DS_Store"})
+	v.SetDefault("TimestampCheck.MinDate", "1980-01-02")
+	v.SetDefault("TimestampCheck.MaxFuture", "24h")
+	v.SetDefault("Review.Timeout", "72h")
+	v.SetDefault("Validation.BlockingSeverity", "error")
+	v.SetDefault("Lock.Mode", LockModeWait)
+	v.SetDefault("Lock.PollInterval", "1m")
+	v.SetDefault("Trash.Retention", "720h")
+	v.SetDefault("Trash.Schedule", "0 3 * * *")
 
 	if configFile != "" {
 		// Viper will not return a viper.ConfigFileNotFoundError error when
 		// SetConfigFile() is passed a path to a file that doesn't exist, so we
 		// need to check ourselves.
 		if _, err := os.Stat(configFile); errors.Is(err, os.ErrNotExist) {
-			return false, "", fmt.Errorf("configuration file not found: %s", configFile)
+			return v, false, fmt.Errorf("configuration file not found: %s", configFile)
 		}
 
 		v.SetConfigFile(configFile)
@@ -110,25 +750,89 @@ func Read(config *Configuration, configFile string) (found bool, configFileUsed
 	if err = v.ReadInConfig(); err != nil {
 		switch err.(type) {
 		case viper.ConfigFileNotFoundError:
-			return false, "", err
+			return v, false, err
 		default:
-			return true, "", fmt.Errorf("failed to read configuration file: %w", err)
+			return v, true, fmt.Errorf("failed to read configuration file: %w", err)
 		}
 	}
 
 	err = v.Unmarshal(config)
 	if err != nil {
-		return true, "", fmt.Errorf("failed to unmarshal configuration: %w", err)
+		return v, true, fmt.Errorf("failed to unmarshal configuration: %w", err)
 	}
 
-	if err := config.Validate(); err != nil {
-		return true, "", errors.Join(
-			fmt.Errorf("invalid configuration:"),
-			err,
-		)
+	return v, true, nil
+}
+
+// Validate checks the activity options, name is the configuration key used in
+// the error messages and d the default options merged with the empty values.
+func (c ActivityConfig) Validate(name string, d ActivityConfig) error {
+	var errs error
+
+	if c.StartToCloseTimeout < 0 {
+		errs = errors.Join(errs, fmt.Errorf(
+			"%s.StartToCloseTimeout: %s is less than the minimum value (0s)",
+			name, c.StartToCloseTimeout,
+		))
 	}
+	if c.HeartbeatTimeout < 0 {
+		errs = errors.Join(errs, fmt.Errorf(
+			"%s.HeartbeatTimeout: %s is less than the minimum value (0s)",
+			name, c.HeartbeatTimeout,
+		))
+	}
+	errs = errors.Join(errs, c.RetryPolicy.Validate(name+".RetryPolicy", d.RetryPolicy))
 
-	return true, v.ConfigFileUsed(), nil
+	return errs
+}
+
+// Validate checks the retry policy values, name is the configuration key used
+// in the error messages. Zero values are valid, they are replaced by the
+// values of the d default policy.
+func (c RetryPolicyConfig) Validate(name string, d RetryPolicyConfig) error {
+	var errs error
+
+	if c.InitialInterval < 0 {
+		errs = errors.Join(errs, fmt.Errorf(
+			"%s.InitialInterval: %s is less than the minimum value (0s)",
+			name, c.InitialInterval,
+		))
+	}
+	if c.BackoffCoefficient != 0 && c.BackoffCoefficient < 1 {
+		errs = errors.Join(errs, fmt.Errorf(
+			"%s.BackoffCoefficient: %v is less than the minimum value (1)",
+			name, c.BackoffCoefficient,
+		))
+	}
+	if c.MaximumInterval < 0 {
+		errs = errors.Join(errs, fmt.Errorf(
+			"%s.MaximumInterval: %s is less than the minimum value (0s)",
+			name, c.MaximumInterval,
+		))
+	}
+	// The intervals are compared once merged with the default policy, only
+	// when one of them is set so an invalid default is reported once.
+	if m := c.Merge(d); (c.InitialInterval != 0 || c.MaximumInterval != 0) &&
+		m.MaximumInterval > 0 && m.MaximumInterval < m.InitialInterval {
+		errs = errors.Join(errs, fmt.Errorf(
+			"%s.MaximumInterval: %s is less than InitialInterval (%s)",
+			name, m.MaximumInterval, m.InitialInterval,
+		))
+	}
+	if c.MaximumAttempts < 0 {
+		errs = errors.Join(errs, fmt.Errorf(
+			"%s.MaximumAttempts: %d is less than the minimum value (0)",
+			name, c.MaximumAttempts,
+		))
+	}
+	if c.MaximumAttempts > math.MaxInt32 {
+		errs = errors.Join(errs, fmt.Errorf(
+			"%s.MaximumAttempts: %d is greater than the maximum value (%d)",
+			name, c.MaximumAttempts, math.MaxInt32,
+		))
+	}
+
+	return errs
 }
 
 func errRequired(name string) error {
@@ -1,7 +1,9 @@
 package config_test
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"gotest.tools/v3/assert"
 	"gotest.tools/v3/fs"
@@ -20,6 +22,51 @@ taskQueue = "preprocessing"
 workflowName = "preprocessing"
 [worker]
 maxConcurrentSessions = 1
+maxConcurrentActivityExecutionSize = 10
+maxConcurrentWorkflowTaskExecutionSize = 20
+maxConcurrentActivityTaskPollers = 4
+maxConcurrentWorkflowTaskPollers = 2
+workerActivitiesPerSecond = 0.5
+taskQueueActivitiesPerSecond = 2.5
+stickyWorkflowCacheSize = 100
+activityTaskQueue = "preprocessing-activities"
+sessionCreationTimeout = "30m"
+[activities.default]
+startToCloseTimeout = "10m"
+[activities.removeFiles]
+heartbeatTimeout = "30s"
+[activities.removeFiles.retryPolicy]
+maximumAttempts = 5
+nonRetryableErrorTypes = ["TransientIOError"]
+[unwantedFiles]
+names = [".DS_Store", "Thumbs.db"]
+patterns = ["^\\._"]
+[review]
+timeout = "48h"
+[validation]
+blockingSeverity = "warning"
+[report]
+format = "markdown"
+[staging]
+enabled = true
+hardlinks = true
+[lock]
+mode = "fail"
+timeout = "2h"
+pollInterval = "30s"
+[trash]
+retention = "168h"
+schedule = "30 1 * * *"
+[[codec.keys]]
+id = "k2"
+key = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+[[codec.keys]]
+id = "k1"
+key = "MDEyMzQ1Njc4OWFiY2RlZg=="
+[codec.server]
+address = "localhost:8089"
+origins = ["https://temporal.example.org"]
+tokens = ["secret-token"]
 `
 
 func TestConfig(t *testing.T) {
@@ -51,7 +98,80 @@ func TestConfig(t *testing.T) {
 					WorkflowName: "preprocessing",
 				},
 				Worker: config.WorkerConfig{
-					MaxConcurrentSessions: 1,
+					MaxConcurrentSessions:                  1,
+					MaxConcurrentActivityExecutionSize:     10,
+					MaxConcurrentWorkflowTaskExecutionSize: 20,
+					MaxConcurrentActivityTaskPollers:       4,
+					MaxConcurrentWorkflowTaskPollers:       2,
+					WorkerActivitiesPerSecond:              0.5,
+					TaskQueueActivitiesPerSecond:           2.5,
+					StickyWorkflowCacheSize:                100,
+					ActivityTaskQueue:                      "preprocessing-activities",
+					SessionCreationTimeout:                 30 * time.Minute,
+					SessionExecutionTimeout:                24 * time.Hour,
+				},
+				Activities: config.ActivitiesConfig{
+					Default: config.ActivityConfig{
+						StartToCloseTimeout: 10 * time.Minute,
+						RetryPolicy: config.RetryPolicyConfig{
+							InitialInterval:    time.Second,
+							BackoffCoefficient: 2,
+							MaximumAttempts:    3,
+						},
+					},
+					RemoveFiles: config.ActivityConfig{
+						StartToCloseTimeout: 24 * time.Hour,
+						HeartbeatTimeout:    30 * time.Second,
+						RetryPolicy: config.RetryPolicyConfig{
+							MaximumAttempts:        5,
+							NonRetryableErrorTypes: []string{"TransientIOError"},
+						},
+					},
+					MacMetadata: config.ActivityConfig{
+						StartToCloseTimeout: 24 * time.Hour,
+						HeartbeatTimeout:    time.Minute,
+					},
+					Timestamps: config.ActivityConfig{
+						StartToCloseTimeout: 24 * time.Hour,
+						HeartbeatTimeout:    time.Minute,
+					},
+					WriteReport: config.ActivityConfig{
+						StartToCloseTimeout: 24 * time.Hour,
+						HeartbeatTimeout:    time.Minute,
+					},
+					Stage: config.ActivityConfig{
+						StartToCloseTimeout: 24 * time.Hour,
+						HeartbeatTimeout:    time.Minute,
+					},
+				},
+				UnwantedFiles: config.UnwantedFilesConfig{
+					Names:    []string{".DS_Store", "Thumbs.db"},
+					Patterns: []string{`^\._`},
+				},
+				Review:     config.ReviewConfig{Timeout: 48 * time.Hour},
+				Validation: config.ValidationConfig{BlockingSeverity: "warning"},
+				Report:     config.ReportConfig{Format: "markdown"},
+				TimestampCheck: config.TimestampCheckConfig{
+					MinDate:   "1980-01-02",
+					MaxFuture: 24 * time.Hour,
+				},
+				Staging: config.StagingConfig{Enabled: true, Hardlinks: true},
+				Lock: config.LockConfig{
+					Mode:         config.LockModeFail,
+					Timeout:      2 * time.Hour,
+					PollInterval: 30 * time.Second,
+				},
+				Trash: config.TrashConfig{Retention: 168 * time.Hour, Schedule: "30 1 * * *"},
+				Codec: config.CodecConfig{
+					Keys: []config.CodecKey{
+						{ID: "k2", Key: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="},
+						{ID: "k1", Key: "MDEyMzQ1Njc4OWFiY2RlZg=="},
+					},
+					Server: config.CodecServerConfig{
+						Address: "localhost:8089",
+						Origins: []string{"https://temporal.example.org"},
+						Tokens:  []string{"secret-token"},
+					},
 				},
 			},
 		},
@@ -78,6 +198,224 @@ maxConcurrentSessions = -1
 			wantFound: true,
 			wantErr:   `Worker.MaxConcurrentSessions: -1 is less than the minimum value (1)`,
 		},
+		{
+			name:       "Errors when worker tuning values are not valid",
+			configFile: "preprocessing.toml",
+			toml: `# Config
+sharedPath = "/home/preprocessing/shared"
+[temporal]
+taskQueue = "preprocessing"
+workflowName = "preprocessing"
+[worker]
+maxConcurrentActivityExecutionSize = -1
+maxConcurrentWorkflowTaskPollers = 1
+workerActivitiesPerSecond = -0.5
+activityTaskQueue = "preprocessing"
+sessionExecutionTimeout = "-1h"
+`,
+			wantFound: true,
+			wantErr: `invalid configuration:
+Worker.MaxConcurrentActivityExecutionSize: -1 is less than the minimum value (0)
+Worker.WorkerActivitiesPerSecond: -0.5 is less than the minimum value (0)
+Worker.MaxConcurrentWorkflowTaskPollers: 1 is not a valid value
+Worker.SessionExecutionTimeout: -1h0m0s is less than the minimum value (0s)
+Worker.ActivityTaskQueue: "preprocessing" must be different from Temporal.TaskQueue`,
+		},
+		{
+			name:       "Errors when activity timeouts are negative",
+			configFile: "preprocessing.toml",
+			toml: `# Config
+sharedPath = "/home/preprocessing/shared"
+[temporal]
+taskQueue = "preprocessing"
+workflowName = "preprocessing"
+[activities.removeFiles]
+heartbeatTimeout = "-1m"
+`,
+			wantFound: true,
+			wantErr:   `Activities.RemoveFiles.HeartbeatTimeout: -1m0s is less than the minimum value (0s)`,
+		},
+		{
+			name:       "Errors when retry policy values are not valid",
+			configFile: "preprocessing.toml",
+			toml: `# Config
+sharedPath = "/home/preprocessing/shared"
+[temporal]
+taskQueue = "preprocessing"
+workflowName = "preprocessing"
+[activities.default.retryPolicy]
+initialInterval = "10s"
+backoffCoefficient = 0.5
+maximumInterval = "5s"
+maximumAttempts = -1
+`,
+			wantFound: true,
+			wantErr: `invalid configuration:
+Activities.Default.RetryPolicy.BackoffCoefficient: 0.5 is less than the minimum value (1)
+Activities.Default.RetryPolicy.MaximumInterval: 5s is less than InitialInterval (10s)
+Activities.Default.RetryPolicy.MaximumAttempts: -1 is less than the minimum value (0)`,
+		},
+		{
+			name:       "Errors when merged retry policy values are not valid",
+			configFile: "preprocessing.toml",
+			toml: `# Config
+sharedPath = "/home/preprocessing/shared"
+[temporal]
+taskQueue = "preprocessing"
+workflowName = "preprocessing"
+[activities.default.retryPolicy]
+initialInterval = "10s"
+maximumInterval = "1m"
+[activities.removeFiles.retryPolicy]
+maximumInterval = "5s"
+[activities.writeReport.retryPolicy]
+initialInterval = "2m"
+[activities.stage.retryPolicy]
+initialInterval = "30s"
+[activities.timestamps.retryPolicy]
+initialInterval = "1s"
+maximumInterval = "5s"
+`,
+			wantFound: true,
+			wantErr: `invalid configuration:
+Activities.RemoveFiles.RetryPolicy.MaximumInterval: 5s is less than InitialInterval (10s)
+Activities.WriteReport.RetryPolicy.MaximumInterval: 1m0s is less than InitialInterval (2m0s)`,
+		},
+		{
+			name:       "Errors when unwanted file patterns are not valid",
+			configFile: "preprocessing.toml",
+			toml: `# Config
+sharedPath = "/home/preprocessing/shared"
+[temporal]
+taskQueue = "preprocessing"
+workflowName = "preprocessing"
+[unwantedFiles]
+patterns = ["("]
+`,
+			wantFound: true,
+			wantErr:   "UnwantedFiles.Patterns: error parsing regexp: missing closing ): `(`",
+		},
+		{
+			name:       "Errors when the blocking severity is not valid",
+			configFile: "preprocessing.toml",
+			toml: `# Config
+sharedPath = "/home/preprocessing/shared"
+[temporal]
+taskQueue = "preprocessing"
+workflowName = "preprocessing"
+[validation]
+blockingSeverity = "fatal"
+`,
+			wantFound: true,
+			wantErr:   `Validation.BlockingSeverity: invalid severity "fatal", must be one of: info, warning, error`,
+		},
+		{
+			name:       "Errors when the lock configuration is not valid",
+			configFile: "preprocessing.toml",
+			toml: `# Config
+sharedPath = "/home/preprocessing/shared"
+[temporal]
+taskQueue = "preprocessing"
+workflowName = "preprocessing"
+[lock]
+mode = "skip"
+timeout = "-1h"
+`,
+			wantFound: true,
+			wantErr: `invalid configuration:
+Lock.Mode: "skip" is not one of: wait, fail
+Lock.Timeout: -1h0m0s is less than the minimum value (0s)`,
+		},
+		{
+			name:       "Errors when the trash retention is negative",
+			configFile: "preprocessing.toml",
+			toml: `# Config
+sharedPath = "/home/preprocessing/shared"
+[temporal]
+taskQueue = "preprocessing"
+workflowName = "preprocessing"
+[trash]
+retention = "-1h"
+`,
+			wantFound: true,
+			wantErr: `invalid configuration:
+Trash.Retention: -1h0m0s is less than the minimum value (0s)`,
+		},
+		{
+			name:       "Errors when the timestamp check configuration is not valid",
+			configFile: "preprocessing.toml",
+			toml: `# Config
+sharedPath = "/home/preprocessing/shared"
+[temporal]
+taskQueue = "preprocessing"
+workflowName = "preprocessing"
+[timestampCheck]
+minDate = "01/02/1980"
+maxFuture = "-1h"
+`,
+			wantFound: true,
+			wantErr: `invalid configuration:
+TimestampCheck.MinDate: "01/02/1980" is not a valid date (YYYY-MM-DD)
+TimestampCheck.MaxFuture: -1h0m0s is less than the minimum value (0s)`,
+		},
+		{
+			name:       "Errors when both staging and journal are enabled",
+			configFile: "preprocessing.toml",
+			toml: `# Config
+sharedPath = "/home/preprocessing/shared"
+[temporal]
+taskQueue = "preprocessing"
+workflowName = "preprocessing"
+[staging]
+enabled = true
+[journal]
+enabled = true
+`,
+			wantFound: true,
+			wantErr: `invalid configuration:
+Journal.Enabled: can't be enabled with Staging.Enabled`,
+		},
+		{
+			name:       "Errors when the codec configuration is not valid",
+			configFile: "preprocessing.toml",
+			toml: `# Config
+sharedPath = "/home/preprocessing/shared"
+[temporal]
+taskQueue = "preprocessing"
+workflowName = "preprocessing"
+[[codec.keys]]
+id = "k1"
+key = "not base64"
+[[codec.keys]]
+id = "k1"
+key = "MDEyMzQ1Njc4OQ=="
+[[codec.keys]]
+key = "MDEyMzQ1Njc4OWFiY2RlZg=="
+[codec.server]
+address = "localhost:8089"
+`,
+			wantFound: true,
+			wantErr: `invalid configuration:
+Codec.Keys[0].Key: invalid base64 value
+Codec.Keys[1].ID: duplicate key ID "k1"
+Codec.Keys[1].Key: 10 bytes key, must be 16, 24 or 32 bytes
+Codec.Keys[2].ID: missing required value
+Codec.Server.Tokens: required with Codec.Server.Address`,
+		},
+		{
+			name:       "Errors when the configuration has unknown keys",
+			configFile: "preprocessing.toml",
+			toml: `# Config
+sharedPath = "/home/preprocessing/shared"
+[temporal]
+taskQueue = "preprocessing"
+workflowName = "preprocessing"
+[worker]
+maxConcurentSessions = 2
+`,
+			wantFound: true,
+			wantErr:   "unknown configuration keys: worker.maxconcurentsessions",
+		},
 		{
 			name:       "Errors when TOML is invalid",
 			configFile: "preprocessing.toml",
@@ -123,3 +461,143 @@ maxConcurrentSessions = -1
 		})
 	}
 }
+
+func TestReadWarnUnknownKeys(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := fs.NewDir(t, "preprocessing-test", fs.WithFile("preprocessing.toml", `# Config
+sharedPath = "/home/preprocessing/shared"
+typo = true
+[temporal]
+taskQueue = "preprocessing"
+workflowName = "preprocessing"
+[worker]
+maxConcurentSessions = 2
+`))
+
+	var c config.Configuration
+	var keys []string
+	found, _, err := config.Read(&c, tmpDir.Join("preprocessing.toml"), config.WarnUnknownKeys(&keys))
+	assert.NilError(t, err)
+	assert.Equal(t, found, true)
+	assert.DeepEqual(t, keys, []string{"typo", "worker.maxconcurentsessions"})
+	assert.Equal(t, c.Worker.MaxConcurrentSessions, 1)
+}
+
+func TestResultsDir(t *testing.T) {
+	t.Parallel()
+
+	c := config.Configuration{SharedPath: "/home/preprocessing/shared"}
+	assert.Equal(t, c.ResultsDir(), "/home/preprocessing/shared/.results")
+
+	c.ResultsPath = "/home/preprocessing/results"
+	assert.Equal(t, c.ResultsDir(), "/home/preprocessing/results")
+}
+
+func TestStagingDir(t *testing.T) {
+	t.Parallel()
+
+	c := config.Configuration{SharedPath: "/home/preprocessing/shared"}
+	assert.Equal(t, c.StagingDir(), "/home/preprocessing/shared/.staging")
+
+	c.StagingPath = "/home/preprocessing/staging"
+	assert.Equal(t, c.StagingDir(), "/home/preprocessing/staging")
+}
+
+func TestJournalDir(t *testing.T) {
+	t.Parallel()
+
+	c := config.Configuration{SharedPath: "/home/preprocessing/shared"}
+	assert.Equal(t, c.JournalDir(), "/home/preprocessing/shared/.journal")
+
+	c.JournalPath = "/home/preprocessing/journal"
+	assert.Equal(t, c.JournalDir(), "/home/preprocessing/journal")
+}
+
+func TestResolve(t *testing.T) {
+	t.Setenv("ENDURO_PREPROCESSING_TEMPORAL_WORKFLOWNAME", "env-workflow")
+	t.Setenv("ENDURO_PREPROCESSING_WORKER_MAXCONCURRENTSESSIONS", "7")
+	t.Setenv("ENDURO_PREPROCESSING_WORKER_MAXCONCURRENTACTIVITYEXECUTIONSIZE", "7")
+
+	tmpDir := fs.NewDir(t, "preprocessing-test", fs.WithFile("preprocessing.toml", `# Config
+sharedPath = "/home/preprocessing/shared"
+[temporal]
+taskQueue = "preprocessing"
+workflowName = "preprocessing"
+`))
+
+	r, err := config.Resolve(tmpDir.Join("preprocessing.toml"))
+	assert.NilError(t, err)
+	assert.Equal(t, r.Found, true)
+	assert.Equal(t, r.ConfigFileUsed, tmpDir.Join("preprocessing.toml"))
+	assert.Equal(t, r.Config.Temporal.WorkflowName, "env-workflow")
+	assert.Equal(t, r.Config.Worker.MaxConcurrentSessions, 7)
+	assert.Equal(t, r.Config.Worker.MaxConcurrentActivityExecutionSize, 0)
+
+	settings := map[string]config.Setting{}
+	for _, s := range r.Settings {
+		settings[s.Key] = s
+	}
+	assert.DeepEqual(t, settings["SharedPath"], config.Setting{
+		Key:    "SharedPath",
+		Value:  "/home/preprocessing/shared",
+		Source: config.SourceFile,
+		Env:    "ENDURO_PREPROCESSING_SHAREDPATH",
+	})
+	assert.DeepEqual(t, settings["Temporal.WorkflowName"], config.Setting{
+		Key:    "Temporal.WorkflowName",
+		Value:  "env-workflow",
+		Source: config.SourceEnv,
+		Env:    "ENDURO_PREPROCESSING_TEMPORAL_WORKFLOWNAME",
+	})
+	assert.DeepEqual(t, settings["Worker.MaxConcurrentSessions"], config.Setting{
+		Key:    "Worker.MaxConcurrentSessions",
+		Value:  7,
+		Source: config.SourceEnv,
+		Env:    "ENDURO_PREPROCESSING_WORKER_MAXCONCURRENTSESSIONS",
+	})
+	// The environment variables of the keys without a default or a value in
+	// the configuration file are ignored.
+	assert.DeepEqual(t, settings["Worker.MaxConcurrentActivityExecutionSize"], config.Setting{
+		Key:    "Worker.MaxConcurrentActivityExecutionSize",
+		Value:  0,
+		Source: config.SourceUnset,
+		Env:    "ENDURO_PREPROCESSING_WORKER_MAXCONCURRENTACTIVITYEXECUTIONSIZE",
+	})
+	assert.DeepEqual(t, settings["Activities.RemoveFiles.HeartbeatTimeout"], config.Setting{
+		Key:    "Activities.RemoveFiles.HeartbeatTimeout",
+		Value:  "1m0s",
+		Source: config.SourceDefault,
+		Env:    "ENDURO_PREPROCESSING_ACTIVITIES_REMOVEFILES_HEARTBEATTIMEOUT",
+	})
+	assert.DeepEqual(t, settings["Temporal.Address"], config.Setting{
+		Key:    "Temporal.Address",
+		Value:  "",
+		Source: config.SourceUnset,
+		Env:    "ENDURO_PREPROCESSING_TEMPORAL_ADDRESS",
+	})
+}
+
+func TestResolveRedactsSecrets(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := fs.NewDir(t, "preprocessing-test", fs.WithFile("preprocessing.toml", testConfig))
+
+	r, err := config.Resolve(tmpDir.Join("preprocessing.toml"))
+	assert.NilError(t, err)
+	assert.Equal(t, len(r.Config.Codec.Keys), 2)
+
+	settings := map[string]config.Setting{}
+	for _, s := range r.Settings {
+		settings[s.Key] = s
+	}
+	for _, key := range []string{"Codec.Keys", "Codec.Server.Tokens"} {
+		assert.DeepEqual(t, settings[key], config.Setting{
+			Key:    key,
+			Value:  "[REDACTED]",
+			Source: config.SourceFile,
+			Env:    "ENDURO_PREPROCESSING_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_")),
+		})
+	}
+	assert.DeepEqual(t, settings["Codec.Server.Address"].Value, "localhost:8089")
+}
@@ -0,0 +1,108 @@
+// Code generated by gendocs; DO NOT EDIT.
+
+package config
+
+// fieldDocs are the doc comments of the configuration struct fields, indexed
+// by type and field name.
+var fieldDocs = map[string]map[string]string{
+	"ActivitiesConfig": {
+		"Default":     "Default sets the options for activities without their own configuration, and the options left empty in an activity configuration.",
+		"MacMetadata": "MacMetadata sets the options for the activity preserving the macOS metadata.",
+		"RemoveFiles": "RemoveFiles sets the options for the activity removing unwanted files.",
+		"Stage":       "Stage sets the options for the activities staging a copy of the SIP, publishing it and discarding it.",
+		"Timestamps":  "Timestamps sets the options for the activities capturing, restoring and checking the file timestamps.",
+		"WriteReport": "WriteReport sets the options for the activity writing the preprocessing report.",
+	},
+	"ActivityConfig": {
+		"HeartbeatTimeout":    "HeartbeatTimeout is the maximum time between activity heartbeats, long running activities fail when they don't report progress within this time (default: no heartbeat timeout, \"1m\" for RemoveFiles, MacMetadata, Timestamps, WriteReport and Stage).",
+		"RetryPolicy":         "RetryPolicy sets how failed activity attempts are retried.",
+		"StartToCloseTimeout": "StartToCloseTimeout is the maximum time of a single activity attempt (default: \"5m\", \"24h\" for RemoveFiles, MacMetadata, Timestamps, WriteReport and Stage).",
+	},
+	"CodecConfig": {
+		"Keys":   "Keys lists the AES keys encrypting the Temporal payloads, e.g. the workflow inputs and results. The payloads are encrypted with the first key and decrypted with the key of their key ID, add a new key first to rotate the keys. The payloads aren't encrypted if there are no keys.",
+		"Server": "Server is the codec server used by the Temporal UI and CLI to decode the encrypted payloads.",
+	},
+	"CodecKey": {
+		"ID":  "ID identifies the key in the encrypted payloads (required).",
+		"Key": "Key is the base64 encoded AES key, of 16, 24 or 32 bytes (required).",
+	},
+	"CodecServerConfig": {
+		"Address": "Address is the host and port the codec server listens on, e.g. \"localhost:8089\". The codec server is disabled if empty.",
+		"Origins": "Origins lists the origins allowed to call the codec server from a browser, e.g. the Temporal UI \"https://temporal.example.org\".",
+		"Tokens":  "Tokens lists the tokens authorizing the codec server requests, sent in an \"Authorization: Bearer <token>\" header (required with an Address).",
+	},
+	"Configuration": {
+		"Debug":       "Debug toggles human readable logs or JSON logs (default).",
+		"JournalPath": "JournalPath is the directory of the journals of the SIP mutations of the workflow runs, with the trash of the removed files (default: \"<SharedPath>/.journal\"). It must be in the same filesystem as SharedPath.",
+		"ResultsPath": "ResultsPath is the directory of the large activity results, e.g. the lists of removed files, referenced by the workflow results (default: \"<SharedPath>/.results\"). Enduro must be able to access it.",
+		"SharedPath":  "SharedPath is a file path that both Preprocessing and Enduro can access (required).\n\nEnduro will deposit transfers in SharedPath for preprocessing. Preprocessing must write transfer updates to SharedPath for retrieval by Enduro and preservation processing.",
+		"StagingPath": "StagingPath is the directory of the staged SIP copies processed when Staging is enabled (default: \"<SharedPath>/.staging\"). It must be in the same filesystem as SharedPath.",
+		"Verbosity":   "Verbosity sets the verbosity level of log messages, with 0 (default) logging only critical messages and each higher number increasing the number of messages logged.",
+	},
+	"JournalConfig": {
+		"Enabled": "Enabled journals the mutations of the SIP, moving the removed files to the trash instead of deleting them, and rolls them back when the workflow fails or is canceled. It can't be enabled with Staging (default: false).",
+	},
+	"LockConfig": {
+		"Mode":         "Mode sets what a workflow does when the relative path is being processed by another workflow execution, \"wait\" or \"fail\" (default: \"wait\").",
+		"PollInterval": "PollInterval is the time between the attempts to lock the relative path in \"wait\" mode (default: \"1m\").",
+		"Timeout":      "Timeout is the maximum time a workflow waits for the relative path to be released in \"wait\" mode, zero waits indefinitely (default: \"0s\").",
+	},
+	"MacMetadataConfig": {
+		"Enabled": "Enabled preserves the metadata of the AppleDouble files (\"._<name>\") and the extended attributes of the SIP files in JSON sidecars in the SIP metadata/macos directory, before the unwanted files are removed (default: false).",
+	},
+	"ReportConfig": {
+		"Format": "Format is the format of the preprocessing report written in the SIP metadata/submissionDocumentation directory, \"html\" or \"markdown\" (default: \"html\").",
+	},
+	"RetryPolicyConfig": {
+		"BackoffCoefficient":     "BackoffCoefficient multiplies the interval between retries after each attempt, it must be 1 or larger (default: 2).",
+		"InitialInterval":        "InitialInterval is the time to wait before the first retry (default: \"1s\").",
+		"MaximumAttempts":        "MaximumAttempts is the maximum number of attempts, including the first one (default: 3).",
+		"MaximumInterval":        "MaximumInterval caps the interval between retries (default: 100x InitialInterval).",
+		"NonRetryableErrorTypes": "NonRetryableErrorTypes lists the error types that are never retried. Activity errors caused by invalid content are never retried, this list can be used to also stop retrying transient errors, e.g. [\"TransientIOError\"] (optional).",
+	},
+	"ReviewConfig": {
+		"Timeout": "Timeout is the maximum time a workflow waits for the review decision of a SIP, when a review is requested. The SIP is rejected when it expires, zero waits indefinitely (default: \"72h\").",
+	},
+	"StagingConfig": {
+		"Enabled":   "Enabled processes a copy of the SIP in the StagingPath, which replaces the SIP only when the workflow succeeds. The SIP is left untouched when the workflow fails (default: false).",
+		"Hardlinks": "Hardlinks links the SIP files into the staged copy when the filesystem can't reflink them, instead of copying them. It's faster and it doesn't use more space, but the staged files share their content and metadata with the SIP files (default: false).",
+	},
+	"Temporal": {
+		"Address":      "Address is the Temporal server host and port (default: \"localhost:7233\").",
+		"Namespace":    "Namespace is the Temporal namespace the preprocessing worker should run in (default: \"default\").",
+		"TaskQueue":    "TaskQueue is the Temporal task queue from which the preprocessing worker will pull tasks (required).",
+		"WorkflowName": "WorkflowName is the name of the preprocessing Temporal workflow (required).",
+	},
+	"TimestampCheckConfig": {
+		"Enabled":   "Enabled reports the SIP files modified before MinDate or later than MaxFuture after the workflow start as warning findings, e.g. the files dated by a camera with a reset or wrong clock (default: false).",
+		"MaxFuture": "MaxFuture is how far after the workflow start a modification time is valid, allowing for the clock differences between the systems (default: \"24h\").",
+		"MinDate":   "MinDate is the earliest valid modification date, \"YYYY-MM-DD\" in UTC. The default flags the Unix (1970-01-01) and FAT (1980-01-01) epochs, no lower bound is checked if empty (default: \"1980-01-02\").",
+	},
+	"TimestampsConfig": {
+		"Enabled": "Enabled captures the timestamps of the SIP files at intake in the SIP metadata/preprocessing-timestamps.json file, and restores their modification times once the SIP is processed (default: false).",
+	},
+	"TrashConfig": {
+		"Retention": "Retention is how long the journals, with the trash of the removed files when Journal is enabled, are kept after their last mutation. Zero keeps them indefinitely (default: \"720h\").",
+		"Schedule":  "Schedule is the cron expression, in UTC, of the housekeeping workflow purging the journals older than Retention, it's applied by the bootstrap command (default: \"0 3 * * *\").",
+	},
+	"UnwantedFilesConfig": {
+		"Names":    "Names lists the names of the files and directories removed from the SIPs (default: [\".DS_Store\"]).",
+		"Patterns": "Patterns lists regular expressions matching the names of the files and directories removed from the SIPs (optional).",
+	},
+	"ValidationConfig": {
+		"BlockingSeverity": "BlockingSeverity is the minimum severity (\"info\", \"warning\" or \"error\") of the findings failing the preprocessing, so the SIP isn't ingested (default: \"error\").",
+	},
+	"WorkerConfig": {
+		"ActivityTaskQueue":                      "ActivityTaskQueue is a dedicated Temporal task queue for the preprocessing activities. When set, a separate worker polls this queue for activity tasks and Temporal.TaskQueue is only used for workflow tasks (optional).",
+		"MaxConcurrentActivityExecutionSize":     "MaxConcurrentActivityExecutionSize limits the number of activities the worker can execute simultaneously (default: Temporal SDK default).",
+		"MaxConcurrentActivityTaskPollers":       "MaxConcurrentActivityTaskPollers sets the number of goroutines polling the Temporal server for activity tasks (default: Temporal SDK default).",
+		"MaxConcurrentSessions":                  "MaxConcurrentSessions limits the number of workflow sessions the preprocessing worker can handle simultaneously (default: 1).",
+		"MaxConcurrentWorkflowTaskExecutionSize": "MaxConcurrentWorkflowTaskExecutionSize limits the number of workflow tasks the worker can execute simultaneously. It can't be 1 (default: Temporal SDK default).",
+		"MaxConcurrentWorkflowTaskPollers":       "MaxConcurrentWorkflowTaskPollers sets the number of goroutines polling the Temporal server for workflow tasks. It can't be 1 (default: Temporal SDK default).",
+		"SessionCreationTimeout":                 "SessionCreationTimeout is how long a workflow waits for a worker to accept its session, e.g. when all the workers run MaxConcurrentSessions sessions. The workflow fails when it expires (default: \"10m\").",
+		"SessionExecutionTimeout":                "SessionExecutionTimeout is the maximum duration of the session processing a SIP, the activities still running when it expires are canceled (default: \"24h\").",
+		"StickyWorkflowCacheSize":                "StickyWorkflowCacheSize sets the number of workflow executions cached by the worker process (default: Temporal SDK default).",
+		"TaskQueueActivitiesPerSecond":           "TaskQueueActivitiesPerSecond limits the number of activities per second started across all the workers polling the activity task queue, it's enforced by the Temporal server (default: Temporal SDK default).",
+		"WorkerActivitiesPerSecond":              "WorkerActivitiesPerSecond limits the number of activities per second this worker can start, values lower than 1 are allowed (default: Temporal SDK default).",
+	},
+}
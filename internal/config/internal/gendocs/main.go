@@ -0,0 +1,154 @@
+// Command gendocs generates the fieldDocs map of the config package from the
+// doc comments of the configuration struct fields, it's run by go generate in
+// the config package directory:
+//
+//	go run ./internal/gendocs -output docs_gen.go
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// rootType is the type of the configuration, only the struct types reachable
+// from its fields are documented.
+const rootType = "Configuration"
+
+func main() {
+	dir := flag.String("dir", ".", "Package directory")
+	output := flag.String("output", "docs_gen.go", "Output file, relative to the package directory")
+	flag.Parse()
+
+	b, err := generate(*dir, *output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gendocs: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(filepath.Join(*dir, *output), b, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "gendocs: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// generate returns the source of the fieldDocs map of the package in dir,
+// ignoring the test files and the output file.
+func generate(dir, output string) ([]byte, error) {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != output
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("found %d packages in %s, expected one", len(pkgs), dir)
+	}
+
+	var pkgName string
+	structs := map[string]*ast.StructType{}
+	for name, pkg := range pkgs {
+		pkgName = name
+		for _, f := range pkg.Files {
+			for _, decl := range f.Decls {
+				gd, ok := decl.(*ast.GenDecl)
+				if !ok || gd.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gd.Specs {
+					ts := spec.(*ast.TypeSpec)
+					if st, ok := ts.Type.(*ast.StructType); ok {
+						structs[ts.Name.Name] = st
+					}
+				}
+			}
+		}
+	}
+	if structs[rootType] == nil {
+		return nil, fmt.Errorf("type %s not found in %s", rootType, dir)
+	}
+
+	docs := map[string]map[string]string{}
+	collect(rootType, structs, docs)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by gendocs; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkgName)
+	fmt.Fprintf(&buf, "// fieldDocs are the doc comments of the configuration struct fields, indexed\n")
+	fmt.Fprintf(&buf, "// by type and field name.\n")
+	fmt.Fprintf(&buf, "var fieldDocs = map[string]map[string]string{\n")
+	for _, typeName := range sortedKeys(docs) {
+		fmt.Fprintf(&buf, "%s: {\n", strconv.Quote(typeName))
+		for _, fieldName := range sortedKeys(docs[typeName]) {
+			fmt.Fprintf(&buf, "%s: %s,\n", strconv.Quote(fieldName), strconv.Quote(docs[typeName][fieldName]))
+		}
+		fmt.Fprintf(&buf, "},\n")
+	}
+	fmt.Fprintf(&buf, "}\n")
+
+	return format.Source(buf.Bytes())
+}
+
+// newlines matches the line breaks inside a paragraph.
+var newlines = regexp.MustCompile(`([^\n])\n([^\n])`)
+
+// collect adds the field docs of the typeName struct, and of the package
+// struct types used by its fields, to docs.
+func collect(typeName string, structs map[string]*ast.StructType, docs map[string]map[string]string) {
+	st, ok := structs[typeName]
+	if !ok || docs[typeName] != nil {
+		return
+	}
+
+	docs[typeName] = map[string]string{}
+	for _, field := range st.Fields.List {
+		// Unwrap the comment lines, keeping the paragraphs.
+		doc := strings.TrimSpace(field.Doc.Text())
+		doc = newlines.ReplaceAllString(doc, "$1 $2")
+		for _, name := range field.Names {
+			if name.IsExported() && doc != "" {
+				docs[typeName][name.Name] = doc
+			}
+		}
+		collect(elemName(field.Type), structs, docs)
+	}
+}
+
+// elemName returns the name of the type of expr, or of its elements for the
+// pointer, slice and map types. It returns an empty string for the types of
+// other packages.
+func elemName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return elemName(t.X)
+	case *ast.ArrayType:
+		return elemName(t.Elt)
+	case *ast.MapType:
+		return elemName(t.Value)
+	default:
+		return ""
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// TestGenerate checks that the config package docs_gen.go file is up to date,
+// run `make gen-schema` to update it.
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	got, err := generate("../..", "docs_gen.go")
+	assert.NilError(t, err)
+
+	want, err := os.ReadFile("../../docs_gen.go")
+	assert.NilError(t, err)
+	assert.Equal(t, string(got), string(want), "docs_gen.go is out of date, run `make gen-schema`")
+}
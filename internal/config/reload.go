@@ -0,0 +1,196 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+)
+
+// Reloadable is the part of the configuration that can be changed without
+// restarting the worker. The changes are applied to the new workflow
+// executions.
+//
+// Each field mirrors the Configuration field with the same name and type, a
+// setting is made reloadable by adding its field here.
+type Reloadable struct {
+	Verbosity      int
+	Activities     ActivitiesConfig
+	UnwantedFiles  UnwantedFilesConfig
+	Review         ReviewConfig
+	Validation     ValidationConfig
+	Report         ReportConfig
+	MacMetadata    MacMetadataConfig
+	Timestamps     TimestampsConfig
+	TimestampCheck TimestampCheckConfig
+	Lock           LockConfig
+	Staging        StagingConfig
+	Journal        JournalConfig
+}
+
+// Reloadable returns the reloadable part of c. The Reloadable fields are
+// copied from the Configuration fields with the same name.
+func (c Configuration) Reloadable() Reloadable {
+	var r Reloadable
+	rv, cv := reflect.ValueOf(&r).Elem(), reflect.ValueOf(c)
+	for i := range rv.NumField() {
+		rv.Field(i).Set(cv.FieldByName(rv.Type().Field(i).Name))
+	}
+
+	return r
+}
+
+// applyReloadable sets the reloadable values of c from r.
+func (c *Configuration) applyReloadable(r Reloadable) {
+	rv, cv := reflect.ValueOf(r), reflect.ValueOf(c).Elem()
+	for i := range rv.NumField() {
+		cv.FieldByName(rv.Type().Field(i).Name).Set(rv.Field(i))
+	}
+}
+
+// Version returns a short digest identifying the r values.
+func (r Reloadable) Version() string {
+	b, _ := json.Marshal(r)
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:6])
+}
+
+// Watcher is a Provider reloading the configuration file when it changes.
+//
+// Only the Reloadable values are applied, the changes to any other value (e.g.
+// the Temporal task queue or address) are logged and ignored until the worker
+// is restarted.
+type Watcher struct {
+	logger          logr.Logger
+	configFile      string
+	warnUnknownKeys bool
+
+	mu       sync.RWMutex
+	cfg      Configuration
+	onReload []func(Configuration)
+}
+
+var _ Provider = (*Watcher)(nil)
+
+// NewWatcher returns a Watcher of configFile, the file cfg was read from. The
+// reloaded files with unknown keys are rejected, or only logged if
+// warnUnknownKeys is true, as with the WarnUnknownKeys option of Read.
+func NewWatcher(logger logr.Logger, cfg Configuration, configFile string, warnUnknownKeys bool) *Watcher {
+	return &Watcher{
+		logger:          logger,
+		configFile:      configFile,
+		warnUnknownKeys: warnUnknownKeys,
+		cfg:             cfg,
+	}
+}
+
+// Config returns the currently applied configuration.
+func (w *Watcher) Config() Configuration {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.cfg
+}
+
+// Version returns the version of the currently applied configuration.
+func (w *Watcher) Version() string {
+	return w.Config().Reloadable().Version()
+}
+
+// OnReload registers fn to be called with the new configuration after it has
+// been applied. It must be called before Watch.
+func (w *Watcher) OnReload(fn func(Configuration)) {
+	w.onReload = append(w.onReload, fn)
+}
+
+// Watch starts watching the configuration file for changes.
+func (w *Watcher) Watch() error {
+	var cfg Configuration
+	v, _, err := load(&cfg, w.configFile)
+	if err != nil {
+		return err
+	}
+
+	v.OnConfigChange(func(fsnotify.Event) { w.reload() })
+	v.WatchConfig()
+
+	return nil
+}
+
+func (w *Watcher) reload() {
+	var next Configuration
+	v, _, err := load(&next, w.configFile)
+	if err != nil {
+		w.logger.Error(err, "Configuration reload failed.")
+		return
+	}
+	if keys := unknownKeys(v); len(keys) > 0 {
+		if !w.warnUnknownKeys {
+			w.logger.Error(
+				fmt.Errorf("unknown configuration keys: %s", strings.Join(keys, ", ")),
+				"Configuration reload failed.",
+			)
+			return
+		}
+		w.logger.Info("Unknown configuration keys ignored.", "keys", keys)
+	}
+	if err := next.Validate(); err != nil {
+		w.logger.Error(err, "Configuration reload failed, invalid configuration.")
+		return
+	}
+
+	w.mu.Lock()
+	cur := w.cfg
+	if keys := changedKeys(cur, next); len(keys) > 0 {
+		w.logger.Info(
+			"Configuration changes require a restart, they have been ignored.",
+			"keys", keys,
+		)
+	}
+	if reflect.DeepEqual(cur.Reloadable(), next.Reloadable()) {
+		w.mu.Unlock()
+		return
+	}
+	cur.applyReloadable(next.Reloadable())
+	w.cfg = cur
+	w.mu.Unlock()
+
+	w.logger.Info("Configuration reloaded.", "version", cur.Reloadable().Version())
+	for _, fn := range w.onReload {
+		fn(cur)
+	}
+}
+
+// changedKeys returns the keys of the non-reloadable values that differ
+// between a and b.
+func changedKeys(a, b Configuration) []string {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+
+	var keys []string
+	for _, f := range fields(reflect.TypeOf(a), "", nil) {
+		if isReloadable(f.key) {
+			continue
+		}
+		if !reflect.DeepEqual(va.FieldByIndex(f.index).Interface(), vb.FieldByIndex(f.index).Interface()) {
+			keys = append(keys, f.key)
+		}
+	}
+
+	return keys
+}
+
+// isReloadable returns true if key is the key of a Reloadable field, or of one
+// of its nested values.
+func isReloadable(key string) bool {
+	name, _, _ := strings.Cut(key, ".")
+	_, ok := reflect.TypeOf(Reloadable{}).FieldByName(name)
+
+	return ok
+}
@@ -0,0 +1,110 @@
+package config_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+)
+
+const reloadConfig = `# Config
+verbosity = %d
+sharedPath = "/home/preprocessing/shared"
+[temporal]
+taskQueue = "%s"
+workflowName = "preprocessing"
+`
+
+func TestReloadableVersion(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Configuration{Verbosity: 1}
+	assert.Equal(t, len(cfg.Reloadable().Version()), 12)
+	assert.Equal(t, cfg.Reloadable().Version(), config.Configuration{Verbosity: 1}.Reloadable().Version())
+
+	// Non-reloadable values don't change the version.
+	cfg.SharedPath = "/tmp"
+	assert.Equal(t, cfg.Reloadable().Version(), config.Configuration{Verbosity: 1}.Reloadable().Version())
+
+	cfg.UnwantedFiles.Names = []string{"Thumbs.db"}
+	assert.Assert(t, cfg.Reloadable().Version() != config.Configuration{Verbosity: 1}.Reloadable().Version())
+}
+
+func TestWatcher(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := fs.NewDir(t, "", fs.WithFile("preprocessing.toml", fmt.Sprintf(reloadConfig, 0, "preprocessing")))
+	configFile := tmpDir.Join("preprocessing.toml")
+
+	var cfg config.Configuration
+	_, _, err := config.Read(&cfg, configFile)
+	assert.NilError(t, err)
+
+	w := config.NewWatcher(logr.Discard(), cfg, configFile, false)
+	version := w.Version()
+	reloaded := make(chan config.Configuration, 1)
+	w.OnReload(func(cfg config.Configuration) {
+		select {
+		case reloaded <- cfg:
+		default:
+		}
+	})
+	assert.NilError(t, w.Watch())
+
+	// The task queue change requires a restart and is ignored.
+	err = os.WriteFile(configFile, []byte(fmt.Sprintf(reloadConfig, 2, "other")), 0o600)
+	assert.NilError(t, err)
+
+	select {
+	case got := <-reloaded:
+		assert.Equal(t, got.Verbosity, 2)
+		assert.Equal(t, got.Temporal.TaskQueue, "preprocessing")
+	case <-time.After(5 * time.Second):
+		t.Fatal("configuration not reloaded")
+	}
+
+	assert.Equal(t, w.Config().Verbosity, 2)
+	assert.Equal(t, w.Config().Temporal.TaskQueue, "preprocessing")
+	assert.Assert(t, w.Version() != version)
+}
+
+func TestWatcherUnknownKeys(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := fs.NewDir(t, "", fs.WithFile("preprocessing.toml", fmt.Sprintf(reloadConfig, 0, "preprocessing")))
+	configFile := tmpDir.Join("preprocessing.toml")
+
+	var cfg config.Configuration
+	_, _, err := config.Read(&cfg, configFile)
+	assert.NilError(t, err)
+
+	w := config.NewWatcher(logr.Discard(), cfg, configFile, false)
+	reloaded := make(chan config.Configuration, 2)
+	w.OnReload(func(cfg config.Configuration) {
+		select {
+		case reloaded <- cfg:
+		default:
+		}
+	})
+	assert.NilError(t, w.Watch())
+
+	// The file with an unknown key is rejected.
+	err = os.WriteFile(configFile, []byte(fmt.Sprintf(reloadConfig, 3, "preprocessing")+"verbosty = 3\n"), 0o600)
+	assert.NilError(t, err)
+	time.Sleep(200 * time.Millisecond)
+	err = os.WriteFile(configFile, []byte(fmt.Sprintf(reloadConfig, 2, "preprocessing")), 0o600)
+	assert.NilError(t, err)
+
+	select {
+	case got := <-reloaded:
+		assert.Equal(t, got.Verbosity, 2)
+	case <-time.After(5 * time.Second):
+		t.Fatal("configuration not reloaded")
+	}
+}
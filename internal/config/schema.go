@@ -0,0 +1,88 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+	"unicode"
+)
+
+//go:generate go run ./internal/gendocs -output docs_gen.go
+
+// durationPattern matches the values accepted by time.ParseDuration, without
+// the negative ones.
+const durationPattern = `^(0|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`
+
+// JSONSchema returns a JSON Schema describing the preprocessing.toml file. The
+// keys use the lower camel case naming of the documentation, and unknown keys
+// are not allowed.
+func JSONSchema() ([]byte, error) {
+	s := schemaFor(reflect.TypeOf(Configuration{}), fieldDocs)
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["$id"] = "https://github.com/artefactual-sdps/preprocessing-moma/preprocessing.schema.json"
+	s["title"] = "Preprocessing MoMA worker configuration"
+
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
+
+func schemaFor(t reflect.Type, docs map[string]map[string]string) map[string]any {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return map[string]any{"type": "string", "pattern": durationPattern}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem(), docs)}
+	case reflect.Struct:
+		props := map[string]any{}
+		for i := range t.NumField() {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+
+			p := schemaFor(sf.Type, docs)
+			if doc := docs[t.Name()][sf.Name]; doc != "" {
+				p["description"] = doc
+			}
+			props[lowerCamel(sf.Name)] = p
+		}
+
+		return map[string]any{
+			"type":                 "object",
+			"properties":           props,
+			"additionalProperties": false,
+		}
+	default:
+		return map[string]any{}
+	}
+}
+
+// lowerCamel converts a Go field name to the configuration key naming, e.g.
+// "MaxConcurrentSessions" to "maxConcurrentSessions".
+func lowerCamel(name string) string {
+	r := []rune(name)
+	for i := range r {
+		// Lower the leading upper case runes, except for the first rune of
+		// the next word (e.g. "URLPath" to "urlPath").
+		if !unicode.IsUpper(r[i]) || (i > 0 && i+1 < len(r) && unicode.IsLower(r[i+1])) {
+			break
+		}
+		r[i] = unicode.ToLower(r[i])
+	}
+
+	return string(r)
+}
@@ -0,0 +1,24 @@
+package config_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+)
+
+// TestJSONSchema checks that preprocessing.schema.json is up to date, run
+// `make gen-schema` to update it.
+func TestJSONSchema(t *testing.T) {
+	t.Parallel()
+
+	got, err := config.JSONSchema()
+	assert.NilError(t, err)
+
+	path, err := filepath.Abs("../../preprocessing.schema.json")
+	assert.NilError(t, err)
+	golden.Assert(t, string(got), path)
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const envPrefix = "ENDURO_PREPROCESSING"
+
+// Source is the origin of a configuration value.
+type Source string
+
+const (
+	SourceEnv     Source = "env"
+	SourceFile    Source = "file"
+	SourceDefault Source = "default"
+	SourceUnset   Source = "unset"
+)
+
+// redacted replaces the values of the fields tagged with `secret:"true"`.
+const redacted = "[REDACTED]"
+
+// Setting is a resolved configuration value.
+type Setting struct {
+	// Key is the configuration key, e.g. "Worker.MaxConcurrentSessions".
+	Key string
+
+	// Value is the effective value, secrets are redacted.
+	Value any
+
+	// Source is where the value came from.
+	Source Source
+
+	// Env is the name of the environment variable that can set the value.
+	Env string
+}
+
+// Resolved is the effective configuration and the source of its values.
+type Resolved struct {
+	Config Configuration
+
+	// Found is true if a configuration file was found.
+	Found bool
+
+	// ConfigFileUsed is the path of the configuration file used, if found.
+	ConfigFileUsed string
+
+	Settings []Setting
+
+	// UnknownKeys lists the keys in the configuration file that don't match
+	// any configuration value.
+	UnknownKeys []string
+}
+
+// Resolve reads the configuration like Read does, but it doesn't validate it.
+// It returns the effective configuration and the source of each value.
+func Resolve(configFile string) (*Resolved, error) {
+	var r Resolved
+
+	v, found, err := load(&r.Config, configFile)
+	r.Found = found
+	if err != nil {
+		// Resolve the defaults and environment variables when there is no
+		// configuration file.
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return nil, err
+		}
+		if err := v.Unmarshal(&r.Config); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal configuration: %w", err)
+		}
+	}
+	if found {
+		r.ConfigFileUsed = v.ConfigFileUsed()
+	}
+	r.UnknownKeys = unknownKeys(v)
+
+	// The environment variables are only used for the keys with a default or
+	// a value in the configuration file, see viper.AutomaticEnv.
+	known := map[string]bool{}
+	for _, k := range v.AllKeys() {
+		known[k] = true
+	}
+
+	value := reflect.ValueOf(r.Config)
+	for _, f := range fields(reflect.TypeOf(r.Config), "", nil) {
+		s := Setting{
+			Key:   f.key,
+			Value: value.FieldByIndex(f.index).Interface(),
+			Env:   envName(f.key),
+		}
+
+		_, isEnv := os.LookupEnv(s.Env)
+		isKnown := known[strings.ToLower(f.key)]
+		switch {
+		case isEnv && isKnown:
+			s.Source = SourceEnv
+		case v.InConfig(f.key):
+			s.Source = SourceFile
+		case isKnown:
+			s.Source = SourceDefault
+		default:
+			s.Source = SourceUnset
+		}
+
+		if f.secret && !reflect.ValueOf(s.Value).IsZero() {
+			s.Value = redacted
+		} else if d, ok := s.Value.(time.Duration); ok {
+			s.Value = d.String()
+		}
+
+		r.Settings = append(r.Settings, s)
+	}
+
+	return &r, nil
+}
+
+type field struct {
+	key    string
+	index  []int
+	secret bool
+}
+
+// fields returns the leaf fields of the t struct type, with their dotted
+// configuration keys.
+func fields(t reflect.Type, prefix string, index []int) []field {
+	var res []field
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		key := sf.Name
+		if prefix != "" {
+			key = prefix + "." + sf.Name
+		}
+		idx := append(append([]int{}, index...), i)
+
+		if sf.Type.Kind() == reflect.Struct {
+			res = append(res, fields(sf.Type, key, idx)...)
+			continue
+		}
+
+		res = append(res, field{key: key, index: idx, secret: sf.Tag.Get("secret") == "true"})
+	}
+
+	return res
+}
+
+// unknownKeys returns the keys loaded in v that don't match a configuration
+// field. Only the configuration file can have unknown keys, the environment
+// variables are looked up by key.
+func unknownKeys(v *viper.Viper) []string {
+	known := map[string]bool{}
+	for _, f := range fields(reflect.TypeOf(Configuration{}), "", nil) {
+		known[strings.ToLower(f.key)] = true
+	}
+
+	var keys []string
+	for _, k := range v.AllKeys() {
+		if !known[k] {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+func envName(key string) string {
+	return envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
@@ -0,0 +1,232 @@
+// Package fsutil provides the filesystem operations of the preprocessing
+// activities that aren't provided by the standard library.
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// CopyOptions configures CopyTree.
+type CopyOptions struct {
+	// Hardlinks links the regular files that can't be reflinked instead of
+	// copying them. The links share their content and metadata with the
+	// source files, so the copy must never be modified in place.
+	Hardlinks bool
+
+	// Progress is called after each entry is copied with its path relative to
+	// the source directory, copying stops if it returns an error (optional).
+	Progress func(rel string) error
+}
+
+// CopyTree copies the src directory tree to dst, which must not exist, and
+// returns the number of regular files copied.
+//
+// The regular files are reflinked (i.e. they share their data blocks with the
+// source files until they are modified) when the filesystem supports it,
+// otherwise they are hard linked or copied according to opts. The permissions
+// and modification times of the files and directories are preserved. Symbolic
+// links are copied as is, and any other file type returns an error.
+func CopyTree(src, dst string, opts CopyOptions) (int, error) {
+	c := &copier{opts: opts, reflink: true}
+
+	type dir struct {
+		path    string
+		mode    fs.FileMode
+		modTime time.Time
+	}
+	var dirs []dir
+
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case fi.IsDir():
+			// The directories are writable until their content is copied.
+			if err := os.Mkdir(target, 0o700); err != nil {
+				return err
+			}
+			dirs = append(dirs, dir{path: target, mode: fi.Mode().Perm(), modTime: fi.ModTime()})
+		case fi.Mode().IsRegular():
+			if err := c.copyFile(path, target, fi); err != nil {
+				return err
+			}
+			c.count++
+		case fi.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("copy %s: unsupported file type %s", path, fi.Mode().Type())
+		}
+
+		if opts.Progress != nil && rel != "." {
+			return opts.Progress(rel)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return c.count, err
+	}
+
+	// Set the directories metadata once their content is copied, starting
+	// with the deepest ones.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		if err := os.Chmod(d.path, d.mode); err != nil {
+			return c.count, err
+		}
+		if err := os.Chtimes(d.path, d.modTime, d.modTime); err != nil {
+			return c.count, err
+		}
+	}
+
+	return c.count, nil
+}
+
+type copier struct {
+	opts  CopyOptions
+	count int
+
+	// reflink is disabled after the first file that can't be reflinked, the
+	// rest of the tree is in the same filesystem.
+	reflink bool
+}
+
+func (c *copier) copyFile(src, dst string, fi fs.FileInfo) error {
+	if !c.reflink && c.opts.Hardlinks {
+		return os.Link(src, dst)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if c.reflink {
+		err = reflink(out, in)
+		if errors.Is(err, errors.ErrUnsupported) {
+			c.reflink = false
+			if c.opts.Hardlinks {
+				out.Close()
+				if err := os.Remove(dst); err != nil {
+					return err
+				}
+				return os.Link(src, dst)
+			}
+		} else if err != nil {
+			out.Close()
+			return fmt.Errorf("reflink %s: %w", src, err)
+		}
+	}
+	if !c.reflink {
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return fmt.Errorf("copy %s: %w", src, err)
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	// The permissions aren't affected by the umask when they are set
+	// explicitly.
+	if err := os.Chmod(dst, fi.Mode().Perm()); err != nil {
+		return err
+	}
+
+	return os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+}
+
+// Exchange atomically exchanges the a and b paths, which must be in the same
+// filesystem. It returns an error wrapping errors.ErrUnsupported if the
+// platform or the filesystem can't exchange paths atomically.
+func Exchange(a, b string) error {
+	if err := exchange(a, b); err != nil {
+		return &os.LinkError{Op: "exchange", Old: a, New: b, Err: err}
+	}
+
+	return nil
+}
+
+// SameFilesystem reports whether the a and b paths are in the same
+// filesystem, so they can be renamed or linked into each other. It reports
+// true when the platform doesn't provide the filesystem of a path.
+func SameFilesystem(a, b string) (bool, error) {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+
+	ad, aok := device(ai)
+	bd, bok := device(bi)
+	if !aok || !bok {
+		return true, nil
+	}
+
+	return ad == bd, nil
+}
+
+// Xattr is an extended attribute of a file.
+type Xattr struct {
+	Name  string
+	Value []byte
+}
+
+// Xattrs returns the extended attributes of the user namespace of path (e.g.
+// "user.com.apple.metadata:_kMDItemUserTags"), sorted by name, without
+// following symbolic links. It returns no attributes if the platform or the
+// filesystem doesn't support them.
+func Xattrs(path string) ([]Xattr, error) {
+	attrs, err := xattrs(path)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &os.PathError{Op: "xattrs", Path: path, Err: err}
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Name < attrs[j].Name })
+
+	return attrs, nil
+}
+
+// Times returns the access and status change times of the fi file, or false
+// if the platform doesn't provide them.
+func Times(fi fs.FileInfo) (atime, ctime time.Time, ok bool) {
+	return times(fi)
+}
@@ -0,0 +1,115 @@
+package fsutil
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func reflink(dst, src *os.File) error {
+	err := unix.IoctlFileClone(int(dst.Fd()), int(src.Fd()))
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, unix.EOPNOTSUPP),
+		errors.Is(err, unix.ENOTTY),
+		errors.Is(err, unix.EXDEV),
+		errors.Is(err, unix.EINVAL),
+		errors.Is(err, unix.ENOSYS):
+		return errors.ErrUnsupported
+	default:
+		return err
+	}
+}
+
+func exchange(a, b string) error {
+	err := unix.Renameat2(unix.AT_FDCWD, a, unix.AT_FDCWD, b, unix.RENAME_EXCHANGE)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, unix.EINVAL), errors.Is(err, unix.ENOSYS), errors.Is(err, unix.EOPNOTSUPP):
+		// EINVAL is returned by the filesystems not supporting
+		// RENAME_EXCHANGE, e.g. NFS.
+		return errors.ErrUnsupported
+	default:
+		return err
+	}
+}
+
+func device(fi fs.FileInfo) (uint64, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+
+	// Dev is uint32 on some architectures.
+	return uint64(st.Dev), true
+}
+
+func times(fi fs.FileInfo) (time.Time, time.Time, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+
+	return time.Unix(st.Atim.Unix()), time.Unix(st.Ctim.Unix()), true
+}
+
+func xattrs(path string) ([]Xattr, error) {
+	names, err := xattrRead(func(b []byte) (int, error) { return unix.Llistxattr(path, b) })
+	if err != nil {
+		return nil, xattrError(err)
+	}
+
+	var attrs []Xattr
+	for _, name := range strings.Split(string(names), "\x00") {
+		if !strings.HasPrefix(name, "user.") {
+			continue
+		}
+		value, err := xattrRead(func(b []byte) (int, error) { return unix.Lgetxattr(path, name, b) })
+		if errors.Is(err, unix.ENODATA) {
+			// Removed since it was listed.
+			continue
+		}
+		if err != nil {
+			return nil, xattrError(err)
+		}
+		attrs = append(attrs, Xattr{Name: name, Value: value})
+	}
+
+	return attrs, nil
+}
+
+// xattrRead calls read with a buffer of the size it returns for a nil buffer,
+// again if the size changed in between.
+func xattrRead(read func([]byte) (int, error)) ([]byte, error) {
+	for {
+		n, err := read(nil)
+		if err != nil || n == 0 {
+			return nil, err
+		}
+		b := make([]byte, n)
+		n, err = read(b)
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return b[:n], nil
+	}
+}
+
+func xattrError(err error) error {
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.ENOSYS) {
+		return errors.ErrUnsupported
+	}
+
+	return err
+}
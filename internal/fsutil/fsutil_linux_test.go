@@ -0,0 +1,39 @@
+package fsutil_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"golang.org/x/sys/unix"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/fsutil"
+)
+
+func TestXattrs(t *testing.T) {
+	t.Parallel()
+
+	dir := fs.NewDir(t, "", fs.WithFile("a.tif", ""), fs.WithFile("b.tif", ""))
+	err := unix.Setxattr(dir.Join("a.tif"), "user.com.apple.metadata:kMDItemFinderComment", []byte("Comment"), 0)
+	if errors.Is(err, unix.ENOTSUP) {
+		t.Skip("The filesystem doesn't support extended attributes.")
+	}
+	assert.NilError(t, err)
+	assert.NilError(t, unix.Setxattr(dir.Join("a.tif"), "user.com.apple.FinderInfo", []byte{0, 1}, 0))
+
+	attrs, err := fsutil.Xattrs(dir.Join("a.tif"))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, attrs, []fsutil.Xattr{
+		{Name: "user.com.apple.FinderInfo", Value: []byte{0, 1}},
+		{Name: "user.com.apple.metadata:kMDItemFinderComment", Value: []byte("Comment")},
+	})
+
+	attrs, err = fsutil.Xattrs(dir.Join("b.tif"))
+	assert.NilError(t, err)
+	assert.Equal(t, len(attrs), 0)
+
+	_, err = fsutil.Xattrs(dir.Join("missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
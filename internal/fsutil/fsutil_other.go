@@ -0,0 +1,30 @@
+//go:build !linux
+
+package fsutil
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+)
+
+func reflink(dst, src *os.File) error {
+	return errors.ErrUnsupported
+}
+
+func exchange(a, b string) error {
+	return errors.ErrUnsupported
+}
+
+func device(fi fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+func xattrs(path string) ([]Xattr, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func times(fi fs.FileInfo) (time.Time, time.Time, bool) {
+	return time.Time{}, time.Time{}, false
+}
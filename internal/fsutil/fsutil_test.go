@@ -0,0 +1,122 @@
+package fsutil_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/fsutil"
+)
+
+func TestCopyTree(t *testing.T) {
+	t.Parallel()
+
+	modTime := time.Date(2019, 5, 1, 10, 30, 0, 0, time.UTC)
+	src := fs.NewDir(t, "",
+		fs.WithFile("a.txt", "A", fs.WithMode(0o640), fs.WithTimestamps(modTime, modTime)),
+		fs.WithDir("sub",
+			fs.WithFile("b.txt", "BB", fs.WithMode(0o600)),
+			fs.WithMode(0o750),
+		),
+	)
+	assert.NilError(t, os.Symlink("b.txt", src.Join("sub", "link")))
+	assert.NilError(t, os.Chtimes(src.Join("sub"), modTime, modTime))
+	dst := filepath.Join(t.TempDir(), "copy")
+
+	for _, hardlinks := range []bool{false, true} {
+		t.Run(map[bool]string{false: "Copies", true: "Links"}[hardlinks], func(t *testing.T) {
+			t.Parallel()
+
+			dst := dst + map[bool]string{false: "-copy", true: "-link"}[hardlinks]
+			var copied []string
+			n, err := fsutil.CopyTree(src.Path(), dst, fsutil.CopyOptions{
+				Hardlinks: hardlinks,
+				Progress: func(rel string) error {
+					copied = append(copied, rel)
+					return nil
+				},
+			})
+			assert.NilError(t, err)
+			assert.Equal(t, n, 2)
+			assert.DeepEqual(t, copied, []string{"a.txt", "sub", "sub/b.txt", "sub/link"})
+			assert.Assert(t, fs.Equal(dst, fs.Expected(t,
+				fs.WithFile("a.txt", "A", fs.WithMode(0o640)),
+				fs.WithDir("sub",
+					fs.WithFile("b.txt", "BB", fs.WithMode(0o600)),
+					fs.WithSymlink("link", "b.txt"),
+					fs.WithMode(0o750),
+				),
+				fs.MatchAnyFileMode,
+			)))
+
+			for _, name := range []string{"a.txt", "sub"} {
+				fi, err := os.Stat(filepath.Join(dst, name))
+				assert.NilError(t, err)
+				assert.Assert(t, fi.ModTime().Equal(modTime), "%s: %s", name, fi.ModTime())
+			}
+		})
+	}
+}
+
+func TestCopyTreeStops(t *testing.T) {
+	t.Parallel()
+
+	src := fs.NewDir(t, "", fs.WithFile("a.txt", "A"), fs.WithFile("b.txt", "B"))
+	stop := errors.New("stop")
+
+	n, err := fsutil.CopyTree(src.Path(), filepath.Join(t.TempDir(), "copy"), fsutil.CopyOptions{
+		Progress: func(rel string) error { return stop },
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, n, 1)
+}
+
+func TestCopyTreeExistingDestination(t *testing.T) {
+	t.Parallel()
+
+	src := fs.NewDir(t, "", fs.WithFile("a.txt", "A"))
+
+	_, err := fsutil.CopyTree(src.Path(), t.TempDir(), fsutil.CopyOptions{})
+	assert.ErrorIs(t, err, os.ErrExist)
+}
+
+func TestExchange(t *testing.T) {
+	t.Parallel()
+
+	dir := fs.NewDir(t, "",
+		fs.WithDir("a", fs.WithFile("a.txt", "A")),
+		fs.WithDir("b", fs.WithFile("b.txt", "B")),
+	)
+
+	err := fsutil.Exchange(dir.Join("a"), dir.Join("b"))
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("The filesystem can't exchange paths.")
+	}
+	assert.NilError(t, err)
+	assert.Assert(t, fs.Equal(dir.Path(), fs.Expected(t,
+		fs.WithDir("a", fs.WithFile("b.txt", "B")),
+		fs.WithDir("b", fs.WithFile("a.txt", "A")),
+		fs.MatchAnyFileMode,
+	)))
+
+	err = fsutil.Exchange(dir.Join("a"), dir.Join("missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestSameFilesystem(t *testing.T) {
+	t.Parallel()
+
+	dir := fs.NewDir(t, "", fs.WithDir("a"), fs.WithDir("b"))
+
+	same, err := fsutil.SameFilesystem(dir.Join("a"), dir.Join("b"))
+	assert.NilError(t, err)
+	assert.Assert(t, same)
+
+	_, err = fsutil.SameFilesystem(dir.Join("a"), dir.Join("missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
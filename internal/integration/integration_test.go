@@ -2,6 +2,8 @@ package integration_test
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -13,19 +15,41 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/go-logr/logr/testr"
 	cp "github.com/otiai10/copy"
+	temporalapi_enums "go.temporal.io/api/enums/v1"
+	temporalapi_history "go.temporal.io/api/history/v1"
+	"go.temporal.io/api/temporalproto"
 	temporalsdk_client "go.temporal.io/sdk/client"
+	temporalsdk_converter "go.temporal.io/sdk/converter"
 	temporalsdk_testsuite "go.temporal.io/sdk/testsuite"
 	"gotest.tools/v3/assert"
 	tfs "gotest.tools/v3/fs"
 
+	"github.com/artefactual-sdps/preprocessing-moma/cmd/worker/bootstrapcmd"
 	"github.com/artefactual-sdps/preprocessing-moma/cmd/worker/workercmd"
 	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/report"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/results"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/validation"
 	"github.com/artefactual-sdps/preprocessing-moma/internal/workflow"
 )
 
 const (
 	dirMode  fs.FileMode = 0o700
 	fileMode fs.FileMode = 0o600
+
+	// historiesDir is the directory of the workflow histories replayed by the
+	// workflow package tests.
+	historiesDir = "../workflow/testdata/histories"
+
+	// historyMode is the mode of the recorded histories, which are checked in
+	// like the other source files.
+	historyMode fs.FileMode = 0o644
+)
+
+var recordHistories = flag.Bool(
+	"record-histories",
+	false,
+	"Record the workflow histories in "+historiesDir,
 )
 
 type temporalInstance struct {
@@ -51,6 +75,9 @@ func setUpTemporal(ctx context.Context, t *testing.T) *temporalInstance {
 		c.Close()
 	})
 
+	_, err = bootstrapcmd.RegisterSearchAttributes(ctx, c, "default")
+	assert.NilError(t, err, "Failed to register search attributes.")
+
 	return &temporalInstance{
 		client: c,
 		addr:   s.FrontendHostPort(),
@@ -69,6 +96,9 @@ func defaultConfig() config.Configuration {
 			TaskQueue:    "preprocessing",
 			WorkflowName: "preprocessing",
 		},
+		UnwantedFiles: config.UnwantedFilesConfig{
+			Names: []string{".DS_Store"},
+		},
 	}
 }
 
@@ -157,6 +187,60 @@ func (env *testEnv) copyTestTransfer(name string) {
 	})
 }
 
+// recordHistory writes the history of the run workflow execution to the
+// historiesDir when the -record-histories flag is set. The file is named after
+// name and the workflow change versions, so the histories of the previous
+// workflow versions are kept.
+func (env *testEnv) recordHistory(ctx context.Context, c temporalsdk_client.Client, run temporalsdk_client.WorkflowRun, name string) {
+	env.t.Helper()
+
+	if !*recordHistories {
+		return
+	}
+
+	history := &temporalapi_history.History{}
+	iter := c.GetWorkflowHistory(ctx, run.GetID(), run.GetRunID(), false, temporalapi_enums.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		assert.NilError(env.t, err, "Workflow history could not be read.")
+		history.Events = append(history.Events, event)
+	}
+
+	b, err := temporalproto.CustomJSONMarshalOptions{Indent: "  "}.Marshal(history)
+	assert.NilError(env.t, err)
+
+	path := filepath.Join(historiesDir, historyFilename(env.t, name, history))
+	err = os.WriteFile(path, append(b, '\n'), historyMode)
+	assert.NilError(env.t, err)
+	env.t.Logf("Workflow history recorded in %s.", path)
+}
+
+// historyFilename returns the file name of a workflow history, e.g.
+// "remove-ds-store_config-1.json".
+func historyFilename(t *testing.T, name string, history *temporalapi_history.History) string {
+	t.Helper()
+
+	var versions []string
+	for _, event := range history.Events {
+		attrs := event.GetUpsertWorkflowSearchAttributesEventAttributes()
+		if attrs == nil {
+			continue
+		}
+		p, ok := attrs.GetSearchAttributes().GetIndexedFields()["TemporalChangeVersion"]
+		if !ok {
+			continue
+		}
+		var vs []string
+		err := temporalsdk_converter.GetDefaultDataConverter().FromPayload(p, &vs)
+		assert.NilError(t, err)
+		versions = append(versions, vs...)
+	}
+	slices.Sort(versions)
+	versions = slices.Compact(versions)
+
+	return fmt.Sprintf("%s.json", strings.Join(append([]string{name}, versions...), "_"))
+}
+
 func TestIntegration(t *testing.T) {
 	truthy := []string{"1", "t", "true"}
 	v := strings.ToLower(os.Getenv("ENDURO_PP_INTEGRATION_TEST"))
@@ -184,7 +268,7 @@ func TestIntegration(t *testing.T) {
 				TaskQueue:                env.cfg.Temporal.TaskQueue,
 				WorkflowExecutionTimeout: 30 * time.Second,
 			},
-			workflow.NewPreprocessingWorkflow(env.testDir.Path()).Execute,
+			env.cfg.Temporal.WorkflowName,
 			&workflow.PreprocessingWorkflowParams{
 				RelativePath: testTransfer,
 			},
@@ -193,14 +277,129 @@ func TestIntegration(t *testing.T) {
 
 		var result workflow.PreprocessingWorkflowResult
 		run.Get(ctx, &result)
-
-		assert.Equal(t, result, workflow.PreprocessingWorkflowResult{
-			RelativePath: testTransfer,
+		env.recordHistory(ctx, temporalServer.client, run, "remove-ds-store")
+
+		// The removed files and the inventory are written to the results
+		// directory and referenced by the result.
+		store := results.NewStore(env.cfg.ResultsDir())
+		var removed []string
+		assert.NilError(t, store.Load(result.Removed, &removed))
+		assert.DeepEqual(t, removed, []string{".DS_Store"})
+		var inventory []report.File
+		assert.NilError(t, store.Load(result.Inventory, &inventory))
+		assert.Equal(t, len(inventory), 1)
+		assert.Equal(t, inventory[0].Path, "small.txt")
+
+		result.Removed, result.Inventory = nil, nil
+		assert.DeepEqual(t, result, workflow.PreprocessingWorkflowResult{
+			RelativePath:  testTransfer,
+			ConfigVersion: env.cfg.Reloadable().Version(),
+			FileCount:     1,
+			TotalBytes:    19,
+			Findings: validation.Findings{{
+				Severity: validation.Info,
+				Code:     "unwanted-files-removed",
+				Message:  "1 unwanted files removed.",
+			}},
 		})
+
+		// The trash is opt-in, the removed files are deleted without the
+		// journal.
+		assert.Assert(t, tfs.Equal(
+			env.testDir.Path(),
+			tfs.Expected(t,
+				tfs.WithDir(".results", tfs.WithMode(dirMode), tfs.MatchExtraFiles),
+				tfs.WithDir(testTransfer, tfs.WithMode(dirMode),
+					tfs.WithFile(
+						"small.txt", "I am a small file.\n", tfs.WithMode(fileMode),
+					),
+					tfs.WithDir("metadata", tfs.WithMode(dirMode),
+						tfs.WithDir("submissionDocumentation", tfs.WithMode(dirMode),
+							tfs.WithFile(
+								"preprocessing-report.html", "",
+								tfs.MatchAnyFileContent, tfs.WithMode(fileMode),
+							),
+						),
+					),
+				),
+			),
+		))
+	})
+
+	t.Run("Wait for the review of the SIP", func(t *testing.T) {
+		testTransfer := "small_with_ds_store"
+
+		env := newTestEnv(t, defaultConfig())
+		env.cfg.Temporal.Address = temporalServer.addr
+		env.copyTestTransfer(testTransfer)
+		env.startWorker(ctx)
+
+		run, err := temporalServer.client.ExecuteWorkflow(
+			ctx,
+			temporalsdk_client.StartWorkflowOptions{
+				TaskQueue:                env.cfg.Temporal.TaskQueue,
+				WorkflowExecutionTimeout: 30 * time.Second,
+			},
+			env.cfg.Temporal.WorkflowName,
+			&workflow.PreprocessingWorkflowParams{
+				RelativePath: testTransfer,
+				Review:       true,
+			},
+		)
+		assert.NilError(t, err, "Workflow could not be started.")
+
+		err = temporalServer.client.SignalWorkflow(
+			ctx, run.GetID(), run.GetRunID(), workflow.ReviewSignal,
+			&workflow.ReviewDecision{Approved: true, Reviewer: "registrar"},
+		)
+		assert.NilError(t, err, "Review could not be signaled.")
+
+		var result workflow.PreprocessingWorkflowResult
+		err = run.Get(ctx, &result)
+		assert.NilError(t, err)
+		env.recordHistory(ctx, temporalServer.client, run, "review")
+
+		assert.Assert(t, result.Review != nil)
+		assert.Equal(t, result.Review.Status, workflow.ReviewApproved)
+		assert.Equal(t, result.Review.Reviewer, "registrar")
+	})
+
+	t.Run("Leave the transfer untouched when staging fails", func(t *testing.T) {
+		testTransfer := "small_with_ds_store"
+
+		// The unwanted files finding fails the workflow after the staged copy
+		// is processed.
+		cfg := defaultConfig()
+		cfg.Staging.Enabled = true
+		cfg.Validation.BlockingSeverity = "info"
+
+		env := newTestEnv(t, cfg)
+		env.cfg.Temporal.Address = temporalServer.addr
+		env.copyTestTransfer(testTransfer)
+		env.startWorker(ctx)
+
+		run, err := temporalServer.client.ExecuteWorkflow(
+			ctx,
+			temporalsdk_client.StartWorkflowOptions{
+				TaskQueue:                env.cfg.Temporal.TaskQueue,
+				WorkflowExecutionTimeout: 30 * time.Second,
+			},
+			env.cfg.Temporal.WorkflowName,
+			&workflow.PreprocessingWorkflowParams{
+				RelativePath: testTransfer,
+			},
+		)
+		assert.NilError(t, err, "Workflow could not be started.")
+
+		err = run.Get(ctx, nil)
+		assert.ErrorContains(t, err, "validation failed")
 		assert.Assert(t, tfs.Equal(
 			env.testDir.Path(),
 			tfs.Expected(t,
+				tfs.WithDir(".results", tfs.WithMode(dirMode), tfs.MatchExtraFiles),
+				tfs.WithDir(".staging", tfs.WithMode(dirMode)),
 				tfs.WithDir(testTransfer, tfs.WithMode(dirMode),
+					tfs.WithFile(".DS_Store", "", tfs.WithMode(fileMode)),
 					tfs.WithFile(
 						"small.txt", "I am a small file.\n", tfs.WithMode(fileMode),
 					),
@@ -0,0 +1,530 @@
+// Package journal records the filesystem mutations made while processing a
+// SIP, so they can be rolled back to restore the original tree.
+//
+// Each mutation is appended to the journal before it's made: a removed path is
+// moved to the trash directory of the journal, a created path is recorded so
+// it can be removed and a renamed path so it can be renamed back. A Rollback
+// undoes the mutations in reverse order, skipping the ones that weren't made.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mutation operations.
+const (
+	// OpRemove is the removal of Path, moved to the Target trash path.
+	OpRemove = "remove"
+
+	// OpCreate is the creation of the Path file or directory.
+	OpCreate = "create"
+
+	// OpRename is the rename of Path to Target.
+	OpRename = "rename"
+)
+
+const (
+	// FileName is the name of the journal file, in the journal directory.
+	FileName = "journal.jsonl"
+
+	// TrashDir is the directory of the removed paths, in the journal
+	// directory.
+	TrashDir = "trash"
+)
+
+// Entry is a mutation recorded in a journal.
+type Entry struct {
+	Op string
+
+	// Path is the absolute path mutated.
+	Path string
+
+	// Target is the trash path of a removed Path, or the new path of a renamed
+	// Path.
+	Target string `json:",omitempty"`
+
+	Time time.Time
+}
+
+// Store is the directory of the journals of the workflow runs, e.g.
+// "<dir>/<workflow ID>/<run ID>/journal.jsonl". Each journal is the manifest of
+// the trash of its workflow run.
+type Store struct {
+	dir string
+}
+
+// NewStore returns a Store of the journals in dir, which must be in the
+// filesystem of the journaled paths so they can be moved to the trash.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir returns the Store directory.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Run describes the journal of a workflow run.
+type Run struct {
+	WorkflowID string
+	RunID      string
+
+	// ModTime is the time the last entry was recorded.
+	ModTime time.Time
+}
+
+func (s *Store) runDir(workflowID, runID string) string {
+	return filepath.Join(s.dir, url.PathEscape(workflowID), url.PathEscape(runID))
+}
+
+// Open opens the journal of the workflow run and loads its entries. The
+// journal directory is created when the first entry is recorded.
+func (s *Store) Open(workflowID, runID string) (*Journal, error) {
+	if workflowID == "" || runID == "" {
+		return nil, errors.New("journal: missing workflow or run ID")
+	}
+
+	j := &Journal{
+		dir:     s.runDir(workflowID, runID),
+		created: map[string]bool{},
+	}
+	if err := j.load(); err != nil {
+		return nil, fmt.Errorf("journal: %w", err)
+	}
+
+	return j, nil
+}
+
+// Runs returns the journals of the Store, sorted by ModTime.
+func (s *Store) Runs() ([]Run, error) {
+	wfs, err := os.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("journal: %w", err)
+	}
+
+	var runs []Run
+	for _, wf := range wfs {
+		if !wf.IsDir() {
+			continue
+		}
+		workflowID, err := url.PathUnescape(wf.Name())
+		if err != nil {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(s.dir, wf.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("journal: %w", err)
+		}
+		for _, e := range entries {
+			runID, err := url.PathUnescape(e.Name())
+			if err != nil || !e.IsDir() {
+				continue
+			}
+			fi, err := os.Stat(filepath.Join(s.dir, wf.Name(), e.Name(), FileName))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("journal: %w", err)
+			}
+			runs = append(runs, Run{WorkflowID: workflowID, RunID: runID, ModTime: fi.ModTime()})
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].ModTime.Before(runs[j].ModTime) })
+
+	return runs, nil
+}
+
+// Purge deletes the journals, with their trash, whose last entry was recorded
+// before t, and returns them. The journals of the workflow runs reported as
+// active by active, if it isn't nil, are kept: they are still needed to roll
+// back the mutations of a failed run.
+func (s *Store) Purge(t time.Time, active func(Run) (bool, error)) ([]Run, error) {
+	runs, err := s.Runs()
+	if err != nil {
+		return nil, err
+	}
+
+	var purged []Run
+	for _, r := range runs {
+		if !r.ModTime.Before(t) {
+			continue
+		}
+		if active != nil {
+			ok, err := active(r)
+			if err != nil {
+				return purged, fmt.Errorf("journal: %w", err)
+			}
+			if ok {
+				continue
+			}
+		}
+		dir := s.runDir(r.WorkflowID, r.RunID)
+		if err := os.RemoveAll(dir); err != nil {
+			return purged, fmt.Errorf("journal: %w", err)
+		}
+		// Remove the workflow directory without other runs.
+		_ = os.Remove(filepath.Dir(dir))
+		purged = append(purged, r)
+	}
+
+	return purged, nil
+}
+
+// Journal records the mutations of a tree. The mutation methods of a nil
+// Journal make the mutations without recording them.
+type Journal struct {
+	dir     string
+	entries []Entry
+
+	// created indexes the paths created by the journaled mutations, they are
+	// removed or overwritten without moving them to the trash.
+	created map[string]bool
+}
+
+// Dir returns the journal directory.
+func (j *Journal) Dir() string {
+	return j.dir
+}
+
+// Entries returns the entries of the journal, in the order they were
+// recorded.
+func (j *Journal) Entries() []Entry {
+	return j.entries
+}
+
+func (j *Journal) load() error {
+	f, err := os.Open(filepath.Join(j.dir, FileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var e Entry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			// The last entry is incomplete if the process was killed while
+			// appending it, its mutation wasn't made.
+			break
+		}
+		j.index(e)
+	}
+
+	return s.Err()
+}
+
+func (j *Journal) index(e Entry) {
+	j.entries = append(j.entries, e)
+	switch e.Op {
+	case OpCreate:
+		j.created[e.Path] = true
+	case OpRename:
+		if j.created[e.Path] {
+			delete(j.created, e.Path)
+			j.created[e.Target] = true
+		}
+	}
+}
+
+// record appends e to the journal file, and syncs it before the mutation is
+// made.
+func (j *Journal) record(e Entry) error {
+	e.Time = time.Now().UTC()
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(j.dir, 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(j.dir, FileName), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	j.index(e)
+
+	return nil
+}
+
+// Remove moves path into the trash, or removes it if it was created by a
+// journaled mutation.
+func (j *Journal) Remove(path string) error {
+	if j == nil || j.created[path] {
+		return os.RemoveAll(path)
+	}
+
+	trash := j.trashPath(len(j.entries), path)
+	if err := j.record(Entry{Op: OpRemove, Path: path, Target: trash}); err != nil {
+		return fmt.Errorf("journal: remove %s: %w", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(trash), 0o700); err != nil {
+		return fmt.Errorf("journal: remove %s: %w", path, err)
+	}
+	if err := os.Rename(path, trash); err != nil {
+		return fmt.Errorf("journal: remove %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Create records the creation of path, moving it into the trash first if it
+// exists and it wasn't created by a journaled mutation. The caller creates
+// path.
+func (j *Journal) Create(path string) error {
+	if j == nil || j.created[path] {
+		return nil
+	}
+
+	found, err := exists(path)
+	if err != nil {
+		return fmt.Errorf("journal: create %s: %w", path, err)
+	}
+	if found {
+		if err := j.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	if err := j.record(Entry{Op: OpCreate, Path: path}); err != nil {
+		return fmt.Errorf("journal: create %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// MkdirAll creates the path directory and its missing parents like
+// os.MkdirAll, recording the creation of the first missing directory.
+func (j *Journal) MkdirAll(path string, perm fs.FileMode) error {
+	if j != nil {
+		var missing string
+		for p := filepath.Clean(path); ; p = filepath.Dir(p) {
+			found, err := exists(p)
+			if err != nil {
+				return fmt.Errorf("journal: create %s: %w", path, err)
+			}
+			if found || filepath.Dir(p) == p {
+				break
+			}
+			missing = p
+		}
+		if missing != "" {
+			if err := j.Create(missing); err != nil {
+				return err
+			}
+		}
+	}
+
+	return os.MkdirAll(path, perm)
+}
+
+// WriteFile writes the path file like os.WriteFile, recording its creation.
+func (j *Journal) WriteFile(path string, data []byte, perm fs.FileMode) error {
+	if err := j.Create(path); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, perm)
+}
+
+// Rename renames oldpath to newpath like os.Rename, moving newpath into the
+// trash first if it exists and it wasn't created by a journaled mutation.
+func (j *Journal) Rename(oldpath, newpath string) error {
+	if j == nil {
+		return os.Rename(oldpath, newpath)
+	}
+
+	if !j.created[newpath] {
+		found, err := exists(newpath)
+		if err != nil {
+			return fmt.Errorf("journal: rename %s: %w", oldpath, err)
+		}
+		if found {
+			if err := j.Remove(newpath); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := j.record(Entry{Op: OpRename, Path: oldpath, Target: newpath}); err != nil {
+		return fmt.Errorf("journal: rename %s: %w", oldpath, err)
+	}
+
+	return os.Rename(oldpath, newpath)
+}
+
+// Rollback undoes the journaled mutations in reverse order, and returns the
+// number of mutations undone. The mutations that weren't made (or were already
+// undone) are skipped, so a failed Rollback can be retried.
+func (j *Journal) Rollback() (int, error) {
+	var n int
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		undone, err := j.undo(i)
+		if err != nil {
+			return n, fmt.Errorf("journal: rollback %s %s: %w", j.entries[i].Op, j.entries[i].Path, err)
+		}
+		if undone {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// Trashed returns the entries of the removed paths still in the trash, in the
+// order they were removed.
+func (j *Journal) Trashed() ([]Entry, error) {
+	var trashed []Entry
+	for _, e := range j.entries {
+		if e.Op != OpRemove {
+			continue
+		}
+		found, err := exists(e.Target)
+		if err != nil {
+			return nil, fmt.Errorf("journal: %w", err)
+		}
+		if found {
+			trashed = append(trashed, e)
+		}
+	}
+
+	return trashed, nil
+}
+
+// Restore moves the removed paths back from the trash in reverse order, and
+// returns the number of paths restored. If paths isn't empty, only the removed
+// paths equal to or in one of paths are restored. The other mutations aren't
+// undone.
+func (j *Journal) Restore(paths ...string) (int, error) {
+	var n int
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		e := j.entries[i]
+		if e.Op != OpRemove || !matches(e.Path, paths) {
+			continue
+		}
+		restored, err := j.undo(i)
+		if err != nil {
+			return n, fmt.Errorf("journal: restore %s: %w", e.Path, err)
+		}
+		if restored {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// matches reports whether path is equal to or in one of paths, or if paths is
+// empty.
+func matches(path string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	for _, p := range paths {
+		p = filepath.Clean(p)
+		if path == p || strings.HasPrefix(path, p+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Delete deletes the journal directory, with its trash.
+func (j *Journal) Delete() error {
+	if err := os.RemoveAll(j.dir); err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	j.entries = nil
+	j.created = map[string]bool{}
+
+	return nil
+}
+
+// trashPath returns the trash path of path for the i entry.
+func (j *Journal) trashPath(i int, path string) string {
+	return filepath.Join(j.dir, TrashDir, strconv.Itoa(i), filepath.Base(path))
+}
+
+// undo undoes the i entry, and reports whether it was undone.
+func (j *Journal) undo(i int) (bool, error) {
+	e := j.entries[i]
+	switch e.Op {
+	case OpCreate:
+		// The created path is moved into the trash rather than removed: the
+		// trash path records that the creation was undone, so a retried
+		// Rollback doesn't remove the original path restored by a previous
+		// entry.
+		trash := j.trashPath(i, e.Path)
+		if found, err := exists(trash); err != nil || found {
+			return false, err
+		}
+		found, err := exists(e.Path)
+		if err != nil || !found {
+			return false, err
+		}
+		if err := os.MkdirAll(filepath.Dir(trash), 0o700); err != nil {
+			return false, err
+		}
+		return true, os.Rename(e.Path, trash)
+	case OpRemove, OpRename:
+		found, err := exists(e.Target)
+		if err != nil || !found {
+			return false, err
+		}
+		// The path is restored even if its parent directory was removed
+		// from the original tree after it was journaled, by a mutation that
+		// wasn't journaled.
+		if err := os.MkdirAll(filepath.Dir(e.Path), 0o700); err != nil {
+			return false, err
+		}
+		if found, err := exists(e.Path); err != nil {
+			return false, err
+		} else if found {
+			return false, fmt.Errorf("restore %s: %w", e.Target, fs.ErrExist)
+		}
+		return true, os.Rename(e.Target, e.Path)
+	default:
+		return false, fmt.Errorf("unknown operation %q", e.Op)
+	}
+}
+
+// exists reports whether path exists, without following symbolic links.
+func exists(path string) (bool, error) {
+	_, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
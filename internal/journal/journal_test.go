@@ -0,0 +1,286 @@
+package journal_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/journal"
+)
+
+func sipDir(t *testing.T) *fs.Dir {
+	t.Helper()
+
+	return fs.NewDir(t, "",
+		fs.WithFile("a.txt", "A"),
+		fs.WithFile("Thumbs.db", "thumbs"),
+		fs.WithDir("metadata",
+			fs.WithFile("metadata.csv", "old"),
+		),
+		fs.WithDir("sub",
+			fs.WithFile("b.txt", "B"),
+			fs.WithDir("__MACOSX",
+				fs.WithFile("._b.txt", "resource fork"),
+			),
+		),
+	)
+}
+
+func expectedSIP(t *testing.T) fs.Manifest {
+	t.Helper()
+
+	return fs.Expected(t,
+		fs.WithFile("a.txt", "A"),
+		fs.WithFile("Thumbs.db", "thumbs"),
+		fs.WithDir("metadata",
+			fs.WithFile("metadata.csv", "old"),
+		),
+		fs.WithDir("sub",
+			fs.WithFile("b.txt", "B"),
+			fs.WithDir("__MACOSX",
+				fs.WithFile("._b.txt", "resource fork"),
+			),
+		),
+		fs.MatchAnyFileMode,
+	)
+}
+
+// mutate applies the mutations of a processed SIP through j.
+func mutate(t *testing.T, j *journal.Journal, sip *fs.Dir) {
+	t.Helper()
+
+	assert.NilError(t, j.Remove(sip.Join("Thumbs.db")))
+	assert.NilError(t, j.Remove(sip.Join("sub", "__MACOSX")))
+	assert.NilError(t, j.WriteFile(sip.Join("metadata", "metadata.csv"), []byte("new"), 0o644))
+	assert.NilError(t, j.MkdirAll(sip.Join("metadata", "submissionDocumentation", "reports"), 0o755))
+	assert.NilError(t, j.WriteFile(sip.Join("metadata", "submissionDocumentation", "reports", "report.md"), []byte("R"), 0o644))
+	assert.NilError(t, j.Rename(sip.Join("a.txt"), sip.Join("sub", "a.txt")))
+}
+
+func TestJournal(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Records and rolls back the mutations", func(t *testing.T) {
+		t.Parallel()
+
+		sip := sipDir(t)
+		s := journal.NewStore(t.TempDir())
+		j, err := s.Open("wf", "run")
+		assert.NilError(t, err)
+
+		mutate(t, j, sip)
+		assert.Assert(t, fs.Equal(sip.Path(), fs.Expected(t,
+			fs.WithDir("metadata",
+				fs.WithFile("metadata.csv", "new"),
+				fs.WithDir("submissionDocumentation",
+					fs.WithDir("reports",
+						fs.WithFile("report.md", "R"),
+					),
+				),
+			),
+			fs.WithDir("sub",
+				fs.WithFile("a.txt", "A"),
+				fs.WithFile("b.txt", "B"),
+			),
+			fs.MatchAnyFileMode,
+		)))
+
+		var ops []string
+		for _, e := range j.Entries() {
+			ops = append(ops, e.Op+" "+e.Path)
+		}
+		assert.DeepEqual(t, ops, []string{
+			"remove " + sip.Join("Thumbs.db"),
+			"remove " + sip.Join("sub", "__MACOSX"),
+			"remove " + sip.Join("metadata", "metadata.csv"),
+			"create " + sip.Join("metadata", "metadata.csv"),
+			"create " + sip.Join("metadata", "submissionDocumentation"),
+			"create " + sip.Join("metadata", "submissionDocumentation", "reports", "report.md"),
+			"rename " + sip.Join("a.txt"),
+		})
+
+		// A reopened journal rolls back the mutations of the previous one.
+		j, err = s.Open("wf", "run")
+		assert.NilError(t, err)
+		n, err := j.Rollback()
+		assert.NilError(t, err)
+		assert.Equal(t, n, 7)
+		assert.Assert(t, fs.Equal(sip.Path(), expectedSIP(t)))
+
+		n, err = j.Rollback()
+		assert.NilError(t, err)
+		assert.Equal(t, n, 0)
+		assert.Assert(t, fs.Equal(sip.Path(), expectedSIP(t)))
+	})
+
+	t.Run("Removes the journaled creations without trashing them", func(t *testing.T) {
+		t.Parallel()
+
+		sip := sipDir(t)
+		j, err := journal.NewStore(t.TempDir()).Open("wf", "run")
+		assert.NilError(t, err)
+
+		path := sip.Join("new.txt")
+		assert.NilError(t, j.WriteFile(path, []byte("1"), 0o644))
+		assert.NilError(t, j.WriteFile(path, []byte("2"), 0o644))
+		assert.NilError(t, j.Remove(path))
+		assert.Equal(t, len(j.Entries()), 1)
+
+		_, err = j.Rollback()
+		assert.NilError(t, err)
+		assert.Assert(t, fs.Equal(sip.Path(), expectedSIP(t)))
+	})
+
+	t.Run("Makes the mutations without recording them when nil", func(t *testing.T) {
+		t.Parallel()
+
+		sip := sipDir(t)
+		var j *journal.Journal
+
+		assert.NilError(t, j.Remove(sip.Join("Thumbs.db")))
+		assert.NilError(t, j.MkdirAll(sip.Join("new"), 0o755))
+		assert.NilError(t, j.WriteFile(sip.Join("new", "c.txt"), []byte("C"), 0o644))
+		assert.NilError(t, j.Rename(sip.Join("a.txt"), sip.Join("new", "a.txt")))
+		assert.Assert(t, fs.Equal(sip.Path(), fs.Expected(t,
+			fs.WithDir("metadata",
+				fs.WithFile("metadata.csv", "old"),
+			),
+			fs.WithDir("new",
+				fs.WithFile("a.txt", "A"),
+				fs.WithFile("c.txt", "C"),
+			),
+			fs.WithDir("sub",
+				fs.WithFile("b.txt", "B"),
+				fs.WithDir("__MACOSX",
+					fs.WithFile("._b.txt", "resource fork"),
+				),
+			),
+			fs.MatchAnyFileMode,
+		)))
+	})
+
+	t.Run("Fails to restore a path replaced outside the journal", func(t *testing.T) {
+		t.Parallel()
+
+		sip := sipDir(t)
+		j, err := journal.NewStore(t.TempDir()).Open("wf", "run")
+		assert.NilError(t, err)
+
+		assert.NilError(t, j.Remove(sip.Join("a.txt")))
+		assert.NilError(t, os.WriteFile(sip.Join("a.txt"), []byte("other"), 0o644))
+
+		_, err = j.Rollback()
+		assert.ErrorContains(t, err, "journal: rollback remove "+sip.Join("a.txt")+": restore ")
+		assert.ErrorContains(t, err, "file already exists")
+	})
+
+	t.Run("Deletes the journal with its trash", func(t *testing.T) {
+		t.Parallel()
+
+		sip := sipDir(t)
+		j, err := journal.NewStore(t.TempDir()).Open("wf", "run")
+		assert.NilError(t, err)
+
+		assert.NilError(t, j.Remove(sip.Join("Thumbs.db")))
+		assert.NilError(t, j.Delete())
+		assert.Equal(t, len(j.Entries()), 0)
+
+		_, err = os.Stat(j.Dir())
+		assert.Assert(t, os.IsNotExist(err))
+	})
+
+	t.Run("Restores the trashed paths", func(t *testing.T) {
+		t.Parallel()
+
+		sip := sipDir(t)
+		j, err := journal.NewStore(t.TempDir()).Open("wf", "run")
+		assert.NilError(t, err)
+		mutate(t, j, sip)
+
+		trashed, err := j.Trashed()
+		assert.NilError(t, err)
+		assert.Equal(t, len(trashed), 3)
+		assert.Equal(t, trashed[0].Path, sip.Join("Thumbs.db"))
+
+		n, err := j.Restore(sip.Join("sub"))
+		assert.NilError(t, err)
+		assert.Equal(t, n, 1)
+		b, err := os.ReadFile(sip.Join("sub", "__MACOSX", "._b.txt"))
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), "resource fork")
+
+		trashed, err = j.Trashed()
+		assert.NilError(t, err)
+		assert.Equal(t, len(trashed), 2)
+
+		// The overwritten metadata.csv can't be restored over its new version.
+		_, err = j.Restore()
+		assert.ErrorContains(t, err, "journal: restore "+sip.Join("metadata", "metadata.csv"))
+
+		n, err = j.Restore(sip.Join("Thumbs.db"))
+		assert.NilError(t, err)
+		assert.Equal(t, n, 1)
+		b, err = os.ReadFile(sip.Join("Thumbs.db"))
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), "thumbs")
+	})
+
+	t.Run("Rejects a missing run ID", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := journal.NewStore(t.TempDir()).Open("wf", "")
+		assert.Error(t, err, "journal: missing workflow or run ID")
+	})
+}
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	sip := sipDir(t)
+	s := journal.NewStore(t.TempDir())
+
+	runs, err := s.Runs()
+	assert.NilError(t, err)
+	assert.Equal(t, len(runs), 0)
+
+	for _, id := range []string{"old", "new/1"} {
+		j, err := s.Open(id, "run")
+		assert.NilError(t, err)
+		assert.NilError(t, j.WriteFile(sip.Join(id[:3]+".txt"), []byte(id), 0o644))
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	assert.NilError(t, os.Chtimes(filepath.Join(s.Dir(), "old", "run", journal.FileName), old, old))
+
+	runs, err = s.Runs()
+	assert.NilError(t, err)
+	assert.Equal(t, len(runs), 2)
+	assert.Equal(t, runs[0].WorkflowID, "old")
+	assert.Equal(t, runs[1].WorkflowID, "new/1")
+	assert.Equal(t, runs[1].RunID, "run")
+
+	// The journal of an active run is kept.
+	purged, err := s.Purge(time.Now().Add(-24*time.Hour), func(r journal.Run) (bool, error) {
+		return r.WorkflowID == "old", nil
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, len(purged), 0)
+
+	_, err = s.Purge(time.Now().Add(-24*time.Hour), func(r journal.Run) (bool, error) {
+		return false, errors.New("unavailable")
+	})
+	assert.Error(t, err, "journal: unavailable")
+
+	purged, err = s.Purge(time.Now().Add(-24*time.Hour), nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(purged), 1)
+	assert.Equal(t, purged[0].WorkflowID, "old")
+	entries, err := os.ReadDir(s.Dir())
+	assert.NilError(t, err)
+	assert.Equal(t, len(entries), 1)
+	assert.Equal(t, entries[0].Name(), "new%2F1")
+}
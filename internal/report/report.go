@@ -0,0 +1,103 @@
+// Package report renders the preprocessing report written into the SIP, so
+// it's preserved in the AIP.
+package report
+
+import (
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/validation"
+)
+
+// Report formats.
+const (
+	HTML     = "html"
+	Markdown = "markdown"
+)
+
+// Formats lists the supported report formats.
+var Formats = []string{HTML, Markdown}
+
+// Filename returns the name of the report file in the format.
+func Filename(format string) string {
+	if format == Markdown {
+		return "preprocessing-report.md"
+	}
+
+	return "preprocessing-report.html"
+}
+
+//go:embed templates
+var templates embed.FS
+
+// Report describes the preprocessing of a SIP.
+type Report struct {
+	// SIP is the name of the SIP.
+	SIP         string
+	GeneratedAt time.Time
+
+	// Version and GitCommit identify the preprocessing worker build.
+	Version   string
+	GitCommit string
+
+	Steps    []Step
+	Removed  []string
+	Findings validation.Findings
+	Formats  []Format
+	Files    []File
+}
+
+// Step is a completed preprocessing step.
+type Step struct {
+	Name      string
+	StartTime time.Time
+	Duration  time.Duration
+	FileCount int
+}
+
+// Format summarizes the files of the SIP with the same file extension.
+type Format struct {
+	Extension string
+	Count     int
+	Size      int64
+}
+
+// File is a file of the SIP, with its path relative to the SIP.
+type File struct {
+	Path   string
+	Size   int64
+	SHA256 string
+}
+
+var funcs = map[string]any{
+	"time": func(t time.Time) string { return t.UTC().Format(time.RFC3339) },
+	"md": func(s string) string {
+		// Escape the characters with a meaning in Markdown tables and text.
+		return strings.NewReplacer(`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`, "`", "\\`", "<", `\<`, ">", `\>`).Replace(s)
+	},
+}
+
+// Write renders r in format to w.
+func Write(w io.Writer, format string, r *Report) error {
+	switch format {
+	case HTML:
+		t, err := htmltemplate.New("report.html.tmpl").Funcs(funcs).ParseFS(templates, "templates/report.html.tmpl")
+		if err != nil {
+			return err
+		}
+		return t.Execute(w, r)
+	case Markdown:
+		t, err := texttemplate.New("report.md.tmpl").Funcs(funcs).ParseFS(templates, "templates/report.md.tmpl")
+		if err != nil {
+			return err
+		}
+		return t.Execute(w, r)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
@@ -0,0 +1,72 @@
+package report_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/golden"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/report"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/validation"
+)
+
+var testReport = &report.Report{
+	SIP:         "small_with_ds_store",
+	GeneratedAt: time.Date(2024, 6, 3, 14, 0, 0, 0, time.UTC),
+	Version:     "0.1.0",
+	GitCommit:   "1b88b1f",
+	Steps: []report.Step{
+		{
+			Name:      "remove-files",
+			StartTime: time.Date(2024, 6, 3, 13, 59, 0, 0, time.UTC),
+			Duration:  1500 * time.Millisecond,
+			FileCount: 1,
+		},
+	},
+	Removed: []string{".DS_Store"},
+	Findings: validation.Findings{
+		{
+			Severity: validation.Info,
+			Code:     "unwanted-files-removed",
+			Message:  "1 unwanted files removed.",
+		},
+		{
+			Severity: validation.Warning,
+			Code:     "suspicious_name",
+			Path:     "a|b<c>.txt",
+			Message:  "Name with <special> characters.",
+		},
+	},
+	Formats: []report.Format{{Extension: ".txt", Count: 1, Size: 19}},
+	Files: []report.File{
+		{
+			Path:   "small.txt",
+			Size:   19,
+			SHA256: "4ac1a2a9cfa4ad9a2b3c4bd8c1e7d9c4c0ae8ec2e5bd2a8b4e3bd0f25b9f0b7e",
+		},
+	},
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range report.Formats {
+		t.Run(format, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			err := report.Write(&buf, format, testReport)
+			assert.NilError(t, err)
+			golden.Assert(t, buf.String(), report.Filename(format))
+		})
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	t.Parallel()
+
+	err := report.Write(&bytes.Buffer{}, "pdf", testReport)
+	assert.Error(t, err, `unknown report format "pdf"`)
+}
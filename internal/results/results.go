@@ -0,0 +1,188 @@
+// Package results stores the large activity results in files, so the
+// activities and the workflow pass small references instead of payloads that
+// could exceed the Temporal blob and history size limits.
+package results
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	dirMode  fs.FileMode = 0o700
+	fileMode fs.FileMode = 0o600
+
+	digestPrefix = "sha256:"
+)
+
+// ErrDigestMismatch is returned when a result file doesn't match the digest of
+// its reference, e.g. because it was modified after it was written.
+var ErrDigestMismatch = errors.New("result digest mismatch")
+
+// Ref references a result file written by a Store.
+type Ref struct {
+	// Path is the result file path, relative to the Store directory.
+	Path string
+
+	// Digest is the SHA-256 checksum of the file, e.g. "sha256:<hex>".
+	Digest string
+
+	// Size is the file size in bytes.
+	Size int64
+}
+
+// Store writes and loads the JSON encoded results in a directory.
+type Store struct {
+	dir string
+}
+
+// NewStore returns a Store of the results in the dir directory, it's created
+// when the first result is written.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir returns the directory of the results.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Write writes v JSON encoded in the name file, relative to the Store
+// directory, and returns its reference. An existing file is replaced.
+func (s *Store) Write(name string, v any) (*Ref, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), dirMode); err != nil {
+		return nil, fmt.Errorf("write result: %w", err)
+	}
+
+	// Write a temporary file first, so a partially written result is never
+	// loaded.
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return nil, fmt.Errorf("write result: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	h := sha256.New()
+	cw := &countWriter{w: io.MultiWriter(f, h)}
+	if err := json.NewEncoder(cw).Encode(v); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("write result: %w", err)
+	}
+	if err := f.Chmod(fileMode); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("write result: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("write result: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return nil, fmt.Errorf("write result: %w", err)
+	}
+
+	return &Ref{
+		Path:   filepath.ToSlash(filepath.Clean(name)),
+		Digest: digestPrefix + hex.EncodeToString(h.Sum(nil)),
+		Size:   cw.n,
+	}, nil
+}
+
+// Load decodes the result file referenced by ref into v. It returns an error
+// wrapping ErrDigestMismatch if the file doesn't match the ref digest or size.
+func (s *Store) Load(ref *Ref, v any) error {
+	if ref == nil {
+		return errors.New("load result: nil reference")
+	}
+
+	path, err := s.path(ref.Path)
+	if err != nil {
+		return err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("load result: %w", err)
+	}
+
+	sum := sha256.Sum256(b)
+	if digest := digestPrefix + hex.EncodeToString(sum[:]); digest != ref.Digest || int64(len(b)) != ref.Size {
+		return fmt.Errorf("load result %q: %w", ref.Path, ErrDigestMismatch)
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("load result %q: %v", ref.Path, err)
+	}
+
+	return nil
+}
+
+// Read decodes the name file into v without a reference, e.g. to load the
+// partial results written by a previous activity attempt. It returns an error
+// wrapping fs.ErrNotExist if the file doesn't exist.
+func (s *Store) Read(name string, v any) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read result: %w", err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("read result %q: %v", name, err)
+	}
+
+	return nil
+}
+
+// Remove removes the name file, a missing file isn't an error.
+func (s *Store) Remove(name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove result: %w", err)
+	}
+
+	return nil
+}
+
+// path returns the absolute path of the name result, name must be a local path
+// so the results can't be written or read outside of the Store directory.
+func (s *Store) path(name string) (string, error) {
+	if s.dir == "" {
+		return "", errors.New("result: missing results directory")
+	}
+	name = filepath.FromSlash(name)
+	if !filepath.IsLocal(name) || strings.HasPrefix(filepath.Base(name), ".tmp-") {
+		return "", fmt.Errorf("result: invalid name %q", name)
+	}
+
+	return filepath.Join(s.dir, name), nil
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,99 @@
+package results_test
+
+import (
+	"errors"
+	iofs "io/fs"
+	"os"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/fs"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/results"
+)
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	dir := fs.NewDir(t, "")
+	s := results.NewStore(dir.Join("results"))
+
+	ref, err := s.Write("wid/rid/removed-files.json", []string{".DS_Store", "a/.DS_Store"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ref, &results.Ref{
+		Path:   "wid/rid/removed-files.json",
+		Digest: "sha256:2776dd97a07668a01730077e4e35379853f47e8df12b2a9be4b7d81120fd2b0d",
+		Size:   28,
+	})
+	assert.Assert(t, fs.Equal(dir.Path(), fs.Expected(t,
+		fs.WithDir("results", fs.WithMode(0o700),
+			fs.WithDir("wid", fs.WithMode(0o700),
+				fs.WithDir("rid", fs.WithMode(0o700),
+					fs.WithFile(
+						"removed-files.json", `[".DS_Store","a/.DS_Store"]`+"\n",
+						fs.WithMode(0o600),
+					),
+				),
+			),
+		),
+	)))
+
+	var removed []string
+	assert.NilError(t, s.Load(ref, &removed))
+	assert.DeepEqual(t, removed, []string{".DS_Store", "a/.DS_Store"})
+}
+
+func TestStoreReadRemove(t *testing.T) {
+	t.Parallel()
+
+	dir := fs.NewDir(t, "")
+	s := results.NewStore(dir.Path())
+
+	_, err := s.Write("wid/rid/part-0001.json", []string{".DS_Store"})
+	assert.NilError(t, err)
+
+	var removed []string
+	assert.NilError(t, s.Read("wid/rid/part-0001.json", &removed))
+	assert.DeepEqual(t, removed, []string{".DS_Store"})
+
+	assert.NilError(t, s.Remove("wid/rid/part-0001.json"))
+	assert.NilError(t, s.Remove("wid/rid/part-0001.json"))
+
+	err = s.Read("wid/rid/part-0001.json", &removed)
+	assert.Assert(t, errors.Is(err, iofs.ErrNotExist))
+}
+
+func TestStoreLoadVerifiesDigest(t *testing.T) {
+	t.Parallel()
+
+	dir := fs.NewDir(t, "")
+	s := results.NewStore(dir.Path())
+
+	ref, err := s.Write("removed-files.json", []string{".DS_Store"})
+	assert.NilError(t, err)
+
+	err = os.WriteFile(dir.Join("removed-files.json"), []byte(`["other"]`+"\n"), 0o600)
+	assert.NilError(t, err)
+
+	var removed []string
+	err = s.Load(ref, &removed)
+	assert.Assert(t, errors.Is(err, results.ErrDigestMismatch))
+	assert.Error(t, err, `load result "removed-files.json": result digest mismatch`)
+}
+
+func TestStoreInvalidName(t *testing.T) {
+	t.Parallel()
+
+	s := results.NewStore(fs.NewDir(t, "").Path())
+
+	for _, name := range []string{"", "../removed-files.json", "/tmp/removed-files.json"} {
+		_, err := s.Write(name, nil)
+		assert.ErrorContains(t, err, "result: invalid name")
+
+		err = s.Load(&results.Ref{Path: name}, nil)
+		assert.ErrorContains(t, err, "result: invalid name")
+	}
+
+	err := results.NewStore("").Load(&results.Ref{Path: "removed-files.json"}, nil)
+	assert.Error(t, err, "result: missing results directory")
+}
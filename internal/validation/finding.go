@@ -0,0 +1,107 @@
+// Package validation provides the model of the findings reported by the
+// preprocessing checks.
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity is the severity of a Finding.
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Error
+)
+
+var severityNames = []string{"info", "warning", "error"}
+
+// ParseSeverity returns the Severity named s ("info", "warning" or "error").
+func ParseSeverity(s string) (Severity, error) {
+	for i, name := range severityNames {
+		if strings.EqualFold(s, name) {
+			return Severity(i), nil
+		}
+	}
+
+	return Info, fmt.Errorf("invalid severity %q, must be one of: %s", s, strings.Join(severityNames, ", "))
+}
+
+func (s Severity) String() string {
+	if s < Info || s > Error {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+
+	return severityNames[s]
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	if s < Info || s > Error {
+		return nil, fmt.Errorf("invalid severity %d", int(s))
+	}
+
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(b []byte) error {
+	v, err := ParseSeverity(string(b))
+	if err != nil {
+		return err
+	}
+	*s = v
+
+	return nil
+}
+
+// Finding is the result of a check.
+type Finding struct {
+	Severity Severity
+
+	// Code identifies the check and the issue found, e.g.
+	// "unwanted-files-removed".
+	Code string
+
+	// Path is the path of the checked file or directory, relative to the SIP
+	// (optional).
+	Path string `json:",omitempty"`
+
+	Message string
+}
+
+func (f Finding) String() string {
+	if f.Path == "" {
+		return fmt.Sprintf("%s: %s: %s", f.Severity, f.Code, f.Message)
+	}
+
+	return fmt.Sprintf("%s: %s: %s: %s", f.Severity, f.Code, f.Path, f.Message)
+}
+
+// Findings is a list of findings.
+type Findings []Finding
+
+// Count returns the number of findings with severity s.
+func (fs Findings) Count(s Severity) int {
+	var n int
+	for _, f := range fs {
+		if f.Severity == s {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Blocking returns the findings with a severity greater than or equal to
+// threshold.
+func (fs Findings) Blocking(threshold Severity) Findings {
+	var blocking Findings
+	for _, f := range fs {
+		if f.Severity >= threshold {
+			blocking = append(blocking, f)
+		}
+	}
+
+	return blocking
+}
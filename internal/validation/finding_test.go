@@ -0,0 +1,61 @@
+package validation_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/validation"
+)
+
+func TestParseSeverity(t *testing.T) {
+	t.Parallel()
+
+	s, err := validation.ParseSeverity("Warning")
+	assert.NilError(t, err)
+	assert.Equal(t, s, validation.Warning)
+
+	_, err = validation.ParseSeverity("fatal")
+	assert.Error(t, err, `invalid severity "fatal", must be one of: info, warning, error`)
+}
+
+func TestFindingJSON(t *testing.T) {
+	t.Parallel()
+
+	f := validation.Finding{
+		Severity: validation.Error,
+		Code:     "empty-sip",
+		Message:  "The SIP has no files.",
+	}
+
+	b, err := json.Marshal(f)
+	assert.NilError(t, err)
+	assert.Equal(t, string(b), `{"Severity":"error","Code":"empty-sip","Message":"The SIP has no files."}`)
+
+	var got validation.Finding
+	assert.NilError(t, json.Unmarshal(b, &got))
+	assert.DeepEqual(t, got, f)
+}
+
+func TestFindingsBlocking(t *testing.T) {
+	t.Parallel()
+
+	fs := validation.Findings{
+		{Severity: validation.Info, Code: "a"},
+		{Severity: validation.Warning, Code: "b"},
+		{Severity: validation.Error, Code: "c"},
+		{Severity: validation.Warning, Code: "d"},
+	}
+
+	assert.Equal(t, fs.Count(validation.Warning), 2)
+	assert.DeepEqual(t, fs.Blocking(validation.Error), validation.Findings{
+		{Severity: validation.Error, Code: "c"},
+	})
+	assert.DeepEqual(t, fs.Blocking(validation.Warning), validation.Findings{
+		{Severity: validation.Warning, Code: "b"},
+		{Severity: validation.Error, Code: "c"},
+		{Severity: validation.Warning, Code: "d"},
+	})
+	assert.Equal(t, len(validation.Findings{}.Blocking(validation.Info)), 0)
+}
@@ -0,0 +1,70 @@
+package workflow
+
+import (
+	"fmt"
+	"time"
+
+	"go.artefactual.dev/tools/temporal"
+	temporalsdk_workflow "go.temporal.io/sdk/workflow"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/activities"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+)
+
+// HousekeepingWorkflowName is the name of the workflow purging the trash, it's
+// started by the schedule created by the preprocessing worker.
+const HousekeepingWorkflowName = "preprocessing-housekeeping"
+
+// purgeTrashTimeout is the StartToCloseTimeout of the purge trash activity.
+const purgeTrashTimeout = time.Hour
+
+type HousekeepingWorkflowParams struct {
+	// Retention is the minimum age of the journals purged, with the trash of
+	// the removed files.
+	Retention time.Duration
+}
+
+type HousekeepingWorkflowResult struct {
+	// Purged is the number of journals purged.
+	Purged int
+}
+
+type HousekeepingWorkflow struct {
+	activityTaskQueue string
+}
+
+// NewHousekeepingWorkflow returns a HousekeepingWorkflow running its
+// activities in the activity task queue of cfg.
+func NewHousekeepingWorkflow(cfg config.Configuration) *HousekeepingWorkflow {
+	return &HousekeepingWorkflow{activityTaskQueue: cfg.Worker.ActivityTaskQueue}
+}
+
+// HousekeepingScheduleID returns the ID of the schedule of the
+// HousekeepingWorkflow of the workers polling taskQueue.
+func HousekeepingScheduleID(taskQueue string) string {
+	return HousekeepingWorkflowName + ":" + taskQueue
+}
+
+// Execute purges the journals older than params.Retention, with the trash of
+// the removed files.
+func (w *HousekeepingWorkflow) Execute(
+	ctx temporalsdk_workflow.Context,
+	params *HousekeepingWorkflowParams,
+) (*HousekeepingWorkflowResult, error) {
+	if params == nil || params.Retention <= 0 {
+		return nil, temporal.NewNonRetryableError(fmt.Errorf("error calling workflow with unexpected inputs"))
+	}
+
+	var result activities.PurgeTrashResult
+	err := temporalsdk_workflow.ExecuteActivity(
+		withActivityOptions(ctx, w.activityTaskQueue, config.ActivityConfig{StartToCloseTimeout: purgeTrashTimeout}),
+		activities.PurgeTrashName,
+		&activities.PurgeTrashParams{Retention: params.Retention},
+	).Get(ctx, &result)
+	if err != nil {
+		return nil, err
+	}
+	temporalsdk_workflow.GetLogger(ctx).Info("Trash purged.", "count", result.Count)
+
+	return &HousekeepingWorkflowResult{Purged: result.Count}, nil
+}
@@ -0,0 +1,44 @@
+package workflow_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	temporalsdk_activity "go.temporal.io/sdk/activity"
+	temporalsdk_testsuite "go.temporal.io/sdk/testsuite"
+	"gotest.tools/v3/assert"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/activities"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/journal"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/workflow"
+)
+
+func TestHousekeepingWorkflow(t *testing.T) {
+	t.Parallel()
+
+	var ts temporalsdk_testsuite.WorkflowTestSuite
+	env := ts.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(
+		activities.NewPurgeTrash(journal.NewStore(t.TempDir()), nil).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.PurgeTrashName},
+	)
+	env.OnActivity(
+		activities.PurgeTrashName,
+		mock.Anything,
+		&activities.PurgeTrashParams{Retention: 720 * time.Hour},
+	).Return(&activities.PurgeTrashResult{Count: 3}, nil)
+
+	env.ExecuteWorkflow(
+		workflow.NewHousekeepingWorkflow(config.Configuration{}).Execute,
+		&workflow.HousekeepingWorkflowParams{Retention: 720 * time.Hour},
+	)
+	assert.Assert(t, env.IsWorkflowCompleted())
+	assert.NilError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+
+	var result workflow.HousekeepingWorkflowResult
+	assert.NilError(t, env.GetWorkflowResult(&result))
+	assert.Equal(t, result.Purged, 3)
+}
@@ -0,0 +1,42 @@
+package workflow
+
+import (
+	temporalsdk_workflow "go.temporal.io/sdk/workflow"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/activities"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+)
+
+// rollbackJournal rolls back the journaled mutations of the SIP of a failed
+// execution, restoring the original SIP. A failure is logged without changing
+// the execution error, the journal is kept to restore the trash manually.
+func (w *PreprocessingWorkflow) rollbackJournal(ctx temporalsdk_workflow.Context, cfg config.Reloadable) {
+	// The mutations are rolled back even if the execution is canceled.
+	ctx, cancel := temporalsdk_workflow.NewDisconnectedContext(ctx)
+	defer cancel()
+
+	var result activities.RollbackJournalResult
+	err := temporalsdk_workflow.ExecuteActivity(
+		w.withActOpts(ctx, cfg.Activities.Default),
+		activities.RollbackJournalName,
+		&activities.RollbackJournalParams{},
+	).Get(ctx, &result)
+	if err != nil {
+		temporalsdk_workflow.GetLogger(ctx).Error("Unable to roll back the journal of the SIP.", "error", err)
+		return
+	}
+	temporalsdk_workflow.GetLogger(ctx).Info("Journal rolled back.", "count", result.Count)
+}
+
+// deleteJournal deletes the journal of a successful execution started before
+// the trashVersion. A failure is logged without failing the execution.
+func (w *PreprocessingWorkflow) deleteJournal(ctx temporalsdk_workflow.Context, cfg config.Reloadable) {
+	err := temporalsdk_workflow.ExecuteActivity(
+		w.withActOpts(ctx, cfg.Activities.Default),
+		activities.DeleteJournalName,
+		&activities.DeleteJournalParams{},
+	).Get(ctx, nil)
+	if err != nil {
+		temporalsdk_workflow.GetLogger(ctx).Warn("Unable to delete the journal of the SIP.", "error", err)
+	}
+}
@@ -0,0 +1,115 @@
+package workflow
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	temporalapi_enums "go.temporal.io/api/enums/v1"
+	temporalsdk_temporal "go.temporal.io/sdk/temporal"
+	temporalsdk_workflow "go.temporal.io/sdk/workflow"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+)
+
+const (
+	// LockWorkflowName is the name of the workflow holding the lock of a
+	// relative path, it must be registered in the preprocessing worker.
+	LockWorkflowName = "preprocessing-lock"
+
+	// ErrTypeLocked is the type of the error returned when the relative path
+	// is being processed by another workflow execution, its details are the
+	// ID of the lock workflow.
+	ErrTypeLocked = "LockedError"
+
+	// lockStep is the name of the step waiting for the lock in the workflow
+	// Progress.
+	lockStep = "lock"
+
+	// defaultLockPollInterval is used when the lock PollInterval isn't
+	// configured.
+	defaultLockPollInterval = time.Minute
+)
+
+// LockWorkflow holds the lock of relPath until it's canceled by the
+// preprocessing workflow holding it, or terminated when the preprocessing
+// workflow closes.
+func LockWorkflow(ctx temporalsdk_workflow.Context, relPath string) error {
+	temporalsdk_workflow.GetLogger(ctx).Debug("Lock acquired.", "relativePath", relPath)
+
+	// Await only returns when the workflow is canceled.
+	_ = temporalsdk_workflow.Await(ctx, func() bool { return false })
+
+	return nil
+}
+
+// lockWorkflowID returns the ID of the workflow holding the lock of relPath.
+// Temporal only allows one running workflow execution with a given ID in a
+// namespace, so the lock can't be held by two executions.
+func lockWorkflowID(relPath string) string {
+	return LockWorkflowName + ":" + filepath.Clean(relPath)
+}
+
+// lock starts the LockWorkflow of relPath as a child workflow, so the other
+// executions can't process relPath until the returned release function is
+// called or the execution closes.
+//
+// If relPath is locked by another execution, lock waits until it's released
+// or returns an ErrTypeLocked error, according to cfg.
+func lock(
+	ctx temporalsdk_workflow.Context,
+	cfg config.LockConfig,
+	relPath string,
+	progress *Progress,
+) (func(), error) {
+	logger := temporalsdk_workflow.GetLogger(ctx)
+	id := lockWorkflowID(relPath)
+
+	pollInterval := cfg.PollInterval
+	if pollInterval == 0 {
+		pollInterval = defaultLockPollInterval
+	}
+
+	var waiting bool
+	start := temporalsdk_workflow.Now(ctx)
+	for {
+		lockCtx, release := temporalsdk_workflow.WithCancel(ctx)
+		lockCtx = temporalsdk_workflow.WithChildOptions(lockCtx, temporalsdk_workflow.ChildWorkflowOptions{
+			WorkflowID:            id,
+			WorkflowIDReusePolicy: temporalapi_enums.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE,
+			ParentClosePolicy:     temporalapi_enums.PARENT_CLOSE_POLICY_TERMINATE,
+		})
+
+		err := temporalsdk_workflow.ExecuteChildWorkflow(lockCtx, LockWorkflowName, relPath).
+			GetChildWorkflowExecution().Get(ctx, nil)
+		if err == nil {
+			if waiting {
+				progress.completeStep(ctx, 0)
+			}
+			return release, nil
+		}
+		release()
+		if !temporalsdk_temporal.IsWorkflowExecutionAlreadyStartedError(err) {
+			return nil, err
+		}
+
+		if cfg.Mode == config.LockModeFail ||
+			(cfg.Timeout > 0 && temporalsdk_workflow.Now(ctx).Sub(start) >= cfg.Timeout) {
+			return nil, temporalsdk_temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("%s is being processed by another workflow execution", relPath),
+				ErrTypeLocked,
+				nil,
+				id,
+			)
+		}
+
+		if !waiting {
+			logger.Info("Waiting for lock.", "relativePath", relPath, "lockWorkflowID", id)
+			progress.startStep(ctx, lockStep)
+			waiting = true
+		}
+		if err := temporalsdk_workflow.Sleep(ctx, pollInterval); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -5,27 +5,204 @@ import (
 	"path/filepath"
 	"time"
 
-	"github.com/artefactual-sdps/temporal-activities/removefiles"
 	"go.artefactual.dev/tools/temporal"
 	temporalsdk_temporal "go.temporal.io/sdk/temporal"
 	temporalsdk_workflow "go.temporal.io/sdk/workflow"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/activities"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/report"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/results"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/validation"
+)
+
+// Workflow changes are guarded with temporalsdk_workflow.GetVersion so the
+// executions started by a previous release can be completed (and replayed)
+// after a deploy. Add a new change ID for each change of the commands issued
+// by the workflow, and a history of the previous release to testdata/histories.
+const (
+	// configChangeID versions the workflow configuration:
+	//
+	//   - DefaultVersion: the unwanted files and activity options are fixed and
+	//     the files are removed by the "remove-files-activity" activity.
+	//   - 1: the reloadable configuration is recorded at the start of the
+	//     execution and the files are removed by the "remove-files" activity.
+	configChangeID = "config"
+	configVersion  = 1
+
+	// reportChangeID versions the preprocessing report:
+	//
+	//   - DefaultVersion: no report is written.
+	//   - 1: the report is written into the SIP by the "write-report" activity.
+	reportChangeID = "report"
+	reportVersion  = 1
+
+	// sessionChangeID versions the worker running the activities:
+	//
+	//   - DefaultVersion: each activity runs on any worker.
+	//   - 1: the activities run on the same worker, in a Temporal session.
+	sessionChangeID = "session"
+	sessionVersion  = 1
+
+	// searchAttributesChangeID versions the search attributes:
+	//
+	//   - DefaultVersion: no search attribute is upserted.
+	//   - 1: the SearchAttributes are upserted as the execution progresses.
+	searchAttributesChangeID = "search-attributes"
+	searchAttributesVersion  = 1
+
+	// lockChangeID versions the relative path lock:
+	//
+	//   - DefaultVersion: a relative path can be processed by two executions
+	//     at the same time.
+	//   - 1: the relative path is locked by a LockWorkflow child workflow
+	//     until the execution closes.
+	lockChangeID = "lock"
+	lockVersion  = 1
+
+	// stagingChangeID versions the SIP staging:
+	//
+	//   - DefaultVersion: the activities process the SIP in place.
+	//   - 1: the activities process a staged copy of the SIP when
+	//     Staging.Enabled is set, the copy replaces the SIP when the execution
+	//     succeeds.
+	stagingChangeID = "staging"
+	stagingVersion  = 1
+
+	// journalChangeID versions the journal of the SIP mutations:
+	//
+	//   - DefaultVersion: the mutations of a failed execution are kept.
+	//   - 1: the mutations are rolled back when the execution fails and
+	//     Journal.Enabled is set, the journal is deleted when it succeeds.
+	//   - 2: the journal is kept with the trash of the removed files when the
+	//     execution succeeds, until it's purged by the HousekeepingWorkflow.
+	journalChangeID = "journal"
+	journalVersion  = 1
+	trashVersion    = 2
+
+	// macMetadataChangeID versions the macOS metadata preservation:
+	//
+	//   - DefaultVersion: the AppleDouble files are removed with their
+	//     metadata.
+	//   - 1: the metadata of the AppleDouble files and the extended attributes
+	//     are written in sidecars by the "preserve-mac-metadata" activity
+	//     before the unwanted files are removed, when MacMetadata.Enabled is
+	//     set.
+	macMetadataChangeID = "mac-metadata"
+	macMetadataVersion  = 1
+
+	// timestampsChangeID versions the file timestamps preservation:
+	//
+	//   - DefaultVersion: the modification times changed by the activities
+	//     are kept.
+	//   - 1: the timestamps are captured by the "capture-timestamps" activity
+	//     before the SIP is processed, and the modification times are
+	//     restored by the "restore-timestamps" activity after the report is
+	//     written, when Timestamps.Enabled is set.
+	timestampsChangeID = "timestamps"
+	timestampsVersion  = 1
+
+	// timestampCheckChangeID versions the file timestamps check:
+	//
+	//   - DefaultVersion: the file timestamps aren't checked.
+	//   - 1: the "check-timestamps" activity reports the files modified
+	//     before TimestampCheck.MinDate or after TimestampCheck.MaxFuture as
+	//     warning findings, when TimestampCheck.Enabled is set.
+	timestampCheckChangeID = "timestamp-check"
+	timestampCheckVersion  = 1
+
+	// reviewChangeID versions the review gate:
+	//
+	//   - DefaultVersion: the Review parameter is ignored.
+	//   - 1: the execution waits for the ReviewSignal before the SIP is
+	//     published, when Review is set.
+	reviewChangeID = "review"
+	reviewVersion  = 1
 )
 
+// ErrTypeValidation is the type of the error returned when the preprocessing
+// findings reach the configured blocking severity, its details are the
+// findings.
+const ErrTypeValidation = "ValidationError"
+
 type PreprocessingWorkflowParams struct {
 	RelativePath string
+
+	// Review pauses the workflow after the SIP is cleaned until a reviewer
+	// approves or rejects it with the ReviewSignal.
+	Review bool `json:",omitempty"`
+
+	// TransferType is the type of the transfer, e.g. "standard" (optional).
+	// It's only recorded in the TransferTypeAttr search attribute.
+	TransferType string `json:",omitempty"`
+
+	// Depositor identifies who deposited the transfer (optional). It's only
+	// recorded in the DepositorAttr search attribute.
+	Depositor string `json:",omitempty"`
 }
 
 type PreprocessingWorkflowResult struct {
 	RelativePath string
+
+	// ConfigVersion is the version of the configuration applied by the
+	// execution, see config.Reloadable.Version.
+	ConfigVersion string `json:",omitempty"`
+
+	// Findings lists the findings of the preprocessing checks, none of them
+	// reaches the configured blocking severity.
+	Findings validation.Findings `json:",omitempty"`
+
+	// Review is the review result, when a review was requested.
+	Review *ReviewResult `json:",omitempty"`
+
+	// Removed references the list of the files removed from the SIP in the
+	// results directory, use results.Store.Load to read it.
+	Removed *results.Ref `json:",omitempty"`
+
+	// Inventory references the inventory of the SIP files, with their size and
+	// checksum, in the results directory.
+	Inventory *results.Ref `json:",omitempty"`
+
+	// OutOfBounds references the activities.OutOfBounds list of the files
+	// whose modification time is out of the TimestampCheck bounds in the
+	// results directory, the findings only report a sample of them.
+	OutOfBounds *results.Ref `json:",omitempty"`
+
+	// FileCount and TotalBytes are the number and total size of the SIP files
+	// listed in the Inventory.
+	FileCount  int   `json:",omitempty"`
+	TotalBytes int64 `json:",omitempty"`
 }
 
 type PreprocessingWorkflow struct {
-	sharedPath string
+	sharedPath        string
+	activityTaskQueue string
+	sessionOptions    temporalsdk_workflow.SessionOptions
+	cfg               config.Provider
 }
 
-func NewPreprocessingWorkflow(sharedPath string) *PreprocessingWorkflow {
+// NewPreprocessingWorkflow returns a PreprocessingWorkflow using the cfg
+// configuration. The reloadable configuration is read when an execution
+// starts, so configuration changes apply to the new executions.
+func NewPreprocessingWorkflow(cfg config.Provider) *PreprocessingWorkflow {
+	c := cfg.Config()
+
+	opts := temporalsdk_workflow.SessionOptions{
+		CreationTimeout:  c.Worker.SessionCreationTimeout,
+		ExecutionTimeout: c.Worker.SessionExecutionTimeout,
+	}
+	if opts.CreationTimeout == 0 {
+		opts.CreationTimeout = config.DefaultSessionCreationTimeout
+	}
+	if opts.ExecutionTimeout == 0 {
+		opts.ExecutionTimeout = config.DefaultSessionExecutionTimeout
+	}
+
 	return &PreprocessingWorkflow{
-		sharedPath: sharedPath,
+		sharedPath:        c.SharedPath,
+		activityTaskQueue: c.Worker.ActivityTaskQueue,
+		sessionOptions:    opts,
+		cfg:               cfg,
 	}
 }
 
@@ -41,32 +218,593 @@ func (w *PreprocessingWorkflow) Execute(
 		return nil, e
 	}
 
-	localPath := filepath.Join(w.sharedPath, filepath.Clean(params.RelativePath))
+	// Report the execution progress, the query handler doesn't add commands
+	// to the workflow history.
+	progress := &Progress{}
+	if err := temporalsdk_workflow.SetQueryHandler(ctx, ProgressQuery, func() (*Progress, error) {
+		return progress, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	cfg, removeFilesName, err := w.config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	progress.ConfigVersion = cfg.Version()
+	logger.Debug("PreprocessingWorkflow configuration loaded.", "version", progress.ConfigVersion)
+
+	sa := newSearchAttributes(ctx)
+	updates := []temporalsdk_temporal.SearchAttributeUpdate{RelativePathAttr.ValueSet(params.RelativePath)}
+	if params.TransferType != "" {
+		updates = append(updates, TransferTypeAttr.ValueSet(params.TransferType))
+	}
+	if params.Depositor != "" {
+		updates = append(updates, DepositorAttr.ValueSet(params.Depositor))
+	}
+	sa.upsert(ctx, updates...)
+
+	// Record the outcome of the execution, with the rejection reason of the
+	// rejected or invalid SIPs.
+	defer func() {
+		o, reason := outcome(e)
+		updates := []temporalsdk_temporal.SearchAttributeUpdate{OutcomeAttr.ValueSet(o)}
+		if reason != "" {
+			updates = append(updates, RejectionReasonAttr.ValueSet(reason))
+		}
+		sa.upsert(ctx, updates...)
+	}()
+
+	// Lock the relative path, so it's never processed by two executions at the
+	// same time.
+	if v := temporalsdk_workflow.GetVersion(ctx, lockChangeID, temporalsdk_workflow.DefaultVersion, lockVersion); v >= lockVersion {
+		release, err := lock(ctx, cfg.Lock, params.RelativePath, progress)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
+	// Process a staged copy of the SIP when staging is enabled, the SIP is
+	// only replaced when the execution succeeds.
+	v := temporalsdk_workflow.GetVersion(ctx, stagingChangeID, temporalsdk_workflow.DefaultVersion, stagingVersion)
+	ws := &workspace{
+		path:    filepath.Join(w.sharedPath, filepath.Clean(params.RelativePath)),
+		staging: v >= stagingVersion && cfg.Staging.Enabled,
+	}
+	defer func() {
+		if e != nil && ws.staging {
+			w.discardStage(ctx, cfg, ws)
+		}
+	}()
+
+	// Roll back the journaled mutations of the SIP if the execution fails and
+	// the journal is enabled.
+	jv := temporalsdk_workflow.GetVersion(ctx, journalChangeID, temporalsdk_workflow.DefaultVersion, trashVersion)
+	ws.journal = jv >= journalVersion && cfg.Journal.Enabled
+	defer func() {
+		if !ws.journal {
+			return
+		}
+		if e != nil {
+			w.rollbackJournal(ctx, cfg)
+		} else if jv < trashVersion {
+			w.deleteJournal(ctx, cfg)
+		}
+	}()
+
+	// Process the SIP, all the activities run on the same worker since the
+	// sessionChangeID version.
+	result := &PreprocessingWorkflowResult{
+		RelativePath:  params.RelativePath,
+		ConfigVersion: progress.ConfigVersion,
+	}
+	v = temporalsdk_workflow.GetVersion(ctx, sessionChangeID, temporalsdk_workflow.DefaultVersion, sessionVersion)
+	if v == temporalsdk_workflow.DefaultVersion {
+		err = w.process(ctx, cfg, removeFilesName, ws, progress, result)
+	} else {
+		err = w.processInSession(ctx, cfg, removeFilesName, ws, progress, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if result.Inventory != nil {
+		sa.upsert(ctx,
+			FileCountAttr.ValueSet(int64(result.FileCount)),
+			TotalBytesAttr.ValueSet(result.TotalBytes),
+		)
+	}
+
+	if err := checkFindings(progress.Findings, cfg.Validation); err != nil {
+		return nil, err
+	}
+	result.Findings = progress.Findings
+
+	// Wait for the review of the SIP. The executions started with Review set
+	// on a worker predating the review gate ignored it, so their histories
+	// have no review step.
+	v = temporalsdk_workflow.GetVersion(ctx, reviewChangeID, temporalsdk_workflow.DefaultVersion, reviewVersion)
+	if v >= reviewVersion && params.Review {
+		progress.startStep(ctx, reviewStep)
+		result.Review = review(ctx, cfg.Review.Timeout)
+		progress.completeStep(ctx, 0)
+		if result.Review.Status != ReviewApproved {
+			return nil, reviewError(result.Review)
+		}
+	}
+
+	if ws.staging {
+		if err := w.publishStage(ctx, cfg, ws, progress); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// processInSession runs process in a Temporal session, so all the activities
+// run on the same worker. The activities are run again in a new session if the
+// session fails, e.g. when its worker is restarted.
+func (w *PreprocessingWorkflow) processInSession(
+	ctx temporalsdk_workflow.Context,
+	cfg config.Reloadable,
+	removeFilesName string,
+	ws *workspace,
+	progress *Progress,
+	result *PreprocessingWorkflowResult,
+) error {
+	for {
+		opts := w.sessionOptions
+		sessCtx, err := temporalsdk_workflow.CreateSession(
+			w.withActOpts(ctx, cfg.Activities.Default),
+			&opts,
+		)
+		if err != nil {
+			return err
+		}
+
+		err = w.process(sessCtx, cfg, removeFilesName, ws, progress, result)
+		temporalsdk_workflow.CompleteSession(sessCtx)
+		if err == nil {
+			return nil
+		}
+
+		info := temporalsdk_workflow.GetSessionInfo(sessCtx)
+		if info == nil || info.SessionState != temporalsdk_workflow.SessionStateFailed {
+			return err
+		}
+
+		// Discard the progress of the failed session, the steps are run again.
+		temporalsdk_workflow.GetLogger(ctx).Warn("Session failed, processing the SIP in a new session.", "error", err)
+		*progress = Progress{}
+	}
+}
+
+// process runs the activities processing the SIP of ws, and sets the
+// references to their large results in result.
+func (w *PreprocessingWorkflow) process(
+	ctx temporalsdk_workflow.Context,
+	cfg config.Reloadable,
+	removeFilesName string,
+	ws *workspace,
+	progress *Progress,
+	result *PreprocessingWorkflowResult,
+) error {
+	if ws.staging {
+		if err := w.stage(ctx, cfg, ws, progress); err != nil {
+			return err
+		}
+	}
+	localPath := ws.workPath()
+
+	// Check the file timestamps as deposited.
+	v := temporalsdk_workflow.GetVersion(ctx, timestampCheckChangeID, temporalsdk_workflow.DefaultVersion, timestampCheckVersion)
+	if v >= timestampCheckVersion && cfg.TimestampCheck.Enabled {
+		if err := w.checkTimestamps(ctx, cfg, localPath, progress, result); err != nil {
+			return err
+		}
+	}
+
+	// Capture the file timestamps before they are changed by the activities.
+	v = temporalsdk_workflow.GetVersion(ctx, timestampsChangeID, temporalsdk_workflow.DefaultVersion, timestampsVersion)
+	timestamps := v >= timestampsVersion && cfg.Timestamps.Enabled
+	if timestamps {
+		if err := w.captureTimestamps(ctx, cfg, ws, progress); err != nil {
+			return err
+		}
+	}
+
+	// Preserve the macOS metadata of the AppleDouble files before they are
+	// removed.
+	v = temporalsdk_workflow.GetVersion(ctx, macMetadataChangeID, temporalsdk_workflow.DefaultVersion, macMetadataVersion)
+	if v >= macMetadataVersion && cfg.MacMetadata.Enabled {
+		if err := w.preserveMacMetadata(ctx, cfg, ws, progress); err != nil {
+			return err
+		}
+	}
 
 	// Remove unwanted files.
-	var removeFilesResult removefiles.ActivityResult
-	e = temporalsdk_workflow.ExecuteActivity(
-		withLocalActOpts(ctx),
-		removefiles.ActivityName,
-		&removefiles.ActivityParams{
-			Path:        localPath,
-			RemoveNames: []string{".DS_Store"},
+	progress.startStep(ctx, activities.RemoveFilesName)
+	var removeFilesResult activities.RemoveFilesResult
+	err := temporalsdk_workflow.ExecuteActivity(
+		w.withActOpts(ctx, cfg.Activities.RemoveFiles.Merge(cfg.Activities.Default)),
+		removeFilesName,
+		&activities.RemoveFilesParams{
+			Path:           localPath,
+			RemoveNames:    cfg.UnwantedFiles.Names,
+			RemovePatterns: cfg.UnwantedFiles.Patterns,
+			Journal:        ws.journal,
 		},
 	).Get(ctx, &removeFilesResult)
-	if e != nil {
-		return nil, e
+	if err != nil {
+		return err
+	}
+	progress.completeStep(ctx, removeFilesResult.Count)
+	result.Removed = removeFilesResult.RemovedRef
+	if removeFilesResult.Count > 0 {
+		progress.addFinding(validation.Finding{
+			Severity: validation.Info,
+			Code:     "unwanted-files-removed",
+			Message:  fmt.Sprintf("%d unwanted files removed.", removeFilesResult.Count),
+		})
 	}
 
 	// TODO: repackage MOMA SIP into a Bag.
 
-	return &PreprocessingWorkflowResult{RelativePath: params.RelativePath}, e
+	// Write the preprocessing report into the SIP.
+	v = temporalsdk_workflow.GetVersion(ctx, reportChangeID, temporalsdk_workflow.DefaultVersion, reportVersion)
+	if v >= reportVersion {
+		res, err := w.writeReport(ctx, cfg, ws, progress, removeFilesResult)
+		if err != nil {
+			return err
+		}
+		result.Inventory = res.InventoryRef
+		result.FileCount = res.FileCount
+		result.TotalBytes = res.TotalBytes
+	}
+
+	// Restore the modification times once the SIP is processed.
+	if timestamps {
+		if err := w.restoreTimestamps(ctx, cfg, localPath, progress); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// captureTimestamps writes the timestamps of the files of the SIP of ws into
+// its TimestampsFile. The timestamps of a staged copy are read from the SIP.
+func (w *PreprocessingWorkflow) captureTimestamps(
+	ctx temporalsdk_workflow.Context,
+	cfg config.Reloadable,
+	ws *workspace,
+	progress *Progress,
+) error {
+	params := &activities.CaptureTimestampsParams{Path: ws.workPath(), Journal: ws.journal}
+	if ws.stagePath != "" {
+		params.SourcePath = ws.path
+	}
+
+	progress.startStep(ctx, activities.CaptureTimestampsName)
+	var result activities.CaptureTimestampsResult
+	err := temporalsdk_workflow.ExecuteActivity(
+		w.withActOpts(ctx, cfg.Activities.Timestamps.Merge(cfg.Activities.Default)),
+		activities.CaptureTimestampsName,
+		params,
+	).Get(ctx, &result)
+	if err != nil {
+		return err
+	}
+	progress.completeStep(ctx, 0)
+
+	return nil
+}
+
+// restoreTimestamps restores the modification times of the files of the SIP
+// in localPath, and reports the files that couldn't be restored as warnings.
+func (w *PreprocessingWorkflow) restoreTimestamps(
+	ctx temporalsdk_workflow.Context,
+	cfg config.Reloadable,
+	localPath string,
+	progress *Progress,
+) error {
+	progress.startStep(ctx, activities.RestoreTimestampsName)
+	var result activities.RestoreTimestampsResult
+	err := temporalsdk_workflow.ExecuteActivity(
+		w.withActOpts(ctx, cfg.Activities.Timestamps.Merge(cfg.Activities.Default)),
+		activities.RestoreTimestampsName,
+		&activities.RestoreTimestampsParams{Path: localPath},
+	).Get(ctx, &result)
+	if err != nil {
+		return err
+	}
+	progress.completeStep(ctx, result.Restored)
+
+	if result.Restored > 0 {
+		progress.addFinding(validation.Finding{
+			Severity: validation.Info,
+			Code:     "timestamps-restored",
+			Message:  fmt.Sprintf("%d modification times restored.", result.Restored),
+		})
+	}
+	for _, f := range result.Failed {
+		progress.addFinding(validation.Finding{
+			Severity: validation.Warning,
+			Code:     "timestamp-not-restored",
+			Path:     f.Path,
+			Message:  fmt.Sprintf("Modification time not restored: %s.", f.Error),
+		})
+	}
+
+	return nil
+}
+
+// checkTimestamps reports a sample of the files of the SIP in localPath
+// modified out of the cfg bounds as warnings, and the number of the other
+// ones. The complete list is referenced by the result.
+func (w *PreprocessingWorkflow) checkTimestamps(
+	ctx temporalsdk_workflow.Context,
+	cfg config.Reloadable,
+	localPath string,
+	progress *Progress,
+	result *PreprocessingWorkflowResult,
+) error {
+	params := &activities.CheckTimestampsParams{
+		Path:    localPath,
+		MaxTime: temporalsdk_workflow.Now(ctx).UTC().Add(cfg.TimestampCheck.MaxFuture),
+	}
+	if d := cfg.TimestampCheck.MinDate; d != "" {
+		var err error
+		if params.MinTime, err = time.Parse(time.DateOnly, d); err != nil {
+			return temporal.NewNonRetryableError(err)
+		}
+	}
+
+	progress.startStep(ctx, activities.CheckTimestampsName)
+	var res activities.CheckTimestampsResult
+	err := temporalsdk_workflow.ExecuteActivity(
+		w.withActOpts(ctx, cfg.Activities.Timestamps.Merge(cfg.Activities.Default)),
+		activities.CheckTimestampsName,
+		params,
+	).Get(ctx, &res)
+	if err != nil {
+		return err
+	}
+	progress.completeStep(ctx, 0)
+	result.OutOfBounds = res.OutOfBoundsRef
+
+	// The results of the previous releases list all the files without their
+	// counts.
+	tooOld, inFuture := res.TooOldCount, res.InFutureCount
+	if tooOld == 0 {
+		tooOld = len(res.TooOld)
+	}
+	if inFuture == 0 {
+		inFuture = len(res.InFuture)
+	}
+	res.TooOld = res.TooOld[:min(len(res.TooOld), activities.TimestampsSampleSize)]
+	res.InFuture = res.InFuture[:min(len(res.InFuture), activities.TimestampsSampleSize)]
+
+	for _, f := range res.TooOld {
+		progress.addFinding(validation.Finding{
+			Severity: validation.Warning,
+			Code:     "timestamp-too-old",
+			Path:     f.Path,
+			Message: fmt.Sprintf(
+				"Modification time %s is before %s, the file may have been dated by a wrong clock.",
+				f.ModTime.Format(time.RFC3339), cfg.TimestampCheck.MinDate,
+			),
+		})
+	}
+	if n := tooOld - len(res.TooOld); n > 0 {
+		progress.addFinding(validation.Finding{
+			Severity: validation.Warning,
+			Code:     "timestamp-too-old",
+			Message: fmt.Sprintf(
+				"%d more files are modified before %s, they are listed in the timestamp check results.",
+				n, cfg.TimestampCheck.MinDate,
+			),
+		})
+	}
+	for _, f := range res.InFuture {
+		progress.addFinding(validation.Finding{
+			Severity: validation.Warning,
+			Code:     "timestamp-in-future",
+			Path:     f.Path,
+			Message: fmt.Sprintf(
+				"Modification time %s is in the future, the file may have been dated by a wrong clock.",
+				f.ModTime.Format(time.RFC3339),
+			),
+		})
+	}
+	if n := inFuture - len(res.InFuture); n > 0 {
+		progress.addFinding(validation.Finding{
+			Severity: validation.Warning,
+			Code:     "timestamp-in-future",
+			Message: fmt.Sprintf(
+				"%d more files are modified in the future, they are listed in the timestamp check results.",
+				n,
+			),
+		})
+	}
+
+	return nil
+}
+
+// preserveMacMetadata writes the macOS metadata of the files of the SIP of ws
+// into its sidecars.
+func (w *PreprocessingWorkflow) preserveMacMetadata(
+	ctx temporalsdk_workflow.Context,
+	cfg config.Reloadable,
+	ws *workspace,
+	progress *Progress,
+) error {
+	progress.startStep(ctx, activities.PreserveMacMetadataName)
+	var result activities.PreserveMacMetadataResult
+	err := temporalsdk_workflow.ExecuteActivity(
+		w.withActOpts(ctx, cfg.Activities.MacMetadata.Merge(cfg.Activities.Default)),
+		activities.PreserveMacMetadataName,
+		&activities.PreserveMacMetadataParams{Path: ws.workPath(), Journal: ws.journal},
+	).Get(ctx, &result)
+	if err != nil {
+		return err
+	}
+	progress.completeStep(ctx, result.Count)
+	if result.Count > 0 {
+		progress.addFinding(validation.Finding{
+			Severity: validation.Info,
+			Code:     "mac-metadata-preserved",
+			Message:  fmt.Sprintf("macOS metadata of %d files preserved in %s.", result.Count, activities.MacMetadataDir),
+		})
+	}
+
+	return nil
+}
+
+// writeReport writes the report of the steps completed into the SIP of ws, and
+// returns the activity result with the SIP inventory.
+func (w *PreprocessingWorkflow) writeReport(
+	ctx temporalsdk_workflow.Context,
+	cfg config.Reloadable,
+	ws *workspace,
+	progress *Progress,
+	removed activities.RemoveFilesResult,
+) (*activities.WriteReportResult, error) {
+	localPath := ws.workPath()
+
+	// The remove files results of the previous releases list the removed
+	// files, the current results reference them in the results store.
+	r := report.Report{
+		SIP:      filepath.Base(localPath),
+		Removed:  removed.Removed,
+		Findings: progress.Findings,
+	}
+	for _, step := range progress.Completed {
+		r.Steps = append(r.Steps, report.Step{
+			Name:      step.Name,
+			StartTime: step.StartTime,
+			Duration:  step.Duration,
+			FileCount: step.FileCount,
+		})
+	}
+
+	format := cfg.Report.Format
+	if format == "" {
+		format = report.HTML
+	}
+
+	progress.startStep(ctx, activities.WriteReportName)
+	var result activities.WriteReportResult
+	err := temporalsdk_workflow.ExecuteActivity(
+		w.withActOpts(ctx, cfg.Activities.WriteReport.Merge(cfg.Activities.Default)),
+		activities.WriteReportName,
+		&activities.WriteReportParams{
+			Path:       localPath,
+			Format:     format,
+			Report:     r,
+			RemovedRef: removed.RemovedRef,
+			Journal:    ws.journal,
+		},
+	).Get(ctx, &result)
+	if err != nil {
+		return nil, err
+	}
+	progress.completeStep(ctx, 1)
+
+	return &result, nil
+}
+
+// checkFindings returns an error with the findings as details if any of them
+// reaches the cfg blocking severity.
+func checkFindings(findings validation.Findings, cfg config.ValidationConfig) error {
+	threshold := validation.Error
+	if cfg.BlockingSeverity != "" {
+		var err error
+		if threshold, err = validation.ParseSeverity(cfg.BlockingSeverity); err != nil {
+			return temporal.NewNonRetryableError(err)
+		}
+	}
+
+	blocking := findings.Blocking(threshold)
+	if len(blocking) == 0 {
+		return nil
+	}
+
+	return temporalsdk_temporal.NewNonRetryableApplicationError(
+		fmt.Sprintf("validation failed: %d findings with %s or higher severity", len(blocking), threshold),
+		ErrTypeValidation,
+		nil,
+		findings,
+	)
+}
+
+// config returns the configuration of the execution and the name of the
+// remove files activity, according to the configChangeID version.
+func (w *PreprocessingWorkflow) config(ctx temporalsdk_workflow.Context) (config.Reloadable, string, error) {
+	v := temporalsdk_workflow.GetVersion(ctx, configChangeID, temporalsdk_workflow.DefaultVersion, configVersion)
+	if v == temporalsdk_workflow.DefaultVersion {
+		// The activities were attempted once, the other activity options use
+		// the workflow defaults.
+		return config.Reloadable{
+			UnwantedFiles: config.UnwantedFilesConfig{Names: []string{".DS_Store"}},
+			Activities: config.ActivitiesConfig{
+				Default: config.ActivityConfig{
+					RetryPolicy: config.RetryPolicyConfig{MaximumAttempts: 1},
+				},
+			},
+		}, activities.RemoveFilesLegacyName, nil
+	}
+
+	// Record the configuration in the workflow history, so the execution
+	// isn't affected by configuration changes and it can be replayed.
+	var cfg config.Reloadable
+	if err := temporalsdk_workflow.SideEffect(ctx, func(ctx temporalsdk_workflow.Context) interface{} {
+		return w.cfg.Config().Reloadable()
+	}).Get(&cfg); err != nil {
+		return config.Reloadable{}, "", err
+	}
+
+	return cfg, activities.RemoveFilesName, nil
+}
+
+// withActOpts sets the activity options from cfg, using the workflow defaults
+// for the empty values.
+func (w *PreprocessingWorkflow) withActOpts(
+	ctx temporalsdk_workflow.Context,
+	cfg config.ActivityConfig,
+) temporalsdk_workflow.Context {
+	return withActivityOptions(ctx, w.activityTaskQueue, cfg)
 }
 
-func withLocalActOpts(ctx temporalsdk_workflow.Context) temporalsdk_workflow.Context {
+// withActivityOptions sets the activity options from cfg, scheduling the
+// activities in taskQueue and using the workflow defaults for the empty
+// values.
+func withActivityOptions(
+	ctx temporalsdk_workflow.Context,
+	taskQueue string,
+	cfg config.ActivityConfig,
+) temporalsdk_workflow.Context {
+	if cfg.StartToCloseTimeout == 0 {
+		cfg.StartToCloseTimeout = config.DefaultStartToCloseTimeout
+	}
+	if cfg.RetryPolicy.MaximumAttempts == 0 {
+		// Zero would retry the activities indefinitely.
+		cfg.RetryPolicy.MaximumAttempts = config.DefaultMaximumAttempts
+	}
+
 	return temporalsdk_workflow.WithActivityOptions(ctx, temporalsdk_workflow.ActivityOptions{
-		ScheduleToCloseTimeout: 5 * time.Minute,
+		// An empty task queue schedules the activities in the workflow task
+		// queue.
+		TaskQueue:           taskQueue,
+		StartToCloseTimeout: cfg.StartToCloseTimeout,
+		HeartbeatTimeout:    cfg.HeartbeatTimeout,
 		RetryPolicy: &temporalsdk_temporal.RetryPolicy{
-			MaximumAttempts: 1,
+			InitialInterval:        cfg.RetryPolicy.InitialInterval,
+			BackoffCoefficient:     cfg.RetryPolicy.BackoffCoefficient,
+			MaximumInterval:        cfg.RetryPolicy.MaximumInterval,
+			MaximumAttempts:        int32(cfg.RetryPolicy.MaximumAttempts),
+			NonRetryableErrorTypes: cfg.RetryPolicy.NonRetryableErrorTypes,
 		},
 	})
 }
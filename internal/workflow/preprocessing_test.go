@@ -1,18 +1,28 @@
 package workflow_test
 
 import (
+	"context"
+	"fmt"
 	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 
-	remove "github.com/artefactual-sdps/remove-files-activity"
-	"github.com/artefactual-sdps/temporal-activities/removefiles"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	temporalsdk_activity "go.temporal.io/sdk/activity"
+	temporalsdk_converter "go.temporal.io/sdk/converter"
+	temporalsdk_temporal "go.temporal.io/sdk/temporal"
 	temporalsdk_testsuite "go.temporal.io/sdk/testsuite"
 	temporalsdk_worker "go.temporal.io/sdk/worker"
+	temporalsdk_workflow "go.temporal.io/sdk/workflow"
 
+	"github.com/artefactual-sdps/preprocessing-moma/internal/activities"
 	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/journal"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/report"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/results"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/validation"
 	"github.com/artefactual-sdps/preprocessing-moma/internal/workflow"
 )
 
@@ -31,18 +41,84 @@ func (s *PreprocessingTestSuite) SetupTest(cfg config.Configuration) {
 	s.env.SetWorkerOptions(temporalsdk_worker.Options{EnableSessionWorker: true})
 
 	// Register activities.
+	store := results.NewStore(s.T().TempDir())
+	journals := journal.NewStore(s.T().TempDir())
 	s.env.RegisterActivityWithOptions(
-		removefiles.NewActivity().Execute,
-		temporalsdk_activity.RegisterOptions{Name: remove.RemoveFilesName},
+		activities.NewRemoveFiles(store, journals).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.RemoveFilesName},
+	)
+	s.env.RegisterActivityWithOptions(
+		activities.NewCheckTimestamps(store).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.CheckTimestampsName},
+	)
+	s.env.RegisterActivityWithOptions(
+		activities.NewCaptureTimestamps(journals).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.CaptureTimestampsName},
+	)
+	s.env.RegisterActivityWithOptions(
+		activities.NewRestoreTimestamps().Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.RestoreTimestampsName},
+	)
+	s.env.RegisterActivityWithOptions(
+		activities.NewPreserveMacMetadata(journals).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.PreserveMacMetadataName},
+	)
+	s.env.RegisterActivityWithOptions(
+		activities.NewWriteReport(store, journals).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.WriteReportName},
+	)
+	stagingDir := s.T().TempDir()
+	s.env.RegisterActivityWithOptions(
+		activities.NewStage(stagingDir).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.StageName},
+	)
+	s.env.RegisterActivityWithOptions(
+		activities.NewPublishStage(stagingDir).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.PublishStageName},
+	)
+	s.env.RegisterActivityWithOptions(
+		activities.NewDiscardStage(stagingDir).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.DiscardStageName},
+	)
+	s.env.RegisterActivityWithOptions(
+		activities.NewRollbackJournal(journals).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.RollbackJournalName},
+	)
+	s.env.RegisterActivityWithOptions(
+		activities.NewDeleteJournal(journals).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.DeleteJournalName},
+	)
+
+	s.env.RegisterWorkflowWithOptions(
+		workflow.LockWorkflow,
+		temporalsdk_workflow.RegisterOptions{Name: workflow.LockWorkflowName},
 	)
 
-	s.workflow = workflow.NewPreprocessingWorkflow(sharedPath)
+	cfg.SharedPath = sharedPath
+	if cfg.UnwantedFiles.Names == nil {
+		cfg.UnwantedFiles.Names = []string{".DS_Store"}
+	}
+	s.workflow = workflow.NewPreprocessingWorkflow(cfg)
 }
 
 func (s *PreprocessingTestSuite) AfterTest(suiteName, testName string) {
 	s.env.AssertExpectations(s.T())
 }
 
+// recordSearchAttributes returns the search attributes values upserted by the
+// workflow, indexed by name.
+func (s *PreprocessingTestSuite) recordSearchAttributes() map[string]any {
+	attrs := map[string]any{}
+	s.env.OnUpsertTypedSearchAttributes(mock.Anything).Run(func(args mock.Arguments) {
+		sa := args.Get(0).(temporalsdk_temporal.SearchAttributes)
+		for k, v := range sa.GetUntypedValues() {
+			attrs[k.GetName()] = v
+		}
+	}).Return(nil)
+
+	return attrs
+}
+
 func TestPreprocessingWorkflow(t *testing.T) {
 	suite.Run(t, new(PreprocessingTestSuite))
 }
@@ -51,22 +127,49 @@ func (s *PreprocessingTestSuite) TestExecute() {
 	relPath := "transfer"
 	s.SetupTest(config.Configuration{})
 
+	removedRef := &results.Ref{Path: "wid/rid/removed-files.json", Digest: "sha256:a", Size: 14}
+	inventoryRef := &results.Ref{Path: "wid/rid/inventory.json", Digest: "sha256:b", Size: 128}
+
 	// Mock activities.
 	sessionCtx := mock.AnythingOfType("*context.timerCtx")
 	s.env.OnActivity(
-		removefiles.ActivityName,
+		activities.RemoveFilesName,
 		sessionCtx,
-		&removefiles.ActivityParams{
+		&activities.RemoveFilesParams{
 			Path:        filepath.Join(sharedPath, relPath),
 			RemoveNames: []string{".DS_Store"},
 		},
 	).Return(
-		&removefiles.ActivityResult{Count: 1}, nil,
+		&activities.RemoveFilesResult{Count: 1, RemovedRef: removedRef}, nil,
 	)
+	s.env.OnActivity(
+		activities.WriteReportName,
+		sessionCtx,
+		mock.MatchedBy(func(params *activities.WriteReportParams) bool {
+			return params.Path == filepath.Join(sharedPath, relPath) &&
+				params.Format == report.HTML &&
+				params.Report.SIP == relPath &&
+				len(params.Report.Steps) == 1 &&
+				reflect.DeepEqual(params.RemovedRef, removedRef) &&
+				len(params.Report.Findings) == 1
+		}),
+	).Return(
+		&activities.WriteReportResult{
+			Path:         "metadata/submissionDocumentation/preprocessing-report.html",
+			InventoryRef: inventoryRef,
+			FileCount:    3,
+			TotalBytes:   1024,
+		}, nil,
+	)
+	attrs := s.recordSearchAttributes()
 
 	s.env.ExecuteWorkflow(
 		s.workflow.Execute,
-		&workflow.PreprocessingWorkflowParams{RelativePath: relPath},
+		&workflow.PreprocessingWorkflowParams{
+			RelativePath: relPath,
+			TransferType: "standard",
+			Depositor:    "registrar",
+		},
 	)
 
 	s.True(s.env.IsWorkflowCompleted())
@@ -74,8 +177,787 @@ func (s *PreprocessingTestSuite) TestExecute() {
 	var result workflow.PreprocessingWorkflowResult
 	err := s.env.GetWorkflowResult(&result)
 	s.NoError(err)
-	s.Equal(
-		&result,
-		&workflow.PreprocessingWorkflowResult{RelativePath: relPath},
+	s.Equal(relPath, result.RelativePath)
+	s.Equal(config.Configuration{
+		UnwantedFiles: config.UnwantedFilesConfig{Names: []string{".DS_Store"}},
+	}.Reloadable().Version(), result.ConfigVersion)
+	s.Equal(removedRef, result.Removed)
+	s.Equal(inventoryRef, result.Inventory)
+	s.Equal(3, result.FileCount)
+	s.Equal(int64(1024), result.TotalBytes)
+	s.Equal(map[string]any{
+		"PreprocessingRelativePath": relPath,
+		"PreprocessingTransferType": "standard",
+		"PreprocessingDepositor":    "registrar",
+		"PreprocessingFileCount":    int64(3),
+		"PreprocessingTotalBytes":   int64(1024),
+		"PreprocessingOutcome":      workflow.OutcomeAccepted,
+	}, attrs)
+
+	value, err := s.env.QueryWorkflow(workflow.ProgressQuery)
+	s.NoError(err)
+
+	var progress workflow.Progress
+	s.NoError(value.Get(&progress))
+	s.Equal(result.ConfigVersion, progress.ConfigVersion)
+	s.Empty(progress.Step)
+	s.Len(progress.Completed, 2)
+	s.Equal(activities.RemoveFilesName, progress.Completed[0].Name)
+	s.Equal(activities.WriteReportName, progress.Completed[1].Name)
+	s.Equal(1, progress.Completed[0].FileCount)
+	s.Equal(2, progress.FileCount)
+	s.Equal(validation.Findings{{
+		Severity: validation.Info,
+		Code:     "unwanted-files-removed",
+		Message:  "1 unwanted files removed.",
+	}}, progress.Findings)
+	s.Equal(progress.Findings, result.Findings)
+}
+
+func (s *PreprocessingTestSuite) TestExecuteRunsActivitiesInSession() {
+	relPath := "transfer"
+	s.SetupTest(config.Configuration{})
+
+	var taskQueues []string
+	s.env.SetOnActivityStartedListener(
+		func(info *temporalsdk_activity.Info, ctx context.Context, args temporalsdk_converter.EncodedValues) {
+			taskQueues = append(taskQueues, info.TaskQueue)
+		},
 	)
+
+	sessionCtx := mock.AnythingOfType("*context.timerCtx")
+	s.env.OnActivity(activities.RemoveFilesName, sessionCtx, mock.Anything).Return(
+		&activities.RemoveFilesResult{}, nil,
+	)
+	s.env.OnActivity(activities.WriteReportName, sessionCtx, mock.Anything).Return(
+		&activities.WriteReportResult{}, nil,
+	)
+
+	s.env.ExecuteWorkflow(
+		s.workflow.Execute,
+		&workflow.PreprocessingWorkflowParams{RelativePath: relPath},
+	)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	// The session creation activity runs first, then the activities and the
+	// session completion run in the task queue of the session worker.
+	s.Len(taskQueues, 4)
+	s.Contains(taskQueues[1], "@")
+	s.Equal(taskQueues[1], taskQueues[2])
+	s.Equal(taskQueues[1], taskQueues[3])
+}
+
+func (s *PreprocessingTestSuite) TestExecuteRetriesTransientErrors() {
+	relPath := "transfer"
+	s.SetupTest(config.Configuration{
+		Activities: config.ActivitiesConfig{
+			Default: config.ActivityConfig{
+				RetryPolicy: config.RetryPolicyConfig{MaximumAttempts: 2},
+			},
+		},
+	})
+
+	// Mock activities.
+	sessionCtx := mock.AnythingOfType("*context.timerCtx")
+	params := &activities.RemoveFilesParams{
+		Path:        filepath.Join(sharedPath, relPath),
+		RemoveNames: []string{".DS_Store"},
+	}
+	s.env.OnActivity(activities.RemoveFilesName, sessionCtx, params).Return(
+		nil, temporalsdk_temporal.NewApplicationError("stale NFS file handle", activities.ErrTypeTransient),
+	).Once()
+	s.env.OnActivity(activities.RemoveFilesName, sessionCtx, params).Return(
+		&activities.RemoveFilesResult{Count: 1}, nil,
+	).Once()
+	s.env.OnActivity(activities.WriteReportName, sessionCtx, mock.Anything).Return(
+		&activities.WriteReportResult{}, nil,
+	)
+
+	s.env.ExecuteWorkflow(
+		s.workflow.Execute,
+		&workflow.PreprocessingWorkflowParams{RelativePath: relPath},
+	)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+}
+
+func (s *PreprocessingTestSuite) TestExecuteLegacyAttemptsOnce() {
+	relPath := "transfer"
+	s.SetupTest(config.Configuration{})
+	s.env.RegisterActivityWithOptions(
+		activities.NewRemoveFiles(results.NewStore(s.T().TempDir()), journal.NewStore(s.T().TempDir())).Execute,
+		temporalsdk_activity.RegisterOptions{Name: activities.RemoveFilesLegacyName},
+	)
+
+	// Run as an execution started before the configuration was recorded.
+	s.env.OnGetVersion("config", temporalsdk_workflow.DefaultVersion, 1).Return(
+		temporalsdk_workflow.DefaultVersion,
+	)
+
+	// Mock activities.
+	s.env.OnActivity(activities.RemoveFilesLegacyName, mock.Anything, mock.Anything).Return(
+		nil, temporalsdk_temporal.NewApplicationError("stale NFS file handle", activities.ErrTypeTransient),
+	).Once()
+
+	s.env.ExecuteWorkflow(
+		s.workflow.Execute,
+		&workflow.PreprocessingWorkflowParams{RelativePath: relPath},
+	)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.ErrorContains(s.env.GetWorkflowError(), "stale NFS file handle")
+}
+
+func (s *PreprocessingTestSuite) TestExecuteReview() {
+	relPath := "transfer"
+
+	for _, tc := range []struct {
+		name       string
+		decision   *workflow.ReviewDecision
+		wantStatus string
+		wantErr    string
+	}{
+		{
+			name:       "Approved",
+			decision:   &workflow.ReviewDecision{Approved: true, Reviewer: "registrar", Note: "Looks good."},
+			wantStatus: workflow.ReviewApproved,
+		},
+		{
+			name:       "Rejected",
+			decision:   &workflow.ReviewDecision{Reviewer: "registrar", Note: "Missing files."},
+			wantStatus: workflow.ReviewRejected,
+			wantErr:    "review rejected by registrar: Missing files.",
+		},
+		{
+			name:       "Timed out",
+			wantStatus: workflow.ReviewTimedOut,
+			wantErr:    "review timed-out",
+		},
+	} {
+		s.Run(tc.name, func() {
+			s.SetupTest(config.Configuration{
+				Review: config.ReviewConfig{Timeout: 24 * time.Hour},
+			})
+
+			s.env.OnActivity(
+				activities.RemoveFilesName,
+				mock.AnythingOfType("*context.timerCtx"),
+				mock.AnythingOfType("*activities.RemoveFilesParams"),
+			).Return(&activities.RemoveFilesResult{}, nil)
+			s.env.OnActivity(
+				activities.WriteReportName,
+				mock.AnythingOfType("*context.timerCtx"),
+				mock.AnythingOfType("*activities.WriteReportParams"),
+			).Return(&activities.WriteReportResult{}, nil)
+
+			attrs := s.recordSearchAttributes()
+			if tc.decision != nil {
+				s.env.RegisterDelayedCallback(func() {
+					s.env.SignalWorkflow(workflow.ReviewSignal, tc.decision)
+				}, time.Hour)
+			}
+
+			s.env.ExecuteWorkflow(
+				s.workflow.Execute,
+				&workflow.PreprocessingWorkflowParams{RelativePath: relPath, Review: true},
+			)
+			s.True(s.env.IsWorkflowCompleted())
+
+			if tc.wantErr != "" {
+				err := s.env.GetWorkflowError()
+				s.ErrorContains(err, tc.wantErr)
+
+				var appErr *temporalsdk_temporal.ApplicationError
+				s.ErrorAs(err, &appErr)
+				s.Equal(workflow.ErrTypeReviewRejected, appErr.Type())
+
+				var review workflow.ReviewResult
+				s.NoError(appErr.Details(&review))
+				s.Equal(tc.wantStatus, review.Status)
+
+				s.Equal(workflow.OutcomeRejected, attrs["PreprocessingOutcome"])
+				s.Equal(tc.wantErr, attrs["PreprocessingRejectionReason"])
+				return
+			}
+			s.Equal(workflow.OutcomeAccepted, attrs["PreprocessingOutcome"])
+
+			var result workflow.PreprocessingWorkflowResult
+			s.NoError(s.env.GetWorkflowResult(&result))
+			s.Equal(tc.wantStatus, result.Review.Status)
+			s.Equal(tc.decision.Reviewer, result.Review.Reviewer)
+			s.Equal(tc.decision.Note, result.Review.Note)
+		})
+	}
+}
+
+func (s *PreprocessingTestSuite) TestExecuteReviewLegacy() {
+	relPath := "transfer"
+	s.SetupTest(config.Configuration{})
+
+	// Run as an execution started before the review gate.
+	s.env.OnGetVersion("review", temporalsdk_workflow.DefaultVersion, 1).Return(
+		temporalsdk_workflow.DefaultVersion,
+	)
+
+	s.env.OnActivity(
+		activities.RemoveFilesName,
+		mock.AnythingOfType("*context.timerCtx"),
+		mock.AnythingOfType("*activities.RemoveFilesParams"),
+	).Return(&activities.RemoveFilesResult{}, nil)
+	s.env.OnActivity(
+		activities.WriteReportName,
+		mock.AnythingOfType("*context.timerCtx"),
+		mock.AnythingOfType("*activities.WriteReportParams"),
+	).Return(&activities.WriteReportResult{}, nil)
+
+	s.env.ExecuteWorkflow(
+		s.workflow.Execute,
+		&workflow.PreprocessingWorkflowParams{RelativePath: relPath, Review: true},
+	)
+	s.True(s.env.IsWorkflowCompleted())
+
+	var result workflow.PreprocessingWorkflowResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.Nil(result.Review)
+}
+
+func (s *PreprocessingTestSuite) TestExecuteBlockingFindings() {
+	relPath := "transfer"
+	s.SetupTest(config.Configuration{
+		Validation: config.ValidationConfig{BlockingSeverity: "info"},
+	})
+
+	s.env.OnActivity(
+		activities.RemoveFilesName,
+		mock.AnythingOfType("*context.timerCtx"),
+		mock.AnythingOfType("*activities.RemoveFilesParams"),
+	).Return(&activities.RemoveFilesResult{Count: 2}, nil)
+	s.env.OnActivity(
+		activities.WriteReportName,
+		mock.AnythingOfType("*context.timerCtx"),
+		mock.AnythingOfType("*activities.WriteReportParams"),
+	).Return(&activities.WriteReportResult{}, nil)
+	attrs := s.recordSearchAttributes()
+
+	s.env.ExecuteWorkflow(
+		s.workflow.Execute,
+		&workflow.PreprocessingWorkflowParams{RelativePath: relPath},
+	)
+	s.True(s.env.IsWorkflowCompleted())
+
+	err := s.env.GetWorkflowError()
+	s.ErrorContains(err, "validation failed: 1 findings with info or higher severity")
+	s.Equal(workflow.OutcomeInvalid, attrs["PreprocessingOutcome"])
+	s.Equal("validation failed: 1 findings with info or higher severity", attrs["PreprocessingRejectionReason"])
+
+	var appErr *temporalsdk_temporal.ApplicationError
+	s.ErrorAs(err, &appErr)
+	s.Equal(workflow.ErrTypeValidation, appErr.Type())
+
+	var findings validation.Findings
+	s.NoError(appErr.Details(&findings))
+	s.Equal(validation.Findings{{
+		Severity: validation.Info,
+		Code:     "unwanted-files-removed",
+		Message:  "2 unwanted files removed.",
+	}}, findings)
+}
+
+func (s *PreprocessingTestSuite) TestExecuteLocksRelativePath() {
+	relPath := "transfer"
+
+	for _, tc := range []struct {
+		name     string
+		lock     config.LockConfig
+		wantErr  string
+		wantWait time.Duration
+	}{
+		{
+			name:    "Fails when the relative path is locked",
+			lock:    config.LockConfig{Mode: config.LockModeFail},
+			wantErr: "transfer is being processed by another workflow execution",
+		},
+		{
+			name:     "Waits until the relative path is released",
+			lock:     config.LockConfig{Mode: config.LockModeWait, PollInterval: time.Minute},
+			wantWait: time.Hour,
+		},
+		{
+			name:    "Fails when the lock timeout expires",
+			lock:    config.LockConfig{Mode: config.LockModeWait, Timeout: 30 * time.Minute, PollInterval: time.Minute},
+			wantErr: "transfer is being processed by another workflow execution",
+		},
+	} {
+		s.Run(tc.name, func() {
+			// The first execution holds the lock until its review times out.
+			s.SetupTest(config.Configuration{
+				Review: config.ReviewConfig{Timeout: time.Hour},
+				Lock:   tc.lock,
+			})
+			s.env.RegisterWorkflowWithOptions(
+				s.workflow.Execute,
+				temporalsdk_workflow.RegisterOptions{Name: "preprocessing"},
+			)
+			s.env.OnActivity(activities.RemoveFilesName, mock.Anything, mock.Anything).Return(
+				&activities.RemoveFilesResult{}, nil,
+			)
+			s.env.OnActivity(activities.WriteReportName, mock.Anything, mock.Anything).Return(
+				&activities.WriteReportResult{}, nil,
+			)
+
+			// concurrent runs two executions processing relPath, and returns
+			// how long the second one took.
+			concurrent := func(ctx temporalsdk_workflow.Context) (time.Duration, error) {
+				first := temporalsdk_workflow.ExecuteChildWorkflow(
+					temporalsdk_workflow.WithChildOptions(ctx, temporalsdk_workflow.ChildWorkflowOptions{WorkflowID: "first"}),
+					"preprocessing",
+					&workflow.PreprocessingWorkflowParams{RelativePath: relPath, Review: true},
+				)
+				if err := first.GetChildWorkflowExecution().Get(ctx, nil); err != nil {
+					return 0, err
+				}
+				if err := temporalsdk_workflow.Sleep(ctx, time.Second); err != nil {
+					return 0, err
+				}
+
+				start := temporalsdk_workflow.Now(ctx)
+				err := temporalsdk_workflow.ExecuteChildWorkflow(
+					temporalsdk_workflow.WithChildOptions(ctx, temporalsdk_workflow.ChildWorkflowOptions{WorkflowID: "second"}),
+					"preprocessing",
+					&workflow.PreprocessingWorkflowParams{RelativePath: relPath},
+				).Get(ctx, nil)
+				_ = first.Get(ctx, nil)
+
+				return temporalsdk_workflow.Now(ctx).Sub(start), err
+			}
+			s.env.ExecuteWorkflow(concurrent)
+			s.True(s.env.IsWorkflowCompleted())
+
+			if tc.wantErr != "" {
+				err := s.env.GetWorkflowError()
+				s.ErrorContains(err, tc.wantErr)
+
+				var appErr *temporalsdk_temporal.ApplicationError
+				s.ErrorAs(err, &appErr)
+				s.Equal(workflow.ErrTypeLocked, appErr.Type())
+				return
+			}
+
+			var wait time.Duration
+			s.NoError(s.env.GetWorkflowResult(&wait))
+			s.GreaterOrEqual(wait, tc.wantWait-time.Second)
+		})
+	}
+}
+
+func (s *PreprocessingTestSuite) TestExecuteStaging() {
+	relPath := "transfer"
+	stagePath := "/shared/path/.staging/wid/rid/staged/transfer"
+
+	for _, tc := range []struct {
+		name        string
+		reportErr   error
+		wantPublish bool
+		wantDiscard bool
+	}{
+		{
+			name:        "Publishes the staged copy",
+			wantPublish: true,
+		},
+		{
+			name:        "Discards the staged copy of a failed execution",
+			reportErr:   temporalsdk_temporal.NewNonRetryableApplicationError("disk full", "", nil),
+			wantDiscard: true,
+		},
+	} {
+		s.Run(tc.name, func() {
+			s.SetupTest(config.Configuration{
+				Staging: config.StagingConfig{Enabled: true, Hardlinks: true},
+			})
+
+			s.env.OnActivity(
+				activities.StageName,
+				mock.Anything,
+				&activities.StageParams{Path: filepath.Join(sharedPath, relPath), Hardlinks: true},
+			).Return(&activities.StageResult{Path: stagePath, FileCount: 2}, nil)
+			s.env.OnActivity(
+				activities.RemoveFilesName,
+				mock.Anything,
+				mock.MatchedBy(func(params *activities.RemoveFilesParams) bool {
+					return params.Path == stagePath
+				}),
+			).Return(&activities.RemoveFilesResult{}, nil)
+			s.env.OnActivity(
+				activities.WriteReportName,
+				mock.Anything,
+				mock.MatchedBy(func(params *activities.WriteReportParams) bool {
+					return params.Path == stagePath && params.Report.SIP == relPath
+				}),
+			).Return(&activities.WriteReportResult{}, tc.reportErr)
+			if tc.wantPublish {
+				s.env.OnActivity(
+					activities.PublishStageName,
+					mock.Anything,
+					&activities.PublishStageParams{Path: filepath.Join(sharedPath, relPath)},
+				).Return(&activities.PublishStageResult{}, nil)
+			}
+			if tc.wantDiscard {
+				s.env.OnActivity(
+					activities.DiscardStageName,
+					mock.Anything,
+					&activities.DiscardStageParams{Path: filepath.Join(sharedPath, relPath)},
+				).Return(&activities.DiscardStageResult{}, nil)
+			}
+
+			s.env.ExecuteWorkflow(
+				s.workflow.Execute,
+				&workflow.PreprocessingWorkflowParams{RelativePath: relPath},
+			)
+			s.True(s.env.IsWorkflowCompleted())
+			s.env.AssertExpectations(s.T())
+
+			if tc.reportErr != nil {
+				s.ErrorContains(s.env.GetWorkflowError(), "disk full")
+				return
+			}
+			s.NoError(s.env.GetWorkflowError())
+
+			value, err := s.env.QueryWorkflow(workflow.ProgressQuery)
+			s.NoError(err)
+
+			var progress workflow.Progress
+			s.NoError(value.Get(&progress))
+			s.Len(progress.Completed, 4)
+			s.Equal(activities.StageName, progress.Completed[0].Name)
+			s.Equal(activities.PublishStageName, progress.Completed[3].Name)
+		})
+	}
+}
+
+func (s *PreprocessingTestSuite) TestExecuteJournal() {
+	relPath := "transfer"
+	path := filepath.Join(sharedPath, relPath)
+
+	for _, tc := range []struct {
+		name         string
+		reportErr    error
+		cancel       bool
+		wantRollback bool
+	}{
+		{
+			name: "Keeps the journal of a successful execution",
+		},
+		{
+			name:         "Rolls back the journal of a failed execution",
+			reportErr:    temporalsdk_temporal.NewNonRetryableApplicationError("disk full", "", nil),
+			wantRollback: true,
+		},
+		{
+			name:         "Rolls back the journal of a canceled execution",
+			cancel:       true,
+			wantRollback: true,
+		},
+	} {
+		s.Run(tc.name, func() {
+			s.SetupTest(config.Configuration{
+				Review:  config.ReviewConfig{Timeout: 24 * time.Hour},
+				Journal: config.JournalConfig{Enabled: true},
+			})
+
+			s.env.OnActivity(
+				activities.RemoveFilesName,
+				mock.Anything,
+				&activities.RemoveFilesParams{Path: path, RemoveNames: []string{".DS_Store"}, Journal: true},
+			).Return(&activities.RemoveFilesResult{}, nil)
+			s.env.OnActivity(
+				activities.WriteReportName,
+				mock.Anything,
+				mock.MatchedBy(func(params *activities.WriteReportParams) bool {
+					return params.Path == path && params.Journal
+				}),
+			).Return(&activities.WriteReportResult{}, tc.reportErr)
+			if tc.wantRollback {
+				s.env.OnActivity(
+					activities.RollbackJournalName,
+					mock.Anything,
+					&activities.RollbackJournalParams{},
+				).Return(&activities.RollbackJournalResult{Count: 2}, nil)
+			}
+			if tc.cancel {
+				s.env.RegisterDelayedCallback(s.env.CancelWorkflow, time.Hour)
+			}
+
+			s.env.ExecuteWorkflow(
+				s.workflow.Execute,
+				&workflow.PreprocessingWorkflowParams{RelativePath: relPath, Review: tc.cancel},
+			)
+			s.True(s.env.IsWorkflowCompleted())
+			s.env.AssertExpectations(s.T())
+
+			switch {
+			case tc.reportErr != nil:
+				s.ErrorContains(s.env.GetWorkflowError(), "disk full")
+			case tc.cancel:
+				s.Error(s.env.GetWorkflowError())
+			default:
+				s.NoError(s.env.GetWorkflowError())
+			}
+		})
+	}
+}
+
+func (s *PreprocessingTestSuite) TestExecuteMacMetadata() {
+	relPath := "transfer"
+	path := filepath.Join(sharedPath, relPath)
+	s.SetupTest(config.Configuration{
+		MacMetadata:   config.MacMetadataConfig{Enabled: true},
+		UnwantedFiles: config.UnwantedFilesConfig{Patterns: []string{`^\._`}},
+	})
+
+	s.env.OnActivity(
+		activities.PreserveMacMetadataName,
+		mock.Anything,
+		&activities.PreserveMacMetadataParams{Path: path},
+	).Return(&activities.PreserveMacMetadataResult{Count: 2, Sources: 3}, nil)
+	s.env.OnActivity(
+		activities.RemoveFilesName,
+		mock.Anything,
+		&activities.RemoveFilesParams{
+			Path:           path,
+			RemoveNames:    []string{".DS_Store"},
+			RemovePatterns: []string{`^\._`},
+		},
+	).Return(&activities.RemoveFilesResult{Count: 2}, nil)
+	s.env.OnActivity(
+		activities.WriteReportName,
+		mock.Anything,
+		mock.Anything,
+	).Return(&activities.WriteReportResult{}, nil)
+
+	s.env.ExecuteWorkflow(
+		s.workflow.Execute,
+		&workflow.PreprocessingWorkflowParams{RelativePath: relPath},
+	)
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	var result workflow.PreprocessingWorkflowResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.Equal("mac-metadata-preserved", result.Findings[0].Code)
+	s.Equal("macOS metadata of 2 files preserved in metadata/macos.", result.Findings[0].Message)
+
+	value, err := s.env.QueryWorkflow(workflow.ProgressQuery)
+	s.NoError(err)
+	var progress workflow.Progress
+	s.NoError(value.Get(&progress))
+	s.Len(progress.Completed, 3)
+	s.Equal(activities.PreserveMacMetadataName, progress.Completed[0].Name)
+	s.Equal(2, progress.Completed[0].FileCount)
+	s.Equal(activities.RemoveFilesName, progress.Completed[1].Name)
+}
+
+func (s *PreprocessingTestSuite) TestExecuteTimestamps() {
+	relPath := "transfer"
+	stagePath := "/shared/path/.staging/wid/rid/staged/transfer"
+	s.SetupTest(config.Configuration{
+		Staging:    config.StagingConfig{Enabled: true},
+		Timestamps: config.TimestampsConfig{Enabled: true},
+	})
+
+	s.env.OnActivity(
+		activities.StageName,
+		mock.Anything,
+		mock.Anything,
+	).Return(&activities.StageResult{Path: stagePath}, nil)
+	s.env.OnActivity(
+		activities.CaptureTimestampsName,
+		mock.Anything,
+		&activities.CaptureTimestampsParams{Path: stagePath, SourcePath: filepath.Join(sharedPath, relPath)},
+	).Return(&activities.CaptureTimestampsResult{Count: 4}, nil)
+	s.env.OnActivity(activities.RemoveFilesName, mock.Anything, mock.Anything).Return(
+		&activities.RemoveFilesResult{}, nil,
+	)
+	s.env.OnActivity(activities.WriteReportName, mock.Anything, mock.Anything).Return(
+		&activities.WriteReportResult{}, nil,
+	)
+	s.env.OnActivity(
+		activities.RestoreTimestampsName,
+		mock.Anything,
+		&activities.RestoreTimestampsParams{Path: stagePath},
+	).Return(&activities.RestoreTimestampsResult{
+		Restored:  2,
+		Unchanged: 1,
+		Failed:    []activities.TimestampError{{Path: "objects/a.mov", Error: "permission denied"}},
+	}, nil)
+	s.env.OnActivity(activities.PublishStageName, mock.Anything, mock.Anything).Return(
+		&activities.PublishStageResult{}, nil,
+	)
+
+	s.env.ExecuteWorkflow(
+		s.workflow.Execute,
+		&workflow.PreprocessingWorkflowParams{RelativePath: relPath},
+	)
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	var result workflow.PreprocessingWorkflowResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.Equal(validation.Findings{
+		{Severity: validation.Info, Code: "timestamps-restored", Message: "2 modification times restored."},
+		{
+			Severity: validation.Warning,
+			Code:     "timestamp-not-restored",
+			Path:     "objects/a.mov",
+			Message:  "Modification time not restored: permission denied.",
+		},
+	}, result.Findings)
+
+	value, err := s.env.QueryWorkflow(workflow.ProgressQuery)
+	s.NoError(err)
+	var progress workflow.Progress
+	s.NoError(value.Get(&progress))
+	var steps []string
+	for _, step := range progress.Completed {
+		steps = append(steps, step.Name)
+	}
+	s.Equal([]string{
+		activities.StageName,
+		activities.CaptureTimestampsName,
+		activities.RemoveFilesName,
+		activities.WriteReportName,
+		activities.RestoreTimestampsName,
+		activities.PublishStageName,
+	}, steps)
+}
+
+func (s *PreprocessingTestSuite) TestExecuteTimestampCheck() {
+	relPath := "transfer"
+	sessionCtx := mock.AnythingOfType("*context.timerCtx")
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	epoch := time.Unix(0, 0).UTC()
+	later := time.Date(2037, 1, 1, 0, 0, 0, 0, time.UTC)
+	oobRef := &results.Ref{Path: "wid/rid/timestamps-out-of-bounds.json", Digest: "sha256:c", Size: 256}
+
+	tooOld := func(n int) []activities.FileTimestamps {
+		var files []activities.FileTimestamps
+		for i := 0; i < n; i++ {
+			files = append(files, activities.FileTimestamps{Path: fmt.Sprintf("objects/%02d.mov", i), ModTime: epoch})
+		}
+		return files
+	}
+	tooOldFinding := func(path string) validation.Finding {
+		return validation.Finding{
+			Severity: validation.Warning,
+			Code:     "timestamp-too-old",
+			Path:     path,
+			Message:  "Modification time 1970-01-01T00:00:00Z is before 1980-01-02, the file may have been dated by a wrong clock.",
+		}
+	}
+	sampleFindings := func(more int) validation.Findings {
+		var findings validation.Findings
+		for _, f := range tooOld(activities.TimestampsSampleSize) {
+			findings = append(findings, tooOldFinding(f.Path))
+		}
+		return append(findings, validation.Finding{
+			Severity: validation.Warning,
+			Code:     "timestamp-too-old",
+			Message:  fmt.Sprintf("%d more files are modified before 1980-01-02, they are listed in the timestamp check results.", more),
+		})
+	}
+
+	for _, tc := range []struct {
+		name         string
+		result       *activities.CheckTimestampsResult
+		wantFindings validation.Findings
+		wantRef      *results.Ref
+	}{
+		{
+			name: "Reports the files out of the bounds",
+			result: &activities.CheckTimestampsResult{
+				Checked:        3,
+				TooOld:         []activities.FileTimestamps{{Path: "objects/a.mov", ModTime: epoch}},
+				InFuture:       []activities.FileTimestamps{{Path: "objects/c.mov", ModTime: later}},
+				TooOldCount:    1,
+				InFutureCount:  1,
+				OutOfBoundsRef: oobRef,
+			},
+			wantFindings: validation.Findings{
+				tooOldFinding("objects/a.mov"),
+				{
+					Severity: validation.Warning,
+					Code:     "timestamp-in-future",
+					Path:     "objects/c.mov",
+					Message:  "Modification time 2037-01-01T00:00:00Z is in the future, the file may have been dated by a wrong clock.",
+				},
+			},
+			wantRef: oobRef,
+		},
+		{
+			name: "Reports a sample of the files out of the bounds",
+			result: &activities.CheckTimestampsResult{
+				Checked:        40,
+				TooOld:         tooOld(activities.TimestampsSampleSize),
+				TooOldCount:    25,
+				OutOfBoundsRef: oobRef,
+			},
+			wantFindings: sampleFindings(15),
+			wantRef:      oobRef,
+		},
+		{
+			name: "Reports a sample of the files listed by a previous release",
+			result: &activities.CheckTimestampsResult{
+				Checked: 40,
+				TooOld:  tooOld(activities.TimestampsSampleSize + 2),
+			},
+			wantFindings: sampleFindings(2),
+		},
+	} {
+		s.Run(tc.name, func() {
+			s.SetupTest(config.Configuration{
+				TimestampCheck: config.TimestampCheckConfig{
+					Enabled:   true,
+					MinDate:   "1980-01-02",
+					MaxFuture: time.Hour,
+				},
+			})
+			s.env.SetStartTime(start)
+
+			s.env.OnActivity(
+				activities.CheckTimestampsName,
+				sessionCtx,
+				&activities.CheckTimestampsParams{
+					Path:    filepath.Join(sharedPath, relPath),
+					MinTime: time.Date(1980, 1, 2, 0, 0, 0, 0, time.UTC),
+					MaxTime: start.Add(time.Hour),
+				},
+			).Return(tc.result, nil)
+			s.env.OnActivity(activities.RemoveFilesName, sessionCtx, mock.Anything).Return(
+				&activities.RemoveFilesResult{}, nil,
+			)
+			s.env.OnActivity(activities.WriteReportName, sessionCtx, mock.Anything).Return(
+				&activities.WriteReportResult{}, nil,
+			)
+
+			s.env.ExecuteWorkflow(
+				s.workflow.Execute,
+				&workflow.PreprocessingWorkflowParams{RelativePath: relPath},
+			)
+			s.True(s.env.IsWorkflowCompleted())
+			s.NoError(s.env.GetWorkflowError())
+
+			var result workflow.PreprocessingWorkflowResult
+			s.NoError(s.env.GetWorkflowResult(&result))
+			s.Equal(tc.wantFindings, result.Findings)
+			s.Equal(tc.wantRef, result.OutOfBounds)
+
+			value, err := s.env.QueryWorkflow(workflow.ProgressQuery)
+			s.NoError(err)
+			var progress workflow.Progress
+			s.NoError(value.Get(&progress))
+			s.Equal(tc.wantFindings, progress.Findings)
+		})
+	}
 }
@@ -0,0 +1,76 @@
+package workflow
+
+import (
+	"time"
+
+	temporalsdk_workflow "go.temporal.io/sdk/workflow"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/validation"
+)
+
+// ProgressQuery is the name of the query returning the Progress of a
+// preprocessing workflow execution.
+const ProgressQuery = "progress"
+
+// Progress is the state of a preprocessing workflow execution.
+type Progress struct {
+	// ConfigVersion is the version of the configuration applied by the
+	// execution, see config.Reloadable.Version.
+	ConfigVersion string `json:",omitempty"`
+
+	// Step is the name of the running step, empty when no step is running.
+	Step string `json:",omitempty"`
+
+	// StepStartTime is the start time of the running step.
+	StepStartTime *time.Time `json:",omitempty"`
+
+	// Completed lists the completed steps, in execution order.
+	Completed []StepProgress
+
+	// FileCount is the number of files modified (e.g. removed) by the
+	// completed steps.
+	FileCount int
+
+	// Findings lists the findings of the completed checks.
+	Findings validation.Findings
+}
+
+// StepProgress describes a completed workflow step.
+type StepProgress struct {
+	Name      string
+	StartTime time.Time
+	Duration  time.Duration
+
+	// FileCount is the number of files modified (e.g. removed) by the step.
+	FileCount int
+}
+
+// startStep records the start of the name step.
+func (p *Progress) startStep(ctx temporalsdk_workflow.Context, name string) {
+	t := temporalsdk_workflow.Now(ctx)
+	p.Step = name
+	p.StepStartTime = &t
+}
+
+// completeStep records the completion of the running step, which modified
+// fileCount files.
+func (p *Progress) completeStep(ctx temporalsdk_workflow.Context, fileCount int) {
+	if p.Step == "" {
+		return
+	}
+
+	p.Completed = append(p.Completed, StepProgress{
+		Name:      p.Step,
+		StartTime: *p.StepStartTime,
+		Duration:  temporalsdk_workflow.Now(ctx).Sub(*p.StepStartTime),
+		FileCount: fileCount,
+	})
+	p.FileCount += fileCount
+	p.Step = ""
+	p.StepStartTime = nil
+}
+
+// addFinding records the f finding.
+func (p *Progress) addFinding(f validation.Finding) {
+	p.Findings = append(p.Findings, f)
+}
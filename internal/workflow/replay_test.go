@@ -0,0 +1,44 @@
+package workflow_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr/testr"
+	"go.artefactual.dev/tools/temporal"
+	temporalsdk_worker "go.temporal.io/sdk/worker"
+	temporalsdk_workflow "go.temporal.io/sdk/workflow"
+	"gotest.tools/v3/assert"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/workflow"
+)
+
+// TestReplay replays the workflow histories recorded by previous releases, it
+// fails when a change of PreprocessingWorkflow isn't deterministic.
+func TestReplay(t *testing.T) {
+	t.Parallel()
+
+	histories, err := filepath.Glob(filepath.Join("testdata", "histories", "*.json"))
+	assert.NilError(t, err)
+	assert.Assert(t, len(histories) > 0, "no workflow histories found")
+
+	for _, history := range histories {
+		history := history
+		t.Run(filepath.Base(history), func(t *testing.T) {
+			t.Parallel()
+
+			replayer := temporalsdk_worker.NewWorkflowReplayer()
+			replayer.RegisterWorkflowWithOptions(
+				workflow.NewPreprocessingWorkflow(config.Configuration{}).Execute,
+				temporalsdk_workflow.RegisterOptions{Name: "preprocessing"},
+			)
+
+			err := replayer.ReplayWorkflowHistoryFromJSONFile(
+				temporal.Logger(testr.New(t)),
+				history,
+			)
+			assert.NilError(t, err)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package workflow
+
+import (
+	"fmt"
+	"time"
+
+	temporalsdk_temporal "go.temporal.io/sdk/temporal"
+	temporalsdk_workflow "go.temporal.io/sdk/workflow"
+)
+
+const (
+	// ReviewSignal is the name of the signal sending the ReviewDecision of a
+	// SIP to a workflow waiting for review.
+	ReviewSignal = "review"
+
+	// ErrTypeReviewRejected is the type of the error returned when the SIP is
+	// rejected by the reviewer or the review times out.
+	ErrTypeReviewRejected = "ReviewRejectedError"
+
+	// reviewStep is the name of the review step in the workflow Progress.
+	reviewStep = "review"
+)
+
+// ReviewDecision is the payload of the ReviewSignal.
+type ReviewDecision struct {
+	Approved bool
+	Reviewer string
+	Note     string
+}
+
+// Review status values.
+const (
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+	ReviewTimedOut = "timed-out"
+)
+
+// ReviewResult records the outcome of the review of a SIP.
+type ReviewResult struct {
+	// Status is one of ReviewApproved, ReviewRejected or ReviewTimedOut.
+	Status   string
+	Reviewer string `json:",omitempty"`
+	Note     string `json:",omitempty"`
+	Time     time.Time
+}
+
+// review waits for the ReviewSignal, up to timeout if it's not zero, and
+// returns the review result.
+func review(ctx temporalsdk_workflow.Context, timeout time.Duration) *ReviewResult {
+	logger := temporalsdk_workflow.GetLogger(ctx)
+	logger.Info("Waiting for review.", "timeout", timeout)
+
+	var decision ReviewDecision
+	ch := temporalsdk_workflow.GetSignalChannel(ctx, ReviewSignal)
+	if timeout > 0 {
+		if ok, _ := ch.ReceiveWithTimeout(ctx, timeout, &decision); !ok {
+			return &ReviewResult{Status: ReviewTimedOut, Time: temporalsdk_workflow.Now(ctx)}
+		}
+	} else {
+		ch.Receive(ctx, &decision)
+	}
+
+	r := &ReviewResult{
+		Status:   ReviewRejected,
+		Reviewer: decision.Reviewer,
+		Note:     decision.Note,
+		Time:     temporalsdk_workflow.Now(ctx),
+	}
+	if decision.Approved {
+		r.Status = ReviewApproved
+	}
+
+	return r
+}
+
+// reviewError returns the error of a SIP not approved, with r as details.
+func reviewError(r *ReviewResult) error {
+	msg := fmt.Sprintf("review %s", r.Status)
+	if r.Reviewer != "" {
+		msg = fmt.Sprintf("%s by %s", msg, r.Reviewer)
+	}
+	if r.Note != "" {
+		msg = fmt.Sprintf("%s: %s", msg, r.Note)
+	}
+
+	return temporalsdk_temporal.NewNonRetryableApplicationError(msg, ErrTypeReviewRejected, nil, r)
+}
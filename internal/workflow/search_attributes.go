@@ -0,0 +1,115 @@
+package workflow
+
+import (
+	"errors"
+
+	temporalsdk_temporal "go.temporal.io/sdk/temporal"
+	temporalsdk_workflow "go.temporal.io/sdk/workflow"
+)
+
+// Search attributes upserted by the workflow, so the executions can be found
+// with visibility queries, e.g.:
+//
+//	PreprocessingRelativePath = "transfer" AND PreprocessingOutcome = "rejected"
+//
+// They must be registered in the Temporal namespace, see the bootstrap command.
+var (
+	RelativePathAttr    = temporalsdk_temporal.NewSearchAttributeKeyKeyword("PreprocessingRelativePath")
+	TransferTypeAttr    = temporalsdk_temporal.NewSearchAttributeKeyKeyword("PreprocessingTransferType")
+	DepositorAttr       = temporalsdk_temporal.NewSearchAttributeKeyKeyword("PreprocessingDepositor")
+	FileCountAttr       = temporalsdk_temporal.NewSearchAttributeKeyInt64("PreprocessingFileCount")
+	TotalBytesAttr      = temporalsdk_temporal.NewSearchAttributeKeyInt64("PreprocessingTotalBytes")
+	OutcomeAttr         = temporalsdk_temporal.NewSearchAttributeKeyKeyword("PreprocessingOutcome")
+	RejectionReasonAttr = temporalsdk_temporal.NewSearchAttributeKeyString("PreprocessingRejectionReason")
+)
+
+// SearchAttributes lists the search attributes upserted by the workflow.
+var SearchAttributes = []temporalsdk_temporal.SearchAttributeKey{
+	RelativePathAttr,
+	TransferTypeAttr,
+	DepositorAttr,
+	FileCountAttr,
+	TotalBytesAttr,
+	OutcomeAttr,
+	RejectionReasonAttr,
+}
+
+// Outcomes of the preprocessing workflow, recorded in the OutcomeAttr search
+// attribute.
+const (
+	// OutcomeAccepted is the outcome of the completed executions.
+	OutcomeAccepted = "accepted"
+
+	// OutcomeRejected is the outcome of the executions whose SIP was rejected
+	// by the reviewer, or not reviewed in time.
+	OutcomeRejected = "rejected"
+
+	// OutcomeInvalid is the outcome of the executions whose SIP has findings
+	// reaching the blocking severity.
+	OutcomeInvalid = "invalid"
+
+	// OutcomeLocked is the outcome of the executions whose relative path was
+	// being processed by another execution.
+	OutcomeLocked = "locked"
+
+	// OutcomeCanceled is the outcome of the canceled executions.
+	OutcomeCanceled = "canceled"
+
+	// OutcomeFailed is the outcome of the executions failed for any other
+	// reason.
+	OutcomeFailed = "failed"
+)
+
+// searchAttributes upserts the search attributes of an execution, it does
+// nothing for the executions started before the searchAttributesChangeID
+// version.
+type searchAttributes struct {
+	enabled bool
+}
+
+func newSearchAttributes(ctx temporalsdk_workflow.Context) *searchAttributes {
+	v := temporalsdk_workflow.GetVersion(
+		ctx, searchAttributesChangeID, temporalsdk_workflow.DefaultVersion, searchAttributesVersion,
+	)
+
+	return &searchAttributes{enabled: v >= searchAttributesVersion}
+}
+
+// upsert upserts the updates, a failure is logged without failing the
+// execution.
+func (sa *searchAttributes) upsert(ctx temporalsdk_workflow.Context, updates ...temporalsdk_temporal.SearchAttributeUpdate) {
+	if !sa.enabled || len(updates) == 0 {
+		return
+	}
+
+	if err := temporalsdk_workflow.UpsertTypedSearchAttributes(ctx, updates...); err != nil {
+		temporalsdk_workflow.GetLogger(ctx).Warn("Unable to upsert search attributes.", "error", err)
+	}
+}
+
+// outcome returns the outcome and the rejection reason of an execution
+// returning err.
+func outcome(err error) (string, string) {
+	if err == nil {
+		return OutcomeAccepted, ""
+	}
+	if temporalsdk_temporal.IsCanceledError(err) {
+		return OutcomeCanceled, ""
+	}
+
+	var appErr *temporalsdk_temporal.ApplicationError
+	if !errors.As(err, &appErr) {
+		return OutcomeFailed, ""
+	}
+
+	switch appErr.Type() {
+	case ErrTypeValidation:
+		return OutcomeInvalid, appErr.Message()
+	case ErrTypeReviewRejected:
+		return OutcomeRejected, appErr.Message()
+	case ErrTypeLocked:
+		return OutcomeLocked, appErr.Message()
+	default:
+		return OutcomeFailed, ""
+	}
+}
@@ -0,0 +1,101 @@
+package workflow
+
+import (
+	temporalsdk_workflow "go.temporal.io/sdk/workflow"
+
+	"github.com/artefactual-sdps/preprocessing-moma/internal/activities"
+	"github.com/artefactual-sdps/preprocessing-moma/internal/config"
+)
+
+// workspace is the location of the SIP processed by an execution.
+type workspace struct {
+	// path is the SIP in the shared path.
+	path string
+
+	// staging enables the processing of a staged copy of the SIP.
+	staging bool
+
+	// stagePath is the staged copy of the SIP, once it's staged.
+	stagePath string
+
+	// journal enables the journal of the mutations of the SIP, rolled back
+	// if the execution fails.
+	journal bool
+}
+
+// workPath returns the path processed by the activities, the staged copy of
+// the SIP when it's staged.
+func (ws *workspace) workPath() string {
+	if ws.stagePath != "" {
+		return ws.stagePath
+	}
+
+	return ws.path
+}
+
+// stage copies the SIP into the staging directory, so the activities process
+// the copy.
+func (w *PreprocessingWorkflow) stage(
+	ctx temporalsdk_workflow.Context,
+	cfg config.Reloadable,
+	ws *workspace,
+	progress *Progress,
+) error {
+	progress.startStep(ctx, activities.StageName)
+	var result activities.StageResult
+	err := temporalsdk_workflow.ExecuteActivity(
+		w.withActOpts(ctx, cfg.Activities.Stage.Merge(cfg.Activities.Default)),
+		activities.StageName,
+		&activities.StageParams{Path: ws.path, Hardlinks: cfg.Staging.Hardlinks},
+	).Get(ctx, &result)
+	if err != nil {
+		return err
+	}
+	progress.completeStep(ctx, result.FileCount)
+	ws.stagePath = result.Path
+
+	return nil
+}
+
+// publishStage replaces the SIP with its processed staged copy.
+func (w *PreprocessingWorkflow) publishStage(
+	ctx temporalsdk_workflow.Context,
+	cfg config.Reloadable,
+	ws *workspace,
+	progress *Progress,
+) error {
+	progress.startStep(ctx, activities.PublishStageName)
+	err := temporalsdk_workflow.ExecuteActivity(
+		w.withActOpts(ctx, cfg.Activities.Stage.Merge(cfg.Activities.Default)),
+		activities.PublishStageName,
+		&activities.PublishStageParams{Path: ws.path},
+	).Get(ctx, nil)
+	if err != nil {
+		return err
+	}
+	progress.completeStep(ctx, 0)
+
+	return nil
+}
+
+// discardStage deletes the staged copy of the SIP of a failed execution, the
+// SIP is left untouched. A failure is logged without changing the execution
+// error.
+func (w *PreprocessingWorkflow) discardStage(
+	ctx temporalsdk_workflow.Context,
+	cfg config.Reloadable,
+	ws *workspace,
+) {
+	// The staged copy is discarded even if the execution is canceled.
+	ctx, cancel := temporalsdk_workflow.NewDisconnectedContext(ctx)
+	defer cancel()
+
+	err := temporalsdk_workflow.ExecuteActivity(
+		w.withActOpts(ctx, cfg.Activities.Stage.Merge(cfg.Activities.Default)),
+		activities.DiscardStageName,
+		&activities.DiscardStageParams{Path: ws.path},
+	).Get(ctx, nil)
+	if err != nil {
+		temporalsdk_workflow.GetLogger(ctx).Warn("Unable to discard the staged copy of the SIP.", "error", err)
+	}
+}